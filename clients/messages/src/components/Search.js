@@ -27,7 +27,7 @@ const Search = () => {
         }).then(handleError)
         .then(json => {
             const users = [];
-            json.forEach(user => {
+            json.users.forEach(user => {
                 users.push({
                    userName: user.userName,
                    firstName: user.firstName,
@@ -0,0 +1,137 @@
+package avatars
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+
+	//register the decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+//Sizes are the widths and heights, in pixels, of the
+//square thumbnails generated for every avatar
+var Sizes = []int{32, 64, 128, 256}
+
+//DefaultSize is the thumbnail size used for a user's PhotoURL
+const DefaultSize = 128
+
+//MaxUploadSize is the maximum size, in bytes, of an uploaded image
+const MaxUploadSize = 5 << 20
+
+//maxDimension is the maximum width or height, in pixels, of an
+//uploaded image. This protects against images that are small
+//when compressed but use huge amounts of memory when decoded.
+const maxDimension = 4096
+
+//ErrUnsupportedType is returned when an uploaded
+//image is not a PNG, JPEG or GIF
+var ErrUnsupportedType = errors.New("avatar must be a PNG, JPEG or GIF image")
+
+//ErrTooLarge is returned when an uploaded image
+//exceeds MaxUploadSize or maxDimension
+var ErrTooLarge = fmt.Errorf("avatar must be at most %d bytes and %dx%d pixels", MaxUploadSize, maxDimension, maxDimension)
+
+//Key returns the blob store key for the given user's avatar thumbnail
+func Key(userID int64, size int) string {
+	return fmt.Sprintf("avatars/%d/%d.png", userID, size)
+}
+
+//Thumbnails validates the uploaded image data, crops it to a centered
+//square, and returns it resized to each of the Sizes, encoded as PNG
+func Thumbnails(data []byte) (map[int][]byte, error) {
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	square := cropSquare(img)
+
+	thumbs := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		buf := &bytes.Buffer{}
+		if err := png.Encode(buf, resize(square, size)); err != nil {
+			return nil, fmt.Errorf("error encoding thumbnail: %v", err)
+		}
+		thumbs[size] = buf.Bytes()
+	}
+	return thumbs, nil
+}
+
+//cropSquare returns the largest centered square of the image
+func cropSquare(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	return square
+}
+
+//resize scales the square image to size x size pixels. Each
+//destination pixel is the average of the source pixels it covers,
+//which gives smooth results when shrinking.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	side := src.Bounds().Dx()
+	for y := 0; y < size; y++ {
+		sy0, sy1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			sx0, sx1 := span(x, size, side)
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+//span returns the range of source pixels [start, end) covered
+//by destination pixel i when scaling from side to size pixels.
+//The range always contains at least one pixel, so that images
+//smaller than the destination are scaled up.
+func span(i, size, side int) (int, int) {
+	start := i * side / size
+	end := (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
@@ -0,0 +1,85 @@
+package avatars
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encode(t *testing.T, img image.Image, format string) []byte {
+	buf := &bytes.Buffer{}
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(buf, img)
+	case "jpeg":
+		err = jpeg.Encode(buf, img, nil)
+	case "gif":
+		err = gif.Encode(buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("error encoding %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnails(t *testing.T) {
+	//a wide image whose center square is red, with blue bars on either side
+	img := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{0, 0, 255, 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		thumbs, err := Thumbnails(encode(t, img, format))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		if len(thumbs) != len(Sizes) {
+			t.Fatalf("%s: expected %d thumbnails but got %d", format, len(Sizes), len(thumbs))
+		}
+		for _, size := range Sizes {
+			thumb, err := png.Decode(bytes.NewReader(thumbs[size]))
+			if err != nil {
+				t.Fatalf("%s: error decoding %d thumbnail: %v", format, size, err)
+			}
+			if b := thumb.Bounds(); b.Dx() != size || b.Dy() != size {
+				t.Errorf("%s: expected %dx%d thumbnail but got %dx%d", format, size, size, b.Dx(), b.Dy())
+			}
+			//the thumbnail should only contain the red center square
+			r, _, b, _ := thumb.At(0, 0).RGBA()
+			if r>>8 < 200 || b>>8 > 50 {
+				t.Errorf("%s: expected %d thumbnail to be cropped to the red center", format, size)
+			}
+		}
+	}
+}
+
+func TestThumbnailsInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"Plain text", []byte("hello, world")},
+		{"Empty", []byte{}},
+		{"Truncated PNG", encode(t, image.NewRGBA(image.Rect(0, 0, 10, 10)), "png")[:20]},
+		{"Too many bytes", make([]byte, MaxUploadSize+1)},
+		{"Too many pixels", encode(t, image.NewGray(image.Rect(0, 0, maxDimension+1, 1)), "png")},
+	}
+
+	for _, c := range cases {
+		if _, err := Thumbnails(c.data); err == nil {
+			t.Errorf("case %s: expected error but didn't get one", c.name)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package blobs
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//ErrInvalidKey is returned when a key is empty or
+//would refer to a location outside of the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+//LocalStore represents a blob store backed by
+//a directory on the local file system
+type LocalStore struct {
+	root string
+}
+
+//NewLocalStore constructs a new LocalStore that keeps
+//its blobs under the given root directory
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: root}, nil
+}
+
+//Put saves the data under the given key,
+//replacing any blob already stored there
+func (ls *LocalStore) Put(key string, data []byte) error {
+	p, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	//write to a temporary file and rename it so that readers
+	//never see a partially-written blob
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+//Get returns a reader for the blob with the given key.
+//The caller must close the reader when done.
+func (ls *LocalStore) Get(key string) (io.ReadCloser, error) {
+	p, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+//Delete deletes the blob with the given key.
+//Deleting a key that doesn't exist is not an error.
+func (ls *LocalStore) Delete(key string) error {
+	p, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//path returns the file system path for the given key
+func (ls *LocalStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if len(key) == 0 || cleaned == "/" || cleaned != "/"+key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(ls.root, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,70 @@
+package blobs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("error creating local store: %v", err)
+	}
+
+	if _, err := store.Get("avatars/1/32.png"); err != ErrBlobNotFound {
+		t.Errorf("incorrect error when getting blob that was never stored: expected %v but got %v", ErrBlobNotFound, err)
+	}
+
+	data := []byte("not really a png")
+	if err := store.Put("avatars/1/32.png", data); err != nil {
+		t.Fatalf("error putting blob: %v", err)
+	}
+	r, err := store.Get("avatars/1/32.png")
+	if err != nil {
+		t.Fatalf("error getting blob: %v", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("error reading blob: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("incorrect blob retrieved: expected %q but got %q", data, got)
+	}
+
+	if err := store.Delete("avatars/1/32.png"); err != nil {
+		t.Errorf("error deleting blob: %v", err)
+	}
+	if _, err := store.Get("avatars/1/32.png"); err != ErrBlobNotFound {
+		t.Errorf("incorrect error when getting blob that was deleted: expected %v but got %v", ErrBlobNotFound, err)
+	}
+	if err := store.Delete("avatars/1/32.png"); err != nil {
+		t.Errorf("unexpected error deleting blob that doesn't exist: %v", err)
+	}
+}
+
+func TestLocalStoreInvalidKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("error creating local store: %v", err)
+	}
+
+	for _, key := range []string{"", "/", "../outside", "a/../../outside", "/absolute", "a//b", `a\b`} {
+		if err := store.Put(key, []byte("data")); err != ErrInvalidKey {
+			t.Errorf("key %q: expected %v but got %v", key, ErrInvalidKey, err)
+		}
+	}
+}
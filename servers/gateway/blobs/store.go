@@ -0,0 +1,28 @@
+package blobs
+
+import (
+	"errors"
+	"io"
+)
+
+//ErrBlobNotFound is returned from Store.Get() when
+//there is no blob stored with the requested key
+var ErrBlobNotFound = errors.New("no blob was found with the given key")
+
+//Store represents a store for binary large objects such as images.
+//This is an abstract interface that can be implemented against
+//several different types of storage, for example the local file
+//system or a cloud object store. Keys are slash-separated paths.
+type Store interface {
+	//Put saves the data under the given key,
+	//replacing any blob already stored there
+	Put(key string, data []byte) error
+
+	//Get returns a reader for the blob with the given key.
+	//The caller must close the reader when done.
+	Get(key string) (io.ReadCloser, error)
+
+	//Delete deletes the blob with the given key.
+	//Deleting a key that doesn't exist is not an error.
+	Delete(key string) error
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+//DefaultQueue is the name of the queue that user events are published to
+const DefaultQueue = "userQueue"
+
+//AMQPPublisher publishes user events as persistent
+//messages on a durable RabbitMQ queue
+type AMQPPublisher struct {
+	ch    *amqp.Channel
+	queue string
+	lock  sync.Mutex
+}
+
+//NewAMQPPublisher declares the durable queue on the channel and
+//returns a publisher for it. The channel should not be shared with
+//consumers, since a channel that fails to publish is closed.
+func NewAMQPPublisher(ch *amqp.Channel, queue string) (*AMQPPublisher, error) {
+	if ch == nil {
+		panic("nil channel")
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("error declaring queue: %v", err)
+	}
+	return &AMQPPublisher{
+		ch:    ch,
+		queue: queue,
+	}, nil
+}
+
+//Publish publishes the event as JSON to the queue
+func (ap *AMQPPublisher) Publish(event *UserEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %v", err)
+	}
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	err = ap.ch.Publish("", ap.queue, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+//Package events publishes changes to users so that other services
+//holding copies of user data can keep them in sync.
+//
+//Each event is published as a JSON object of the form
+//
+//	{
+//		"type": "user-update",
+//		"userID": 1,
+//		"user": {"id": 1, "userName": "...", "firstName": "...", "lastName": "...", "photoURL": "...", ...},
+//		"time": "2020-03-01T12:00:00Z"
+//	}
+//
+//where "type" is one of "user-new", "user-update" or "user-delete",
+//"user" is the user as returned by GET /v1/users/{id} and is omitted
+//from "user-delete" events, and "time" is when the change was made.
+package events
+
+import (
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//The types of user events
+const (
+	TypeUserNew    = "user-new"
+	TypeUserUpdate = "user-update"
+	TypeUserDelete = "user-delete"
+)
+
+//UserEvent describes a change to a user
+type UserEvent struct {
+	Type   string      `json:"type"`
+	UserID int64       `json:"userID"`
+	User   *users.User `json:"user,omitempty"`
+	Time   time.Time   `json:"time"`
+}
+
+//NewUserEvent returns an event of the given type about the user
+func NewUserEvent(eventType string, user *users.User) *UserEvent {
+	event := &UserEvent{
+		Type:   eventType,
+		UserID: user.ID,
+		User:   user,
+		Time:   time.Now().UTC(),
+	}
+	if eventType == TypeUserDelete {
+		event.User = nil
+	}
+	return event
+}
+
+//Publisher publishes user events to other services
+type Publisher interface {
+	//Publish publishes the event
+	Publish(event *UserEvent) error
+}
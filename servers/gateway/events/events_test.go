@@ -0,0 +1,45 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+func TestUserEventJSON(t *testing.T) {
+	user := &users.User{ID: 7, Email: "private@example.com", UserName: "tester", FirstName: "Test", LastName: "User"}
+	cases := []struct {
+		eventType string
+		hasUser   bool
+	}{
+		{TypeUserNew, true},
+		{TypeUserUpdate, true},
+		{TypeUserDelete, false},
+	}
+	for _, c := range cases {
+		data, err := json.Marshal(NewUserEvent(c.eventType, user))
+		if err != nil {
+			t.Fatalf("error encoding %s event: %v", c.eventType, err)
+		}
+		decoded := map[string]interface{}{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("error decoding %s event: %v", c.eventType, err)
+		}
+		if decoded["type"] != c.eventType || decoded["userID"] != float64(7) || decoded["time"] == nil {
+			t.Errorf("incorrect %s event: %s", c.eventType, data)
+		}
+		encodedUser, hasUser := decoded["user"].(map[string]interface{})
+		if hasUser != c.hasUser {
+			t.Errorf("%s event should include the user: %v, but got %s", c.eventType, c.hasUser, data)
+		}
+		if hasUser {
+			expected := map[string]interface{}{"id": float64(7), "userName": "tester", "firstName": "Test",
+				"lastName": "User", "photoURL": "", "bio": "", "pronouns": "", "timeZone": ""}
+			if !reflect.DeepEqual(encodedUser, expected) {
+				t.Errorf("incorrect user in %s event: expected %v but got %v", c.eventType, expected, encodedUser)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+//Package exports assembles archives of the data held about a user,
+//for answering data access requests.
+//
+//Besides the data held by the gateway, an export includes the data held
+//by each backend service. Backends take part by serving
+//
+//	GET /v1/internal/export
+//
+//which must respond with a JSON document of everything the backend holds
+//about the user in the X-User header, encoded the same way as in proxied
+//requests. A backend that holds nothing about the user may respond with
+//404 Not Found. The gateway doesn't proxy /v1/internal paths, so the
+//endpoint is only reachable by the gateway.
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//BackendPath is the path that backends serve their exports from
+const BackendPath = "/v1/internal/export"
+
+//maxBackendSize is the maximum size, in bytes, of a backend's export
+const maxBackendSize = 64 << 20
+
+//Key returns the blob store key of the user's latest export
+func Key(userID int64) string {
+	return fmt.Sprintf("exports/%d.zip", userID)
+}
+
+//Backend is a service that holds data about users
+type Backend struct {
+	//Name names the backend's file in the archive
+	Name string
+	//Addr is the backend's base URL
+	Addr string
+}
+
+//ParseBackends parses a comma-separated list of backends
+//of the form "name=url", such as "messaging=http://messaging:80"
+func ParseBackends(s string) ([]Backend, error) {
+	backends := []Backend{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("invalid export backend %q: must be of the form name=url", entry)
+		}
+		backends = append(backends, Backend{parts[0], strings.TrimSuffix(parts[1], "/")})
+	}
+	return backends, nil
+}
+
+//Exporter fetches backend data for exports and makes sure
+//that each user only has one export running at a time
+type Exporter struct {
+	Backends []Backend
+	Client   *http.Client
+	lock     sync.Mutex
+	running  map[int64]bool
+}
+
+//NewExporter constructs a new Exporter for the given backends
+func NewExporter(backends []Backend) *Exporter {
+	return &Exporter{
+		Backends: backends,
+		Client:   &http.Client{Timeout: time.Minute},
+		running:  make(map[int64]bool),
+	}
+}
+
+//Start records that an export for the user is running,
+//or returns false if one already is
+func (e *Exporter) Start(userID int64) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.running[userID] {
+		return false
+	}
+	e.running[userID] = true
+	return true
+}
+
+//Finish records that the user's export is no longer running
+func (e *Exporter) Finish(userID int64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.running, userID)
+}
+
+//FetchBackends adds the export of every backend to the archive as
+//"backends/{name}.json", skipping backends that have nothing about
+//the user. userJSON is the user as sent in the X-User header.
+func (e *Exporter) FetchBackends(ctx context.Context, archive *Archive, userJSON []byte) error {
+	for _, backend := range e.Backends {
+		data, err := e.fetch(ctx, backend, userJSON)
+		if err != nil {
+			return fmt.Errorf("error exporting from %s: %v", backend.Name, err)
+		}
+		if data == nil {
+			continue
+		}
+		if err := archive.Add("backends/"+backend.Name+".json", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//fetch returns the backend's export for the user,
+//or nil if the backend has nothing about them
+func (e *Exporter) fetch(ctx context.Context, backend Backend, userJSON []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.Addr+BackendPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User", string(userJSON))
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBackendSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBackendSize {
+		return nil, fmt.Errorf("export is larger than %d bytes", maxBackendSize)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("export is not valid JSON")
+	}
+	return data, nil
+}
+
+//Archive is a ZIP archive being assembled in memory
+type Archive struct {
+	buf bytes.Buffer
+	zw  *zip.Writer
+}
+
+//NewArchive constructs a new, empty Archive
+func NewArchive() *Archive {
+	a := &Archive{}
+	a.zw = zip.NewWriter(&a.buf)
+	return a
+}
+
+//Add adds a file with the given name and contents to the archive
+func (a *Archive) Add(name string, data []byte) error {
+	w, err := a.zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("error adding %s: %v", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	return nil
+}
+
+//AddJSON adds a file with the given name to the
+//archive containing the value encoded as JSON
+func (a *Archive) AddJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %v", name, err)
+	}
+	return a.Add(name, data)
+}
+
+//Bytes finishes the archive and returns its contents.
+//Nothing more can be added afterwards.
+func (a *Archive) Bytes() ([]byte, error) {
+	if err := a.zw.Close(); err != nil {
+		return nil, fmt.Errorf("error finishing archive: %v", err)
+	}
+	return a.buf.Bytes(), nil
+}
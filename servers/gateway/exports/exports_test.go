@@ -0,0 +1,90 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseBackends(t *testing.T) {
+	backends, err := ParseBackends("messaging=http://messaging:80/, summary=http://summary:80")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Backend{{"messaging", "http://messaging:80"}, {"summary", "http://summary:80"}}
+	if !reflect.DeepEqual(backends, expected) {
+		t.Errorf("incorrect backends: expected %v but got %v", expected, backends)
+	}
+	if backends, err := ParseBackends(""); err != nil || len(backends) != 0 {
+		t.Errorf("expected no backends but got %v (error %v)", backends, err)
+	}
+	if _, err := ParseBackends("http://messaging:80"); err == nil {
+		t.Errorf("expected error for backend without a name")
+	}
+}
+
+func TestExporterStart(t *testing.T) {
+	exporter := NewExporter(nil)
+	if !exporter.Start(1) {
+		t.Fatal("first export should start")
+	}
+	if exporter.Start(1) {
+		t.Error("second export should not start while the first is running")
+	}
+	if !exporter.Start(2) {
+		t.Error("other users' exports should start")
+	}
+	exporter.Finish(1)
+	if !exporter.Start(1) {
+		t.Error("export should start once the first has finished")
+	}
+}
+
+func TestFetchBackends(t *testing.T) {
+	messaging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != BackendPath || r.Header.Get("X-User") != `{"id":1}` {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"messages": []}`))
+	}))
+	defer messaging.Close()
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer broken.Close()
+
+	archive := NewArchive()
+	exporter := NewExporter([]Backend{{"messaging", messaging.URL}, {"empty", empty.URL}})
+	if err := exporter.FetchBackends(context.Background(), archive, []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := archive.Bytes()
+	if err != nil {
+		t.Fatalf("error finishing archive: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "backends/messaging.json" {
+		t.Fatalf("expected only backends/messaging.json in archive but got %v", zr.File)
+	}
+	f, _ := zr.File[0].Open()
+	contents, _ := ioutil.ReadAll(f)
+	if string(contents) != `{"messages": []}` {
+		t.Errorf("incorrect contents: %s", contents)
+	}
+
+	exporter = NewExporter([]Backend{{"broken", broken.URL}})
+	if err := exporter.FetchBackends(context.Background(), NewArchive(), []byte(`{"id":1}`)); err == nil {
+		t.Error("expected error for backend returning invalid JSON")
+	}
+}
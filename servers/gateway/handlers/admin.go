@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/exports"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+const adminUsersPath = "/v1/admin/users/"
+
+//defaultAdminListLimit and maxAdminListLimit bound the
+//number of users returned by a single admin list request
+const defaultAdminListLimit = 50
+const maxAdminListLimit = 200
+
+//AdminUser is the view of a user returned to administrators,
+//which includes the fields hidden from other users
+type AdminUser struct {
+	*users.User
+	Email                 string `json:"email"`
+	Admin                 bool   `json:"admin"`
+	Suspended             bool   `json:"suspended"`
+	PasswordResetRequired bool   `json:"passwordResetRequired"`
+}
+
+//newAdminUser returns the view of the user returned to administrators
+func newAdminUser(user *users.User) *AdminUser {
+	return &AdminUser{user, user.Email, user.Admin, user.Suspended, user.PasswordResetRequired}
+}
+
+//AdminUsersHandler handles requests for the admin "users" resource,
+//listing users with pagination and filters
+func (ctx *HandlerCtx) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if !ctx.requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "http method must be GET", http.StatusMethodNotAllowed)
+		return
+	}
+	params, err := parseListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	found, err := ctx.UserStore.List(r.Context(), params)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	result := make([]*AdminUser, len(found))
+	for i, user := range found {
+		result[i] = newAdminUser(user)
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+//AdminSpecificUserHandler handles admin requests to view or
+//permanently delete a specific user
+func (ctx *HandlerCtx) AdminSpecificUserHandler(w http.ResponseWriter, r *http.Request) {
+	if !ctx.requireAdmin(w, r) {
+		return
+	}
+	user, ok := ctx.getAdminTarget(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(newAdminUser(user))
+	} else if r.Method == http.MethodDelete {
+		if err := ctx.UserStore.Delete(r.Context(), user.ID); err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		//end the sessions first, so that they don't outlive
+		//the user if cleaning up after them fails
+		if err := ctx.endUserSessions(user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx.Indexer.RemoveUser(user)
+		ctx.publishUserEvent(events.TypeUserDelete, user)
+		if err := ctx.BlockStore.DeleteUser(user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ctx.ContactStore.DeleteUser(user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ctx.deleteAvatar(user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ctx.BlobStore.Delete(exports.Key(user.ID)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("user deleted"))
+	} else {
+		http.Error(w, "http method must be GET or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//AdminSuspensionHandler handles admin requests to suspend (POST)
+//or unsuspend (DELETE) a specific user. Suspending a user ends
+//all of their sessions and prevents them from signing in.
+func (ctx *HandlerCtx) AdminSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	if !ctx.requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "http method must be POST or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := ctx.getAdminTarget(w, r)
+	if !ok {
+		return
+	}
+	suspend := r.Method == http.MethodPost
+	user, err := ctx.UserStore.SetSuspended(r.Context(), user.ID, suspend)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	if suspend {
+		if err := ctx.endUserSessions(user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newAdminUser(user))
+}
+
+//AdminPasswordResetHandler handles admin requests to force a specific
+//user to change their password. All of the user's sessions are ended,
+//and the next session they begin can only be used to change the password.
+func (ctx *HandlerCtx) AdminPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	if !ctx.requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "http method must be POST", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := ctx.getAdminTarget(w, r)
+	if !ok {
+		return
+	}
+	user, err := ctx.UserStore.SetPasswordResetRequired(r.Context(), user.ID, true)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	if err := ctx.endUserSessions(user.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newAdminUser(user))
+}
+
+//requireAdmin writes an error to the response and returns false
+//unless the request comes from an authenticated administrator.
+//The admin flag is read from the database rather than the session state
+//or the user cache, so that revoking it takes effect immediately.
+func (ctx *HandlerCtx) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	_, ok := ctx.getAdmin(w, r)
+	return ok
+}
+
+//getAdmin returns the authenticated administrator making the request,
+//or writes an error to the response and returns false if there isn't one
+func (ctx *HandlerCtx) getAdmin(w http.ResponseWriter, r *http.Request) (*users.User, bool) {
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return nil, false
+	}
+	user, err := ctx.getFreshUser(r.Context(), sessionState.User.ID)
+	if err != nil || !user.Admin || user.Suspended {
+		http.Error(w, "user is not an administrator", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+//getAdminTarget returns the user identified by the ID
+//following "/v1/admin/users/" in the request path
+func (ctx *HandlerCtx) getAdminTarget(w http.ResponseWriter, r *http.Request) (*users.User, bool) {
+	stringID := strings.Split(strings.TrimPrefix(r.URL.Path, adminUsersPath), "/")[0]
+	id, err := strconv.ParseInt(stringID, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+	user, err := ctx.getFreshUser(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return nil, false
+	}
+	return user, true
+}
+
+//endUserSessions ends all of the user's sessions
+//and closes their websocket connection
+func (ctx *HandlerCtx) endUserSessions(userID int64) error {
+	if err := ctx.SessionStore.DeleteUserSessions(userID); err != nil {
+		return err
+	}
+	if ctx.Notifier != nil {
+		ctx.Notifier.CloseConnection(userID)
+	}
+	return nil
+}
+
+//parseListParams reads the "q", "suspended", "offset" and
+//"limit" query string parameters of an admin list request
+func parseListParams(r *http.Request) (*users.ListParams, error) {
+	query := r.URL.Query()
+	params := &users.ListParams{
+		Query: query.Get("q"),
+		Limit: defaultAdminListLimit,
+	}
+	if s := query.Get("suspended"); len(s) > 0 {
+		suspended, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("suspended must be true or false")
+		}
+		params.Suspended = &suspended
+	}
+	if s := query.Get("offset"); len(s) > 0 {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("offset must be a non-negative integer")
+		}
+		params.Offset = offset
+	}
+	if s := query.Get("limit"); len(s) > 0 {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxAdminListLimit {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d", maxAdminListLimit)
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//failingBlockStore is a blocks.Store that can't delete users
+type failingBlockStore struct {
+	fakeBlockStore
+}
+
+func (fs *failingBlockStore) DeleteUser(userID int64) error {
+	return errors.New("database is unavailable")
+}
+
+func TestAdminDeleteEndsSessions(t *testing.T) {
+	ctx := newTestContext()
+	ctx.BlockStore = &failingBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	admin, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "admin@example.com", UserName: "theadmin"})
+	if err != nil {
+		t.Fatalf("error inserting admin: %v", err)
+	}
+	ctx.UserStore = &adminUserStore{ctx.UserStore, admin.ID}
+	user, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "test@example.com", UserName: "tester"})
+	if err != nil {
+		t.Fatalf("error inserting user: %v", err)
+	}
+	tokens := []string{}
+	for _, u := range []*users.User{admin, user} {
+		rr := httptest.NewRecorder()
+		if _, err := ctx.beginSession(u, rr); err != nil {
+			t.Fatalf("error beginning session: %v", err)
+		}
+		tokens = append(tokens, rr.Header().Get("Authorization"))
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("%s%d", adminUsersPath, user.ID), nil)
+	req.Header.Set("Authorization", tokens[0])
+	rr := httptest.NewRecorder()
+	ctx.AdminSpecificUserHandler(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("incorrect status code: expected %d but got %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	//cleaning up failed, but the deleted user's session must already be over
+	req = httptest.NewRequest(http.MethodGet, "/v1/users?q=tester", nil)
+	req.Header.Set("Authorization", tokens[1])
+	rr = httptest.NewRecorder()
+	ctx.UsersHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("deleted user's session still works: expected %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestPasswordResetRequired(t *testing.T) {
+	ctx := newTestContext()
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	signUp := `{"email": "test@example.com", "password": "password1234", "passwordConf": "password1234",
+		"userName": "tester", "firstName": "Test", "lastName": "User"}`
+	rr := httptest.NewRecorder()
+	ctx.UsersHandler(rr, jsonRequest(http.MethodPost, "/v1/users", signUp))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("error signing up: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, err := ctx.UserStore.SetPasswordResetRequired(context.Background(), 1, true); err != nil {
+		t.Fatalf("error requiring a password reset: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	ctx.SessionsHandler(rr, jsonRequest(http.MethodPost, "/v1/sessions",
+		`{"email": "test@example.com", "password": "password1234"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("error signing in: %d %s", rr.Code, rr.Body.String())
+	}
+	token := rr.Header().Get("Authorization")
+
+	//the session can't be used for anything but changing the password
+	requests := []struct {
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{ctx.UsersHandler, httptest.NewRequest(http.MethodGet, "/v1/users?q=test", nil)},
+		{ctx.SpecificUserHandler, jsonRequest(http.MethodPatch, "/v1/users/me", `{"firstName": "New"}`)},
+		{ctx.UserNameHandler, jsonRequest(http.MethodPatch, "/v1/users/me/username", `{"userName": "newname"}`)},
+		{ctx.BlocksHandler, httptest.NewRequest(http.MethodGet, "/v1/users/me/blocks", nil)},
+		{ctx.ContactsHandler, httptest.NewRequest(http.MethodGet, "/v1/users/me/contacts", nil)},
+		{ctx.ExportHandler, httptest.NewRequest(http.MethodPost, "/v1/users/me/export", nil)},
+	}
+	for _, r := range requests {
+		r.req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		r.handler(rr, r.req)
+		if rr.Code < 400 {
+			t.Errorf("%s %s: expected an error but got %d", r.req.Method, r.req.URL.Path, rr.Code)
+		}
+	}
+
+	req := jsonRequest(http.MethodPut, "/v1/users/me/password",
+		`{"currentPassword": "password1234", "newPassword": "newpassword1234", "newPasswordConf": "newpassword1234"}`)
+	req.Header.Set("Authorization", token)
+	rr = httptest.NewRecorder()
+	ctx.PasswordHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("error changing password: %d %s", rr.Code, rr.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/v1/users?q=test", nil)
+	req.Header.Set("Authorization", rr.Header().Get("Authorization"))
+	rr = httptest.NewRecorder()
+	ctx.UsersHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("session after changing the password: expected %d but got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestAdminUserView(t *testing.T) {
+	user := &users.User{ID: 1, Email: "test@example.com", UserName: "tester", Suspended: true}
+	buf, err := json.Marshal(newAdminUser(user))
+	if err != nil {
+		t.Fatalf("error encoding user: %v", err)
+	}
+	view := map[string]interface{}{}
+	json.Unmarshal(buf, &view)
+	if view["email"] != "test@example.com" || view["suspended"] != true || view["admin"] != false || view["userName"] != "tester" {
+		t.Errorf("incorrect admin view of user: %s", buf)
+	}
+}
+
+func TestRevokedAdminWithCache(t *testing.T) {
+	ctx := newTestContext()
+	admin, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "admin@example.com", UserName: "theadmin"})
+	if err != nil {
+		t.Fatalf("error inserting admin: %v", err)
+	}
+	//the admin's rights are revoked through another gateway,
+	//which doesn't invalidate this gateway's cache
+	database := &adminUserStore{ctx.UserStore, admin.ID}
+	ctx.UserStore = users.NewCachedStore(database, time.Hour)
+	rr := httptest.NewRecorder()
+	if _, err := ctx.beginSession(admin, rr); err != nil {
+		t.Fatalf("error beginning session: %v", err)
+	}
+	token := rr.Header().Get("Authorization")
+
+	expected := []int{http.StatusOK, http.StatusForbidden}
+	for i, code := range expected {
+		if _, err := ctx.UserStore.GetByID(context.Background(), admin.ID); err != nil {
+			t.Fatalf("error caching admin: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s%d", adminUsersPath, admin.ID), nil)
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		ctx.AdminSpecificUserHandler(rr, req)
+		if rr.Code != code {
+			t.Errorf("request %d: expected %d but got %d", i, code, rr.Code)
+		}
+		database.adminID = 0
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//defaultAuditLimit and maxAuditLimit bound the number
+//of events returned by a single security events request
+const defaultAuditLimit = 50
+const maxAuditLimit = 200
+
+//The reasons recorded when a sign-in fails
+const (
+	signInUnknownEmail  = "unknown email"
+	signInWrongPassword = "wrong password"
+	signInSuspended     = "account suspended"
+)
+
+//SecurityEventsHandler handles requests for the authenticated
+//user's security events, such as sign-ins and password changes
+func (ctx *HandlerCtx) SecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "http method must be GET", http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//users only see events about themselves, whatever userID they ask for
+	query.UserID = sessionState.User.ID
+	ctx.writeAuditEvents(w, query)
+}
+
+//AdminSecurityEventsHandler handles admin requests to search the audit log.
+//Events can be filtered by "userID", "type", "email", "ip", "since" and
+//"until", and paginated with "offset" and "limit".
+func (ctx *HandlerCtx) AdminSecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if !ctx.requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "http method must be GET", http.StatusMethodNotAllowed)
+		return
+	}
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx.writeAuditEvents(w, query)
+}
+
+//writeAuditEvents writes the events matching the query to the response
+func (ctx *HandlerCtx) writeAuditEvents(w http.ResponseWriter, query *audit.Query) {
+	found, err := ctx.AuditStore.Find(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(found)
+}
+
+//recordAudit records the event in the audit log. The action has
+//already happened, so failing to record it is logged rather than
+//reported to the client.
+func (ctx *HandlerCtx) recordAudit(event *audit.Event) {
+	if ctx.AuditSink == nil {
+		return
+	}
+	if err := ctx.AuditSink.Record(event); err != nil {
+		log.Printf("Error recording %s event for user %d: %s", event.Type, event.UserID, err.Error())
+	}
+}
+
+//recordSignIn records a sign-in attempt with the given email, which
+//failed for the given reason, or succeeded if the reason is empty
+func (ctx *HandlerCtx) recordSignIn(r *http.Request, user *users.User, email string, reason string) {
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+	eventType := audit.TypeSignIn
+	if len(reason) > 0 {
+		eventType = audit.TypeSignInFailed
+	}
+	event := audit.NewEvent(r, eventType, userID)
+	event.Email = email
+	event.Detail = reason
+	ctx.recordAudit(event)
+}
+
+//updatedFields returns the names of the profile fields
+//set by the updates, separated by commas
+func updatedFields(updates *users.Updates) string {
+	fields := []string{}
+	if len(updates.FirstName) > 0 {
+		fields = append(fields, "firstName")
+	}
+	if len(updates.LastName) > 0 {
+		fields = append(fields, "lastName")
+	}
+	if updates.Bio != nil {
+		fields = append(fields, "bio")
+	}
+	if updates.Pronouns != nil {
+		fields = append(fields, "pronouns")
+	}
+	if updates.TimeZone != nil {
+		fields = append(fields, "timeZone")
+	}
+	if updates.Status != nil {
+		fields = append(fields, "status")
+	}
+	return strings.Join(fields, ",")
+}
+
+//parseAuditQuery reads the filter and pagination
+//query string parameters of a security events request
+func parseAuditQuery(r *http.Request) (*audit.Query, error) {
+	values := r.URL.Query()
+	query := &audit.Query{
+		Type:  values.Get("type"),
+		Email: values.Get("email"),
+		IP:    values.Get("ip"),
+		Limit: defaultAuditLimit,
+	}
+	if s := values.Get("userID"); len(s) > 0 {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("userID must be a positive integer")
+		}
+		query.UserID = id
+	}
+	if s := values.Get("since"); len(s) > 0 {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("since must be an RFC 3339 time")
+		}
+		query.Since = since
+	}
+	if s := values.Get("until"); len(s) > 0 {
+		until, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("until must be an RFC 3339 time")
+		}
+		query.Until = until
+	}
+	if s := values.Get("offset"); len(s) > 0 {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("offset must be a non-negative integer")
+		}
+		query.Offset = offset
+	}
+	if s := values.Get("limit"); len(s) > 0 {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d", maxAuditLimit)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//adminUserStore is a users.Store in which the user
+//with the given ID is an administrator
+type adminUserStore struct {
+	users.Store
+	adminID int64
+}
+
+func (as *adminUserStore) GetByID(c context.Context, id int64) (*users.User, error) {
+	user, err := as.Store.GetByID(c, id)
+	if err == nil && id == as.adminID {
+		user.Admin = true
+	}
+	return user, err
+}
+
+func (as *adminUserStore) GetByIDFresh(c context.Context, id int64) (*users.User, error) {
+	user, err := as.Store.GetByIDFresh(c, id)
+	if err == nil && id == as.adminID {
+		user.Admin = true
+	}
+	return user, err
+}
+
+func TestSecurityEvents(t *testing.T) {
+	ctx := newTestContext()
+	signUp := `{"email": "test@example.com", "password": "password1234", "passwordConf": "password1234",
+		"userName": "tester", "firstName": "Test", "lastName": "User"}`
+	steps := []struct {
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{ctx.UsersHandler, jsonRequest(http.MethodPost, "/v1/users", signUp)},
+		{ctx.SessionsHandler, jsonRequest(http.MethodPost, "/v1/sessions",
+			`{"email": "nobody@example.com", "password": "password1234"}`)},
+		{ctx.SessionsHandler, jsonRequest(http.MethodPost, "/v1/sessions",
+			`{"email": "test@example.com", "password": "wrongpassword"}`)},
+	}
+	for _, step := range steps {
+		step.handler(httptest.NewRecorder(), step.req)
+	}
+	rr := httptest.NewRecorder()
+	ctx.SessionsHandler(rr, jsonRequest(http.MethodPost, "/v1/sessions",
+		`{"email": "test@example.com", "password": "password1234"}`))
+	token := rr.Header().Get("Authorization")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/me/security-events?userID=99", nil)
+	req.Header.Set("Authorization", token)
+	rr = httptest.NewRecorder()
+	ctx.SecurityEventsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("incorrect status code: expected %d but got %d", http.StatusOK, rr.Code)
+	}
+	found := []*audit.Event{}
+	if err := json.NewDecoder(rr.Body).Decode(&found); err != nil {
+		t.Fatalf("error decoding events: %v", err)
+	}
+	//the failed sign-in with an unknown email isn't about the user
+	expected := []string{audit.TypeSignIn, audit.TypeSignInFailed, audit.TypeSignUp}
+	if len(found) != len(expected) {
+		t.Fatalf("incorrect number of events: expected %d but got %d", len(expected), len(found))
+	}
+	for i, event := range found {
+		if event.Type != expected[i] || event.UserID != 1 {
+			t.Errorf("incorrect event %d: expected %s for user 1 but got %s for user %d", i, expected[i], event.Type, event.UserID)
+		}
+	}
+	if found[1].Detail != signInWrongPassword {
+		t.Errorf("incorrect failed sign-in detail: expected %q but got %q", signInWrongPassword, found[1].Detail)
+	}
+
+	//only administrators can search every user's events
+	req = httptest.NewRequest(http.MethodGet, "/v1/admin/security-events?type=sign-in-failed", nil)
+	req.Header.Set("Authorization", token)
+	rr = httptest.NewRecorder()
+	ctx.AdminSecurityEventsHandler(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("incorrect status code for non-admin: expected %d but got %d", http.StatusForbidden, rr.Code)
+	}
+	admin, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "admin@example.com", UserName: "admin"})
+	if err != nil {
+		t.Fatalf("error inserting admin: %v", err)
+	}
+	ctx.UserStore = &adminUserStore{ctx.UserStore, admin.ID}
+	rr = httptest.NewRecorder()
+	if _, err := ctx.beginSession(admin, rr); err != nil {
+		t.Fatalf("error beginning session: %v", err)
+	}
+	req.Header.Set("Authorization", rr.Header().Get("Authorization"))
+	rr = httptest.NewRecorder()
+	ctx.AdminSecurityEventsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("incorrect status code for admin: expected %d but got %d", http.StatusOK, rr.Code)
+	}
+	found = []*audit.Event{}
+	json.NewDecoder(rr.Body).Decode(&found)
+	if len(found) != 2 || found[1].Email != "nobody@example.com" || found[1].Detail != signInUnknownEmail {
+		t.Errorf("incorrect failed sign-ins: %+v", found)
+	}
+}
+
+func TestParseAuditQuery(t *testing.T) {
+	cases := []struct {
+		query string
+		valid bool
+	}{
+		{"", true},
+		{"userID=1&type=sign-in&since=2020-01-01T00:00:00Z&until=2020-02-01T00:00:00Z&offset=5&limit=10", true},
+		{"userID=abc", false},
+		{"since=yesterday", false},
+		{"limit=1000", false},
+		{"offset=-1", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/admin/security-events?"+c.query, nil)
+		if _, err := parseAuditQuery(req); (err == nil) != c.valid {
+			t.Errorf("case %q: expected valid to be %t but got error %v", c.query, c.valid, err)
+		}
+	}
+}
@@ -2,16 +2,41 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"path"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
 )
 
+//contactBoost is added to the relevance of the authenticated user's
+//contacts in search, which ranks them ahead of other users matching
+//the query as well, but not ahead of users matching it better
+const contactBoost = 3
+
+//maxFuzzyDistance is the most typos a fuzzy user search tolerates
+const maxFuzzyDistance = 2
+
+//Modes of user search: words of the query match the beginning
+//of words of users' names, or any part of them
+const (
+	searchModePrefix   = "prefix"
+	searchModeContains = "contains"
+)
+
+//defaultSearchLimit and maxSearchLimit are the default and largest
+//number of users returned by one page of a user search
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
 //UsersHandler handles requests for the "users" resource
 func (ctx *HandlerCtx) UsersHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
@@ -20,39 +45,43 @@ func (ctx *HandlerCtx) UsersHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "request body must be in JSON", http.StatusUnsupportedMediaType)
 			return
 		}
-		nu := users.NewUser{}
-		err := json.NewDecoder(r.Body).Decode(&nu)
+		su := SignUp{}
+		err := json.NewDecoder(r.Body).Decode(&su)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		user, err := nu.ToUser()
+		user, err := su.ToUser()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		userWithID, err := ctx.UserStore.Insert(user)
+		inviteCode, status, err := ctx.checkSignupAllowed(&su)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if status, err := ctx.checkUserNameAvailable(r.Context(), user.UserName, 0); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		userWithID, err := ctx.UserStore.Insert(r.Context(), user)
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		if status, err := ctx.redeemInvite(r.Context(), inviteCode, userWithID); err != nil {
+			http.Error(w, err.Error(), status)
 			return
 		}
 
-		firstname := strings.ToLower(userWithID.FirstName)
-		fsplit := strings.Split(firstname, " ")
-		for i := range fsplit {
-			fsplit[i] = strings.TrimSpace(fsplit[i])
-			ctx.Trie.Add(fsplit[i], userWithID.ID)
-		}
-		lastname := strings.ToLower(userWithID.LastName)
-		lsplit := strings.Split(lastname, " ")
-		for i := range lsplit {
-			lsplit[i] = strings.TrimSpace(lsplit[i])
-			ctx.Trie.Add(lsplit[i], userWithID.ID)
-		}
-		ctx.Trie.Add(strings.ToLower(userWithID.UserName), userWithID.ID)
+		ctx.Indexer.IndexUser(userWithID)
+		ctx.publishUserEvent(events.TypeUserNew, userWithID)
+		signUpEvent := audit.NewEvent(r, audit.TypeSignUp, userWithID.ID)
+		signUpEvent.Email = userWithID.Email
+		ctx.recordAudit(signUpEvent)
 
-		sessionState := &SessionState{time.Now(), userWithID}
-		sid, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, sessionState, w)
+		sid, err := ctx.beginSession(userWithID, w)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -65,7 +94,8 @@ func (ctx *HandlerCtx) UsersHandler(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(userWithID)
 	} else if r.Method == http.MethodGet {
-		_, err := sessions.GetSessionID(r, ctx.SigningKey)
+		sessionState := &SessionState{}
+		_, err := ctx.getState(r, sessionState)
 		if err != nil {
 			http.Error(w, "user is not authenticated", http.StatusUnauthorized)
 			return
@@ -75,21 +105,110 @@ func (ctx *HandlerCtx) UsersHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
+		fuzzy, distance, err := parseFuzzyParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mode := r.URL.Query().Get("mode")
+		if len(mode) == 0 {
+			mode = searchModePrefix
+		}
+		if mode != searchModePrefix && mode != searchModeContains {
+			http.Error(w, "mode must be prefix or contains", http.StatusBadRequest)
+			return
+		}
+		if fuzzy && mode == searchModeContains {
+			http.Error(w, "fuzzy search can't be combined with mode=contains", http.StatusBadRequest)
+			return
+		}
+		limit, cursor, err := parsePageParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if cursor != nil && (fuzzy || mode == searchModeContains) {
+			http.Error(w, "only prefix search has more than one page", http.StatusBadRequest)
+			return
+		}
+
+		//users on either side of a block don't see each other in search
+		blockList, err := ctx.GetBlockList(sessionState.User.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		excluded := blockList.excluded()
+
+		contactIDs, err := ctx.ContactStore.GetContacts(sessionState.User.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		isContact := make(map[int64]bool, len(contactIDs))
+		for _, id := range contactIDs {
+			isContact[id] = true
+		}
+		boost := func(id int64) float64 {
+			if isContact[id] {
+				return contactBoost
+			}
+			return 0
+		}
 
-		userIDs := ctx.Trie.Find(query, 20)
-		users := []*users.User{}
-		for _, id := range userIDs {
-			user, err := ctx.UserStore.GetByID(id)
+		var ranked []int64
+		var next *indexes.Cursor
+		if fuzzy || mode == searchModeContains {
+			//fuzzy and substring matches are ranked as a whole,
+			//so these searches only return the best page
+			var userIDs []int64
+			if fuzzy {
+				userIDs = ctx.Indexer.SearchFuzzy(query, distance, limit+len(excluded), boost)
+			} else {
+				userIDs, err = ctx.Indexer.SearchContains(query, limit+len(excluded), boost)
+				if err == indexes.ErrContainsUnsupported {
+					http.Error(w, err.Error(), http.StatusNotImplemented)
+					return
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			for _, id := range userIDs {
+				if !excluded[id] && len(ranked) < limit {
+					ranked = append(ranked, id)
+				}
+			}
+		} else {
+			//pages follow the order of the index, so each one starts
+			//where the last ended, and is ranked by relevance itself
+			ranked, next, err = ctx.Indexer.SearchAfter(query, cursor, limit, func(id int64) bool {
+				return excluded[id]
+			})
 			if err != nil {
-				http.Error(w, "no user found with given ID", http.StatusNotFound)
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			users = append(users, user)
+			ranked = ctx.Indexer.Rank(query, ranked, boost)
 		}
 
+		found, err := ctx.UserStore.GetByIDs(r.Context(), ranked)
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+
+		page := &searchPage{Users: found}
+		if page.Users == nil {
+			page.Users = []*users.User{}
+		}
+		if next != nil {
+			page.Next = encodeSearchCursor(next)
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Header().Add("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		json.NewEncoder(w).Encode(page)
 	} else {
 		http.Error(w, "http method must be GET or POST", http.StatusMethodNotAllowed)
 		return
@@ -105,7 +224,7 @@ func (ctx *HandlerCtx) SpecificUserHandler(w http.ResponseWriter, r *http.Reques
 	}
 	stringID := path.Base(r.URL.Path)
 	sessionState := &SessionState{}
-	_, err = sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, sessionState)
+	sid, err := ctx.getState(r, sessionState)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -124,11 +243,9 @@ func (ctx *HandlerCtx) SpecificUserHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if r.Method == http.MethodGet {
-		stringID := path.Base(r.URL.Path)
-		id, _ := strconv.ParseInt(stringID, 10, 64)
-		user, err := ctx.UserStore.GetByID(id)
+		user, err := ctx.UserStore.GetByID(r.Context(), id)
 		if err != nil {
-			http.Error(w, "no user found with given ID", http.StatusNotFound)
+			http.Error(w, err.Error(), userStoreStatus(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -146,44 +263,39 @@ func (ctx *HandlerCtx) SpecificUserHandler(w http.ResponseWriter, r *http.Reques
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		oldUser := sessionState.User
-		err = sessionState.User.ApplyUpdates(&update)
+		//the session's copy of the user may be out of date,
+		//and reindexing must remove the keys that are indexed
+		oldUser, err := ctx.getFreshUser(r.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), userStoreStatus(err))
 			return
 		}
-		user, err := ctx.UserStore.Update(id, &update)
+		updatedUser := *oldUser
+		err = updatedUser.ApplyUpdates(&update)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		oldfirstname := strings.ToLower(oldUser.FirstName)
-		ofsplit := strings.Split(oldfirstname, " ")
-		for i := range ofsplit {
-			ofsplit[i] = strings.TrimSpace(ofsplit[i])
-			ctx.Trie.Remove(ofsplit[i], oldUser.ID)
-		}
-		oldlastname := strings.ToLower(oldUser.LastName)
-		olsplit := strings.Split(oldlastname, " ")
-		for i := range olsplit {
-			olsplit[i] = strings.TrimSpace(olsplit[i])
-			ctx.Trie.Remove(olsplit[i], oldUser.ID)
+		user, err := ctx.UserStore.Update(r.Context(), id, &update)
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
 		}
 
-		newfirstname := strings.ToLower(user.FirstName)
-		nfsplit := strings.Split(newfirstname, " ")
-		for i := range nfsplit {
-			nfsplit[i] = strings.TrimSpace(nfsplit[i])
-			ctx.Trie.Add(nfsplit[i], user.ID)
-		}
-		newlastname := strings.ToLower(user.LastName)
-		nlsplit := strings.Split(newlastname, " ")
-		for i := range nlsplit {
-			nlsplit[i] = strings.TrimSpace(nlsplit[i])
-			ctx.Trie.Add(nlsplit[i], user.ID)
+		//keep the session's copy of the user current, since
+		//it is what gets forwarded to backends in X-User
+		sessionState.User = user
+		if err := ctx.SessionStore.Save(sid, sessionState); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
+		ctx.Indexer.ReindexUser(oldUser, user)
+		ctx.publishUserEvent(events.TypeUserUpdate, user)
+		updateEvent := audit.NewEvent(r, audit.TypeProfileUpdate, user.ID)
+		updateEvent.Detail = updatedFields(&update)
+		ctx.recordAudit(updateEvent)
+
 		w.WriteHeader(http.StatusOK)
 		w.Header().Add("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(user)
@@ -208,18 +320,28 @@ func (ctx *HandlerCtx) SessionsHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		user, err := ctx.UserStore.GetByEmail(cred.Email)
-		if err != nil {
+		user, err := ctx.UserStore.GetByEmail(r.Context(), cred.Email)
+		if err == users.ErrUserNotFound {
+			ctx.recordSignIn(r, nil, cred.Email, signInUnknownEmail)
 			http.Error(w, "invalid credentials", http.StatusUnauthorized)
 			return
 		}
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
 		err = user.Authenticate(cred.Password)
 		if err != nil {
+			ctx.recordSignIn(r, user, cred.Email, signInWrongPassword)
 			http.Error(w, "invalid credentials", http.StatusUnauthorized)
 			return
 		}
-		sessionState := &SessionState{time.Now(), user}
-		sid, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, sessionState, w)
+		if user.Suspended {
+			ctx.recordSignIn(r, user, cred.Email, signInSuspended)
+			http.Error(w, "account is suspended", http.StatusForbidden)
+			return
+		}
+		sid, err := ctx.beginSession(user, w)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -228,6 +350,7 @@ func (ctx *HandlerCtx) SessionsHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		ctx.recordSignIn(r, user, cred.Email, "")
 		w.WriteHeader(http.StatusCreated)
 		w.Header().Add("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(user)
@@ -250,14 +373,118 @@ func (ctx *HandlerCtx) SpecificSessionHandler(w http.ResponseWriter, r *http.Req
 			http.Error(w, "status forbidden", http.StatusForbidden)
 			return
 		}
+		sessionState := &SessionState{}
+		if _, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, sessionState); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		_, err := sessions.EndSession(r, ctx.SigningKey, ctx.SessionStore)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		ctx.recordAudit(audit.NewEvent(r, audit.TypeSignOut, sessionState.User.ID))
 		w.Write([]byte("signed out"))
 	} else {
 		http.Error(w, "http method must be DELETE", http.StatusMethodNotAllowed)
 		return
 	}
 }
+
+//PasswordHandler handles requests to change the authenticated user's password
+func (ctx *HandlerCtx) PasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "http method must be PUT", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionState := &SessionState{}
+	_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	contentType := r.Header.Get("Content-type")
+	if contentType != "application/json" {
+		http.Error(w, "request body must be in JSON", http.StatusUnsupportedMediaType)
+		return
+	}
+	change := users.PasswordChange{}
+	err = json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := change.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := ctx.getFreshUser(r.Context(), sessionState.User.ID)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	if err := user.Authenticate(change.CurrentPassword); err != nil {
+		ctx.recordAudit(audit.NewEvent(r, audit.TypePasswordChangeFailed, user.ID))
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if err := user.SetPassword(change.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user, err = ctx.UserStore.UpdatePassword(r.Context(), user.ID, user.PassHash)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+
+	//changing the password signs out every other session
+	if err := ctx.SessionStore.DeleteUserSessions(user.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.recordAudit(audit.NewEvent(r, audit.TypePasswordChange, user.ID))
+	if _, err := ctx.beginSession(user, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+//beginSession begins a new session for the user and records it as
+//one of the user's sessions, so that it can be ended by an administrator
+func (ctx *HandlerCtx) beginSession(user *users.User, w http.ResponseWriter) (sessions.SessionID, error) {
+	sessionState := &SessionState{time.Now(), user, user.PasswordResetRequired}
+	sid, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, sessionState, w)
+	if err != nil {
+		return sessions.InvalidSessionID, err
+	}
+	if err := ctx.SessionStore.AddUserSession(user.ID, sid); err != nil {
+		return sessions.InvalidSessionID, err
+	}
+	return sid, nil
+}
+
+//parseFuzzyParams reads the "fuzzy" and "distance" query string
+//parameters of a user search, returning a distance of -1 if it
+//should be chosen for each word of the query by its length
+func parseFuzzyParams(r *http.Request) (bool, int, error) {
+	values := r.URL.Query()
+	if len(values.Get("fuzzy")) == 0 {
+		return false, -1, nil
+	}
+	fuzzy, err := strconv.ParseBool(values.Get("fuzzy"))
+	if err != nil {
+		return false, -1, fmt.Errorf("fuzzy must be true or false")
+	}
+	distance := -1
+	if s := values.Get("distance"); len(s) > 0 {
+		distance, err = strconv.Atoi(s)
+		if err != nil || distance < 0 || distance > maxFuzzyDistance {
+			return false, -1, fmt.Errorf("distance must be an integer between 0 and %d", maxFuzzyDistance)
+		}
+	}
+	return fuzzy, distance, nil
+}
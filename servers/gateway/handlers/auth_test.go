@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
+)
+
+//newTestContext returns a HandlerCtx backed by in-memory stores
+func newTestContext() *HandlerCtx {
+	auditStore := audit.NewMemStore()
+	return &HandlerCtx{
+		SigningKey:   "test key",
+		SessionStore: sessions.NewMemStore(time.Hour, time.Minute),
+		UserStore:    users.NewMemStore(),
+		Indexer:      indexes.NewIndexer(indexes.NewTrie()),
+		AuditSink:    auditStore,
+		AuditStore:   auditStore,
+	}
+}
+
+func jsonRequest(method string, target string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestSignUpAndSignIn(t *testing.T) {
+	ctx := newTestContext()
+	signUp := `{"email": "test@example.com", "password": "password1234", "passwordConf": "password1234",
+		"userName": "tester", "firstName": "Test", "lastName": "User"}`
+
+	cases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		req      *http.Request
+		expected int
+	}{
+		{"Sign Up", ctx.UsersHandler, jsonRequest(http.MethodPost, "/v1/users", signUp), http.StatusCreated},
+		{"Duplicate User Name", ctx.UsersHandler, jsonRequest(http.MethodPost, "/v1/users",
+			strings.Replace(signUp, "test@example.com", "other@example.com", 1)), http.StatusConflict},
+		{"Duplicate Email", ctx.UsersHandler, jsonRequest(http.MethodPost, "/v1/users",
+			strings.Replace(signUp, `"tester"`, `"other"`, 1)), http.StatusConflict},
+		{"Unknown Email", ctx.SessionsHandler, jsonRequest(http.MethodPost, "/v1/sessions",
+			`{"email": "nobody@example.com", "password": "password1234"}`), http.StatusUnauthorized},
+		{"Wrong Password", ctx.SessionsHandler, jsonRequest(http.MethodPost, "/v1/sessions",
+			`{"email": "test@example.com", "password": "wrongpassword"}`), http.StatusUnauthorized},
+		{"Sign In", ctx.SessionsHandler, jsonRequest(http.MethodPost, "/v1/sessions",
+			`{"email": "test@example.com", "password": "password1234"}`), http.StatusCreated},
+	}
+
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		c.handler(rr, c.req)
+		if rr.Code != c.expected {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d (%s)", c.name, c.expected, rr.Code, rr.Body.String())
+		}
+		if c.expected == http.StatusCreated && len(rr.Header().Get("Authorization")) == 0 {
+			t.Errorf("case %s: expected a session token", c.name)
+		}
+	}
+}
+
+func TestGetMissingUser(t *testing.T) {
+	ctx := newTestContext()
+	user, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "test@example.com", UserName: "tester"})
+	if err != nil {
+		t.Fatalf("error inserting user: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	if _, err := ctx.beginSession(user, rr); err != nil {
+		t.Fatalf("error beginning session: %v", err)
+	}
+	token := rr.Header().Get("Authorization")
+	if err := ctx.UserStore.Delete(context.Background(), user.ID); err != nil {
+		t.Fatalf("error deleting user: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+	req.Header.Set("Authorization", token)
+	rr = httptest.NewRecorder()
+	ctx.SpecificUserHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("incorrect status code: expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestFuzzyUserSearch(t *testing.T) {
+	ctx := newTestContext()
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	signUp := `{"email": "john@example.com", "password": "password1234", "passwordConf": "password1234",
+		"userName": "johnny", "firstName": "John", "lastName": "Smith"}`
+	rr := httptest.NewRecorder()
+	ctx.UsersHandler(rr, jsonRequest(http.MethodPost, "/v1/users", signUp))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("error signing up: %d %s", rr.Code, rr.Body.String())
+	}
+	token := rr.Header().Get("Authorization")
+
+	cases := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedFound int
+	}{
+		{"Exact Prefix", "q=joh", http.StatusOK, 1},
+		{"Typo Without Fuzzy", "q=jonh", http.StatusOK, 0},
+		{"Typo", "q=jonh&fuzzy=true", http.StatusOK, 1},
+		{"Uppercase Typo", "q=SMIHT&fuzzy=true", http.StatusOK, 1},
+		{"Too Many Typos", "q=jxyh&fuzzy=true&distance=1", http.StatusOK, 0},
+		{"Invalid Fuzzy", "q=jonh&fuzzy=maybe", http.StatusBadRequest, 0},
+		{"Invalid Distance", "q=jonh&fuzzy=true&distance=5", http.StatusBadRequest, 0},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users?"+c.query, nil)
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		ctx.UsersHandler(rr, req)
+		if rr.Code != c.expectedCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d", c.name, c.expectedCode, rr.Code)
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+		page := &searchPage{}
+		if err := json.NewDecoder(rr.Body).Decode(page); err != nil {
+			t.Fatalf("case %s: error decoding users: %v", c.name, err)
+		}
+		found := page.Users
+		if len(found) != c.expectedFound {
+			t.Errorf("case %s: expected %d users but got %d", c.name, c.expectedFound, len(found))
+		}
+	}
+}
+
+func TestUserSearchNormalization(t *testing.T) {
+	ctx := newTestContext()
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	signUp := `{"email": "jose@example.com", "password": "password1234", "passwordConf": "password1234",
+		"userName": "jose_luis", "firstName": "José", "lastName": "García-Márquez"}`
+	rr := httptest.NewRecorder()
+	ctx.UsersHandler(rr, jsonRequest(http.MethodPost, "/v1/users", signUp))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("error signing up: %d %s", rr.Code, rr.Body.String())
+	}
+	token := rr.Header().Get("Authorization")
+
+	cases := []struct {
+		query         string
+		expectedFound int
+	}{
+		{"jose", 1},
+		{"JOSÉ", 1},
+		{"marquez", 1},
+		{"garcia-marq", 1},
+		{"jose garcia", 1},
+		{"jose smith", 0},
+		{"luis", 1},
+		{"--", 0},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users?q="+url.QueryEscape(c.query), nil)
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		ctx.UsersHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("query %q: incorrect status code: expected %d but got %d", c.query, http.StatusOK, rr.Code)
+			continue
+		}
+		page := &searchPage{}
+		if err := json.NewDecoder(rr.Body).Decode(page); err != nil {
+			t.Fatalf("query %q: error decoding users: %v", c.query, err)
+		}
+		found := page.Users
+		if len(found) != c.expectedFound {
+			t.Errorf("query %q: expected %d users but got %d", c.query, c.expectedFound, len(found))
+		}
+	}
+}
+
+func TestUserSearchPages(t *testing.T) {
+	ctx := newTestContext()
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	var searcher *users.User
+	for i := 0; i < 7; i++ {
+		user, err := ctx.UserStore.Insert(context.Background(), &users.User{
+			Email: fmt.Sprintf("smith%d@example.com", i), UserName: fmt.Sprintf("smith%d", i), LastName: "Smith"})
+		if err != nil {
+			t.Fatalf("error inserting user: %v", err)
+		}
+		ctx.Indexer.IndexUser(user)
+		searcher = user
+	}
+	rr := httptest.NewRecorder()
+	if _, err := ctx.beginSession(searcher, rr); err != nil {
+		t.Fatalf("error beginning session: %v", err)
+	}
+	token := rr.Header().Get("Authorization")
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users?"+query, nil)
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		ctx.UsersHandler(rr, req)
+		return rr
+	}
+
+	seen := make(map[int64]bool)
+	pages := 0
+	query := "q=smith&limit=3"
+	for {
+		rr := search(query)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("page %d: incorrect status code: expected %d but got %d", pages, http.StatusOK, rr.Code)
+		}
+		page := &searchPage{}
+		if err := json.NewDecoder(rr.Body).Decode(page); err != nil {
+			t.Fatalf("page %d: error decoding users: %v", pages, err)
+		}
+		for _, user := range page.Users {
+			if seen[user.ID] {
+				t.Errorf("page %d: user %d was on an earlier page", pages, user.ID)
+			}
+			seen[user.ID] = true
+		}
+		pages++
+		cursor := page.Next
+		if len(cursor) == 0 {
+			break
+		}
+		if pages > 3 {
+			t.Fatalf("too many pages")
+		}
+		query = "q=smith&limit=3&cursor=" + url.QueryEscape(cursor)
+	}
+	if pages != 3 || len(seen) != 7 {
+		t.Errorf("expected 7 users on 3 pages but got %d users on %d pages", len(seen), pages)
+	}
+
+	//cursors of other queries, or with forged positions, are rejected
+	otherQuery := url.QueryEscape(encodeSearchCursor(&indexes.Cursor{Key: "jones", Value: 1}))
+	noUser := url.QueryEscape(encodeSearchCursor(&indexes.Cursor{Key: "smith", Value: 0}))
+	fuzzyPage := url.QueryEscape(encodeSearchCursor(&indexes.Cursor{Key: "smith", Value: 1}))
+	for _, query := range []string{"q=smith&limit=0", "q=smith&limit=101", "q=smith&limit=ten", "q=smith&cursor=not-a-cursor",
+		"q=smith&cursor=" + otherQuery, "q=smith&cursor=" + noUser, "q=smith&fuzzy=true&cursor=" + fuzzyPage} {
+		if rr := search(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("query %q: incorrect status code: expected %d but got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestContainsUserSearch(t *testing.T) {
+	ctx := newTestContext()
+	ctx.Indexer = indexes.NewIndexer(indexes.NewNGramIndex(indexes.NewTrie()))
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	signUp := `{"email": "gold@example.com", "password": "password1234", "passwordConf": "password1234",
+		"userName": "ggold", "firstName": "Gwen", "lastName": "Goldsmith"}`
+	rr := httptest.NewRecorder()
+	ctx.UsersHandler(rr, jsonRequest(http.MethodPost, "/v1/users", signUp))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("error signing up: %d %s", rr.Code, rr.Body.String())
+	}
+	token := rr.Header().Get("Authorization")
+
+	cases := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedFound int
+	}{
+		{"Prefix Mode", "q=smith", http.StatusOK, 0},
+		{"Contains Mode", "q=smith&mode=contains", http.StatusOK, 1},
+		{"Several Words", "q=gwe+SMITH&mode=contains", http.StatusOK, 1},
+		{"Explicit Prefix Mode", "q=gold&mode=prefix", http.StatusOK, 1},
+		{"Too Short", "q=sm&mode=contains", http.StatusBadRequest, 0},
+		{"Unknown Mode", "q=smith&mode=suffix", http.StatusBadRequest, 0},
+		{"Fuzzy Contains", "q=smith&mode=contains&fuzzy=true", http.StatusBadRequest, 0},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users?"+c.query, nil)
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		ctx.UsersHandler(rr, req)
+		if rr.Code != c.expectedCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d", c.name, c.expectedCode, rr.Code)
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+		page := &searchPage{}
+		if err := json.NewDecoder(rr.Body).Decode(page); err != nil {
+			t.Fatalf("case %s: error decoding users: %v", c.name, err)
+		}
+		found := page.Users
+		if len(found) != c.expectedFound {
+			t.Errorf("case %s: expected %d users but got %d", c.name, c.expectedFound, len(found))
+		}
+	}
+
+	//an index without n-grams can't search substrings
+	ctx.Indexer = indexes.NewIndexer(indexes.NewTrie())
+	req := httptest.NewRequest(http.MethodGet, "/v1/users?q=smith&mode=contains", nil)
+	req.Header.Set("Authorization", token)
+	rr = httptest.NewRecorder()
+	ctx.UsersHandler(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("incorrect status code without n-grams: expected %d but got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
+
+func TestProfileUpdateWithStaleSession(t *testing.T) {
+	ctx := newTestContext()
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	user, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "test@example.com", UserName: "tester", LastName: "Alpha"})
+	if err != nil {
+		t.Fatalf("error inserting user: %v", err)
+	}
+	ctx.Indexer.IndexUser(user)
+	tokens := []string{}
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		if _, err := ctx.beginSession(user, rr); err != nil {
+			t.Fatalf("error beginning session: %v", err)
+		}
+		tokens = append(tokens, rr.Header().Get("Authorization"))
+	}
+
+	//the second session's copy of the user still has the first last name
+	for i, lastName := range []string{"Beta", "Gamma"} {
+		req := jsonRequest(http.MethodPatch, "/v1/users/me", `{"lastName": "`+lastName+`"}`)
+		req.Header.Set("Authorization", tokens[i])
+		rr := httptest.NewRecorder()
+		ctx.SpecificUserHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("error changing last name to %s: %d %s", lastName, rr.Code, rr.Body.String())
+		}
+	}
+	if found := ctx.Indexer.Search("beta", 10, nil); len(found) != 0 {
+		t.Errorf("the replaced last name is still indexed: %v", found)
+	}
+	if found := ctx.Indexer.Search("gamma", 10, nil); len(found) != 1 {
+		t.Errorf("the new last name isn't indexed: %v", found)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/avatars"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/blobs"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+)
+
+const avatarsPath = "/v1/avatars/"
+
+//AvatarUploadHandler handles requests to replace the authenticated
+//user's avatar. The image may be sent either as the request body with
+//an image content type, or as the "avatar" field of a multipart form.
+func (ctx *HandlerCtx) AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "http method must be PUT", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionState := &SessionState{}
+	sid, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	//allow a little extra room for the multipart encoding
+	r.Body = http.MaxBytesReader(w, r.Body, avatars.MaxUploadSize+64<<10)
+	var body io.Reader
+	contentType := r.Header.Get("Content-type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, _, err := r.FormFile("avatar")
+		if err != nil {
+			http.Error(w, "request must include an \"avatar\" file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	} else if strings.HasPrefix(contentType, "image/") {
+		body = r.Body
+	} else {
+		http.Error(w, "request body must be an image or multipart form", http.StatusUnsupportedMediaType)
+		return
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, avatars.MaxUploadSize+1))
+	if err != nil {
+		http.Error(w, avatars.ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	thumbs, err := avatars.Thumbnails(data)
+	if err == avatars.ErrUnsupportedType {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err == avatars.ErrTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := sessionState.User.ID
+	for size, thumb := range thumbs {
+		if err := ctx.BlobStore.Put(avatars.Key(userID, size), thumb); err != nil {
+			http.Error(w, "error storing avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+	//the version parameter makes clients fetch the new image
+	//instead of using a cached copy of the old one
+	photoURL := fmt.Sprintf("%s%d/%d.png?v=%d", avatarsPath, userID, avatars.DefaultSize, time.Now().Unix())
+	user, err := ctx.UserStore.UpdatePhotoURL(r.Context(), userID, photoURL)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	ctx.publishUserEvent(events.TypeUserUpdate, user)
+
+	sessionState.User = user
+	if err := ctx.SessionStore.Save(sid, sessionState); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+//AvatarHandler serves avatar thumbnails from paths of the form
+//"/v1/avatars/{userID}/{size}.png". Avatars are public so that they
+//can be used directly as image sources.
+func (ctx *HandlerCtx) AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "http method must be GET", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, avatarsPath), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	size, err := strconv.Atoi(strings.TrimSuffix(parts[1], ".png"))
+	if err != nil || !validAvatarSize(size) {
+		http.NotFound(w, r)
+		return
+	}
+
+	blob, err := ctx.BlobStore.Get(avatars.Key(userID, size))
+	if err == blobs.ErrBlobNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error reading avatar", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+	w.Header().Add("Content-Type", "image/png")
+	w.Header().Add("Cache-Control", "public, max-age=86400")
+	io.Copy(w, blob)
+}
+
+//deleteAvatar deletes all of the user's avatar thumbnails
+func (ctx *HandlerCtx) deleteAvatar(userID int64) error {
+	for _, size := range avatars.Sizes {
+		if err := ctx.BlobStore.Delete(avatars.Key(userID, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validAvatarSize(size int) bool {
+	for _, s := range avatars.Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/blocks"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/contacts"
+)
+
+//BlockRequest identifies the user to block or unblock
+type BlockRequest struct {
+	UserID int64 `json:"userID"`
+}
+
+//BlockList is forwarded to backends in the X-User-Blocks header
+//so that they can enforce blocks between users
+type BlockList struct {
+	//Blocked are the users the authenticated user has blocked
+	Blocked []int64 `json:"blocked"`
+	//BlockedBy are the users who have blocked the authenticated user
+	BlockedBy []int64 `json:"blockedBy"`
+}
+
+//BlocksHandler handles requests for the authenticated user's block list.
+//GET lists the blocked users, POST blocks a user and DELETE unblocks one.
+func (ctx *HandlerCtx) BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	userID := sessionState.User.ID
+
+	if r.Method == http.MethodGet {
+		ids, err := ctx.BlockStore.GetBlocked(userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		blocked, err := ctx.UserStore.GetByIDs(r.Context(), ids)
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(blocked)
+	} else if r.Method == http.MethodPost || r.Method == http.MethodDelete {
+		contentType := r.Header.Get("Content-type")
+		if contentType != "application/json" {
+			http.Error(w, "request body must be in JSON", http.StatusUnsupportedMediaType)
+			return
+		}
+		req := BlockRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := ctx.BlockStore.Delete(userID, req.UserID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte("user unblocked"))
+			return
+		}
+
+		target, err := ctx.UserStore.GetByID(r.Context(), req.UserID)
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		err = ctx.BlockStore.Insert(userID, target.ID)
+		if err == blocks.ErrBlockSelf {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		//blocking a user also ends any contact or pending request with them
+		if err := ctx.ContactStore.Remove(userID, target.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ctx.ContactStore.DeleteRequest(userID, target.ID); err != nil && err != contacts.ErrRequestNotFound {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ctx.ContactStore.DeleteRequest(target.ID, userID); err != nil && err != contacts.ErrRequestNotFound {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(target)
+	} else {
+		http.Error(w, "http method must be GET, POST or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//GetBlockList returns the users blocked by and blocking the given user
+func (ctx *HandlerCtx) GetBlockList(userID int64) (*BlockList, error) {
+	blocked, err := ctx.BlockStore.GetBlocked(userID)
+	if err != nil {
+		return nil, err
+	}
+	blockedBy, err := ctx.BlockStore.GetBlockers(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockList{blocked, blockedBy}, nil
+}
+
+//excluded returns the set of users that should be hidden from the
+//user with this block list, because either of them blocked the other
+func (bl *BlockList) excluded() map[int64]bool {
+	excluded := make(map[int64]bool, len(bl.Blocked)+len(bl.BlockedBy))
+	for _, id := range bl.Blocked {
+		excluded[id] = true
+	}
+	for _, id := range bl.BlockedBy {
+		excluded[id] = true
+	}
+	return excluded
+}
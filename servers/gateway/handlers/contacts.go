@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/contacts"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//NewContactRequest identifies the user to send a contact request to
+type NewContactRequest struct {
+	UserID int64 `json:"userID"`
+}
+
+//ContactRequest is a pending contact request along
+//with the other user involved in it
+type ContactRequest struct {
+	User      *users.User `json:"user"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+//ContactRequests are the authenticated user's pending contact requests
+type ContactRequests struct {
+	Incoming []*ContactRequest `json:"incoming"`
+	Outgoing []*ContactRequest `json:"outgoing"`
+}
+
+//ContactEvent is sent over the websocket when a user
+//receives a contact request or has one accepted
+type ContactEvent struct {
+	Type    string      `json:"type"`
+	User    *users.User `json:"user"`
+	UserIDs []int64     `json:"userIDs"`
+}
+
+//ContactsHandler handles requests to list the authenticated user's contacts
+func (ctx *HandlerCtx) ContactsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "http method must be GET", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	ids, err := ctx.ContactStore.GetContacts(sessionState.User.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	found, err := ctx.UserStore.GetByIDs(r.Context(), ids)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(found)
+}
+
+//SpecificContactHandler handles requests to remove one
+//of the authenticated user's contacts
+func (ctx *HandlerCtx) SpecificContactHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "http method must be DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	contactID, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if err := ctx.ContactStore.Remove(sessionState.User.ID, contactID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("contact removed"))
+}
+
+//ContactRequestsHandler handles requests for the authenticated user's
+//contact requests. GET lists pending requests and POST sends a new one.
+func (ctx *HandlerCtx) ContactRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	me := sessionState.User
+
+	if r.Method == http.MethodGet {
+		incoming, outgoing, err := ctx.ContactStore.GetRequests(me.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		requests := &ContactRequests{}
+		if requests.Incoming, err = ctx.toContactRequests(r.Context(), incoming, true); err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		if requests.Outgoing, err = ctx.toContactRequests(r.Context(), outgoing, false); err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(requests)
+	} else if r.Method == http.MethodPost {
+		contentType := r.Header.Get("Content-type")
+		if contentType != "application/json" {
+			http.Error(w, "request body must be in JSON", http.StatusUnsupportedMediaType)
+			return
+		}
+		req := NewContactRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		target, err := ctx.UserStore.GetByID(r.Context(), req.UserID)
+		if err != nil {
+			http.Error(w, err.Error(), userStoreStatus(err))
+			return
+		}
+		blockList, err := ctx.GetBlockList(me.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if blockList.excluded()[target.ID] {
+			http.Error(w, "contact requests are blocked between these users", http.StatusForbidden)
+			return
+		}
+
+		accepted, err := ctx.ContactStore.Request(me.ID, target.ID)
+		if err == contacts.ErrRequestSelf || err == contacts.ErrAlreadyContacts {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		eventType := "contact-request"
+		if accepted {
+			eventType = "contact-accept"
+		}
+		ctx.notifyContact(eventType, me, target.ID)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(target)
+	} else {
+		http.Error(w, "http method must be GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//SpecificContactRequestHandler handles requests for the contact request
+//between the authenticated user and the user in the path. POST accepts an
+//incoming request, and DELETE declines an incoming or cancels an outgoing one.
+func (ctx *HandlerCtx) SpecificContactRequestHandler(w http.ResponseWriter, r *http.Request) {
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	me := sessionState.User
+	otherID, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		err = ctx.ContactStore.Accept(me.ID, otherID)
+		if err == contacts.ErrRequestNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx.notifyContact("contact-accept", me, otherID)
+		w.Write([]byte("contact request accepted"))
+	} else if r.Method == http.MethodDelete {
+		err = ctx.ContactStore.DeleteRequest(otherID, me.ID)
+		if err == contacts.ErrRequestNotFound {
+			err = ctx.ContactStore.DeleteRequest(me.ID, otherID)
+		}
+		if err == contacts.ErrRequestNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("contact request deleted"))
+	} else {
+		http.Error(w, "http method must be POST or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//toContactRequests pairs each request with the other user involved,
+//which is the sender for incoming requests and the recipient otherwise
+func (ctx *HandlerCtx) toContactRequests(c context.Context, requests []*contacts.Request, incoming bool) ([]*ContactRequest, error) {
+	otherID := func(req *contacts.Request) int64 {
+		if incoming {
+			return req.FromID
+		}
+		return req.ToID
+	}
+	ids := make([]int64, len(requests))
+	for i, req := range requests {
+		ids[i] = otherID(req)
+	}
+	found, err := ctx.UserStore.GetByIDs(c, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*users.User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+
+	result := []*ContactRequest{}
+	for _, req := range requests {
+		if user, ok := byID[otherID(req)]; ok {
+			result = append(result, &ContactRequest{user, req.CreatedAt})
+		}
+	}
+	return result, nil
+}
+
+//notifyContact tells the recipient over their websocket that
+//the user sent them a contact request or accepted theirs
+func (ctx *HandlerCtx) notifyContact(eventType string, user *users.User, recipientID int64) {
+	if ctx.Notifier == nil {
+		return
+	}
+	ctx.Notifier.Notify(&ContactEvent{eventType, user, []int64{recipientID}}, recipientID)
+}
@@ -1,7 +1,16 @@
 package handlers
 
 import (
+	"context"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/blobs"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/exports"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/blocks"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/contacts"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/invites"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
 )
@@ -11,13 +20,25 @@ type HandlerCtx struct {
 	SigningKey   string
 	SessionStore sessions.Store
 	UserStore    users.Store
-	Trie         *indexes.Trie
+	Indexer      *indexes.Indexer
 	Notifier     *Notifier
+	BlobStore    blobs.Store
+	BlockStore   blocks.Store
+	ContactStore contacts.Store
+	Publisher    events.Publisher
+	Exporter     *exports.Exporter
+	InviteStore  invites.Store
+	SignupPolicy *invites.Policy
+	//AuditSink is where security events are recorded, and
+	//AuditStore is the audit log they're queried from
+	AuditSink  audit.Sink
+	AuditStore audit.Store
 }
 
 //NewHandlerContext constructs a new HandlerCtx,
 //ensuring that the dependencies are valid values
-func NewHandlerContext(signingKey string, sessionStore sessions.Store, userStore users.Store, trie *indexes.Trie, notifier *Notifier) *HandlerCtx {
+func NewHandlerContext(signingKey string, sessionStore sessions.Store, userStore users.Store, indexer *indexes.Indexer, notifier *Notifier, blobStore blobs.Store, blockStore blocks.Store, contactStore contacts.Store, publisher events.Publisher, exporter *exports.Exporter,
+	inviteStore invites.Store, signupPolicy *invites.Policy, auditSink audit.Sink, auditStore audit.Store) *HandlerCtx {
 	if len(signingKey) == 0 {
 		panic("nil signing key")
 	}
@@ -27,8 +48,44 @@ func NewHandlerContext(signingKey string, sessionStore sessions.Store, userStore
 	if userStore == nil {
 		panic("nil user store")
 	}
-	if trie.Root == nil || trie.Size != 0 {
-		panic("nil trie")
+	if indexer == nil {
+		panic("nil indexer")
+	}
+	if blobStore == nil {
+		panic("nil blob store")
+	}
+	if blockStore == nil {
+		panic("nil block store")
+	}
+	if contactStore == nil {
+		panic("nil contact store")
+	}
+	if publisher == nil {
+		panic("nil publisher")
+	}
+	if exporter == nil {
+		panic("nil exporter")
 	}
-	return &HandlerCtx{signingKey, sessionStore, userStore, trie, notifier}
+	if inviteStore == nil {
+		panic("nil invite store")
+	}
+	if signupPolicy == nil {
+		panic("nil signup policy")
+	}
+	if auditSink == nil {
+		panic("nil audit sink")
+	}
+	if auditStore == nil {
+		panic("nil audit store")
+	}
+	return &HandlerCtx{signingKey, sessionStore, userStore, indexer, notifier, blobStore, blockStore, contactStore,
+		publisher, exporter, inviteStore, signupPolicy, auditSink, auditStore}
+}
+
+//getFreshUser returns the user with the given ID as it is stored now,
+//bypassing any cache. Checks of what a user is allowed to do, such as
+//whether they're an administrator or what their password is, use it
+//so that changes made through other gateways take effect immediately.
+func (ctx *HandlerCtx) getFreshUser(c context.Context, id int64) (*users.User, error) {
+	return ctx.UserStore.GetByIDFresh(c, id)
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/invites"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//userStoreStatus returns the HTTP status code to
+//respond with for an error from the users.Store
+func userStoreStatus(err error) int {
+	switch {
+	case errors.Is(err, users.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, users.ErrDuplicateEmail), errors.Is(err, users.ErrDuplicateUserName):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+//inviteStoreStatus returns the HTTP status code to
+//respond with for an error from the invites.Store
+func inviteStoreStatus(err error) int {
+	switch {
+	case errors.Is(err, invites.ErrInviteNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, invites.ErrInviteExpired), errors.Is(err, invites.ErrInviteUsedUp):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/invites"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+func TestUserStoreStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{users.ErrUserNotFound, http.StatusNotFound},
+		{users.ErrDuplicateEmail, http.StatusConflict},
+		{users.ErrDuplicateUserName, http.StatusConflict},
+		{fmt.Errorf("error getting user: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{fmt.Errorf("some error"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if status := userStoreStatus(c.err); status != c.status {
+			t.Errorf("incorrect status for %v: expected %d but got %d", c.err, c.status, status)
+		}
+	}
+}
+
+func TestInviteStoreStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{invites.ErrInviteNotFound, http.StatusNotFound},
+		{fmt.Errorf("error redeeming invite: %w", invites.ErrInviteNotFound), http.StatusNotFound},
+		{invites.ErrInviteExpired, http.StatusForbidden},
+		{fmt.Errorf("error redeeming invite: %w", invites.ErrInviteUsedUp), http.StatusForbidden},
+		{fmt.Errorf("some error"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if status := inviteStoreStatus(c.err); status != c.status {
+			t.Errorf("incorrect status for %v: expected %d but got %d", c.err, c.status, status)
+		}
+	}
+}
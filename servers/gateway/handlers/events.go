@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//publishUserEvent publishes an event of the given type about the user.
+//The change has already been made, so failing to publish is logged
+//rather than reported to the client.
+func (ctx *HandlerCtx) publishUserEvent(eventType string, user *users.User) {
+	if ctx.Publisher == nil {
+		return
+	}
+	if err := ctx.Publisher.Publish(events.NewUserEvent(eventType, user)); err != nil {
+		log.Printf("Error publishing %s event for user %d: %s", eventType, user.ID, err.Error())
+	}
+}
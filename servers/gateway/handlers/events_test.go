@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+)
+
+//recordingPublisher records the events published to it
+type recordingPublisher struct {
+	events []*events.UserEvent
+}
+
+func (rp *recordingPublisher) Publish(event *events.UserEvent) error {
+	rp.events = append(rp.events, event)
+	return nil
+}
+
+func TestUserEventsPublished(t *testing.T) {
+	ctx := newTestContext()
+	publisher := &recordingPublisher{}
+	ctx.Publisher = publisher
+
+	rr := httptest.NewRecorder()
+	ctx.UsersHandler(rr, jsonRequest(http.MethodPost, "/v1/users", `{"email": "test@example.com", "password": "password1234",
+		"passwordConf": "password1234", "userName": "tester", "firstName": "Test", "lastName": "User"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("incorrect status code signing up: expected %d but got %d (%s)", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	req := jsonRequest(http.MethodPatch, "/v1/users/me", `{"firstName": "New", "lastName": "Name"}`)
+	req.Header.Set("Authorization", rr.Header().Get("Authorization"))
+	rr = httptest.NewRecorder()
+	ctx.SpecificUserHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("incorrect status code updating: expected %d but got %d (%s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if len(publisher.events) != 2 {
+		t.Fatalf("incorrect number of events: expected 2 but got %d", len(publisher.events))
+	}
+	if event := publisher.events[0]; event.Type != events.TypeUserNew || event.User.UserName != "tester" {
+		t.Errorf("incorrect sign up event: %+v", event)
+	}
+	if event := publisher.events[1]; event.Type != events.TypeUserUpdate || event.User.FirstName != "New" || event.User.LastName != "Name" {
+		t.Errorf("incorrect update event: %+v", event)
+	}
+}
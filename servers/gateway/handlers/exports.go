@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/blobs"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/exports"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
+)
+
+const exportPath = "/v1/users/me/export"
+
+//exportTimeout is how long an export may take before it fails
+const exportTimeout = 5 * time.Minute
+
+//ExportEvent is sent over the websocket when
+//the user's export is ready or has failed
+type ExportEvent struct {
+	Type    string  `json:"type"`
+	URL     string  `json:"url,omitempty"`
+	UserIDs []int64 `json:"userIDs"`
+}
+
+//ExportStatus is returned when an export is started
+type ExportStatus struct {
+	Status string `json:"status"`
+	URL    string `json:"url"`
+}
+
+//exportedSession is the part of a session included in an
+//export. Session IDs are credentials, so they're left out.
+type exportedSession struct {
+	SessionBegin time.Time `json:"sessionBegin"`
+}
+
+//exportedRequest is a pending contact request included in an export
+type exportedRequest struct {
+	FromID    int64     `json:"fromID"`
+	ToID      int64     `json:"toID"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//exportedContacts are the contacts and requests included in an export
+type exportedContacts struct {
+	Contacts []int64            `json:"contacts"`
+	Incoming []*exportedRequest `json:"incoming"`
+	Outgoing []*exportedRequest `json:"outgoing"`
+}
+
+//exportedProfile is the user's profile in their
+//export, which includes their email address
+type exportedProfile struct {
+	*users.User
+	Email string `json:"email"`
+}
+
+//ExportHandler handles requests for the authenticated user's data export.
+//POST starts assembling a ZIP of everything held about the user, and the
+//user is told over the websocket when it's ready. GET downloads the
+//latest export.
+func (ctx *HandlerCtx) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	sessionState := &SessionState{}
+	_, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	userID := sessionState.User.ID
+
+	if r.Method == http.MethodPost {
+		if !ctx.Exporter.Start(userID) {
+			http.Error(w, "an export is already in progress", http.StatusConflict)
+			return
+		}
+		go ctx.runExport(userID)
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&ExportStatus{"pending", exportPath})
+	} else if r.Method == http.MethodGet {
+		archive, err := ctx.BlobStore.Get(exports.Key(userID))
+		if err == blobs.ErrBlobNotFound {
+			http.Error(w, "no export has been made", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer archive.Close()
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="export.zip"`)
+		w.Header().Set("Cache-Control", "no-store")
+		io.Copy(w, archive)
+	} else {
+		http.Error(w, "http method must be GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//runExport assembles and stores the user's export,
+//then tells the user whether it succeeded
+func (ctx *HandlerCtx) runExport(userID int64) {
+	defer ctx.Exporter.Finish(userID)
+	c, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	event := &ExportEvent{"export-ready", exportPath, []int64{userID}}
+	if err := ctx.buildExport(c, userID); err != nil {
+		log.Printf("Error exporting user %d: %s", userID, err.Error())
+		event = &ExportEvent{"export-failed", "", []int64{userID}}
+	}
+	if ctx.Notifier != nil {
+		ctx.Notifier.Notify(event, userID)
+	}
+}
+
+//buildExport assembles a ZIP of the data held about the user,
+//by the gateway and the backends, and stores it as their export
+func (ctx *HandlerCtx) buildExport(c context.Context, userID int64) error {
+	user, err := ctx.UserStore.GetByID(c, userID)
+	if err != nil {
+		return fmt.Errorf("error getting user: %v", err)
+	}
+	pastNames, err := ctx.UserStore.GetPastUserNames(c, userID)
+	if err != nil {
+		return fmt.Errorf("error getting past user names: %v", err)
+	}
+	sessionList, err := ctx.exportSessions(userID)
+	if err != nil {
+		return err
+	}
+	blocked, err := ctx.BlockStore.GetBlocked(userID)
+	if err != nil {
+		return fmt.Errorf("error getting blocks: %v", err)
+	}
+	contactList, err := ctx.exportContacts(userID)
+	if err != nil {
+		return err
+	}
+	securityEvents, err := ctx.exportSecurityEvents(userID)
+	if err != nil {
+		return err
+	}
+
+	archive := exports.NewArchive()
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", &exportedProfile{user, user.Email}},
+		{"past_user_names.json", pastNames},
+		{"sessions.json", sessionList},
+		{"blocks.json", blocked},
+		{"contacts.json", contactList},
+		{"security_events.json", securityEvents},
+	}
+	for _, file := range files {
+		if err := archive.AddJSON(file.name, file.v); err != nil {
+			return err
+		}
+	}
+
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("error encoding user: %v", err)
+	}
+	if err := ctx.Exporter.FetchBackends(c, archive, userJSON); err != nil {
+		return err
+	}
+
+	data, err := archive.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := ctx.BlobStore.Put(exports.Key(userID), data); err != nil {
+		return fmt.Errorf("error storing export: %v", err)
+	}
+	return nil
+}
+
+//exportSessions returns the user's sessions that haven't expired
+func (ctx *HandlerCtx) exportSessions(userID int64) ([]*exportedSession, error) {
+	sids, err := ctx.SessionStore.GetUserSessions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sessions: %v", err)
+	}
+	result := []*exportedSession{}
+	for _, sid := range sids {
+		state := &SessionState{}
+		if err := ctx.SessionStore.Get(sid, state); err == sessions.ErrStateNotFound {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error getting session: %v", err)
+		}
+		result = append(result, &exportedSession{state.SessionBegin})
+	}
+	return result, nil
+}
+
+//exportContacts returns the user's contacts and pending requests
+func (ctx *HandlerCtx) exportContacts(userID int64) (*exportedContacts, error) {
+	ids, err := ctx.ContactStore.GetContacts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contacts: %v", err)
+	}
+	incoming, outgoing, err := ctx.ContactStore.GetRequests(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contact requests: %v", err)
+	}
+	result := &exportedContacts{ids, []*exportedRequest{}, []*exportedRequest{}}
+	for _, req := range incoming {
+		result.Incoming = append(result.Incoming, &exportedRequest{req.FromID, req.ToID, req.CreatedAt})
+	}
+	for _, req := range outgoing {
+		result.Outgoing = append(result.Outgoing, &exportedRequest{req.FromID, req.ToID, req.CreatedAt})
+	}
+	return result, nil
+}
+
+//exportSecurityEvents returns every event about the user in the audit log
+func (ctx *HandlerCtx) exportSecurityEvents(userID int64) ([]*audit.Event, error) {
+	result := []*audit.Event{}
+	for {
+		page, err := ctx.AuditStore.Find(&audit.Query{UserID: userID, Offset: len(result), Limit: maxAuditLimit})
+		if err != nil {
+			return nil, fmt.Errorf("error getting security events: %v", err)
+		}
+		result = append(result, page...)
+		if len(page) < maxAuditLimit {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/blobs"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/exports"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/blocks"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/contacts"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//fakeBlockStore is a blocks.Store where every user has blocked the same users
+type fakeBlockStore struct {
+	blocks.Store
+	blocked []int64
+}
+
+func (fs *fakeBlockStore) GetBlocked(blockerID int64) ([]int64, error) {
+	return fs.blocked, nil
+}
+
+func (fs *fakeBlockStore) GetBlockers(blockedID int64) ([]int64, error) {
+	return nil, nil
+}
+
+//fakeContactStore is a contacts.Store where every
+//user has the same contacts and no requests
+type fakeContactStore struct {
+	contacts.Store
+	contacts []int64
+}
+
+func (fs *fakeContactStore) GetContacts(userID int64) ([]int64, error) {
+	return fs.contacts, nil
+}
+
+func (fs *fakeContactStore) GetRequests(userID int64) ([]*contacts.Request, []*contacts.Request, error) {
+	return nil, nil, nil
+}
+
+func TestExport(t *testing.T) {
+	ctx := newTestContext()
+	blobStore, err := blobs.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("error creating blob store: %v", err)
+	}
+	ctx.BlobStore = blobStore
+	ctx.BlockStore = &fakeBlockStore{blocked: []int64{5}}
+	ctx.ContactStore = &fakeContactStore{contacts: []int64{6}}
+	ctx.Exporter = exports.NewExporter(nil)
+
+	user, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "test@example.com", UserName: "tester"})
+	if err != nil {
+		t.Fatalf("error inserting user: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	if _, err := ctx.beginSession(user, rr); err != nil {
+		t.Fatalf("error beginning session: %v", err)
+	}
+	token := rr.Header().Get("Authorization")
+	request := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, exportPath, nil)
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		ctx.ExportHandler(rr, req)
+		return rr
+	}
+
+	if rr := request(http.MethodGet); rr.Code != http.StatusNotFound {
+		t.Errorf("incorrect status code before exporting: expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+	ctx.Exporter.Start(user.ID)
+	if rr := request(http.MethodPost); rr.Code != http.StatusConflict {
+		t.Errorf("incorrect status code while exporting: expected %d but got %d", http.StatusConflict, rr.Code)
+	}
+	ctx.Exporter.Finish(user.ID)
+
+	if err := ctx.buildExport(context.Background(), user.ID); err != nil {
+		t.Fatalf("unexpected error exporting: %v", err)
+	}
+	rr = request(http.MethodGet)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("incorrect response downloading export: %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("error reading export: %v", err)
+	}
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	expected := []string{"blocks.json", "contacts.json", "past_user_names.json", "profile.json", "security_events.json", "sessions.json"}
+	if len(names) != len(expected) {
+		t.Fatalf("incorrect files in export: expected %v but got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("incorrect files in export: expected %v but got %v", expected, names)
+			break
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/invites"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+const adminInvitesPath = "/v1/admin/invites/"
+
+var errInviteRequired = errors.New("an invite code is required to sign up")
+var errInvalidInvite = errors.New("invalid invite code")
+
+//SignUp is a new user signing up, along with the invite code
+//required when the signup policy doesn't let them sign up freely
+type SignUp struct {
+	users.NewUser
+	InviteCode string `json:"inviteCode,omitempty"`
+}
+
+//InviteDetails is an invite along with the users who redeemed it
+type InviteDetails struct {
+	*invites.Invite
+	Redemptions []*invites.Redemption `json:"redemptions"`
+}
+
+//AdminInvitesHandler handles admin requests to list
+//invites (GET) or create a new invite (POST)
+func (ctx *HandlerCtx) AdminInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := ctx.getAdmin(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		found, err := ctx.InviteStore.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(found)
+	} else if r.Method == http.MethodPost {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			http.Error(w, "request body must be in JSON", http.StatusUnsupportedMediaType)
+			return
+		}
+		ni := &invites.NewInvite{}
+		if err := json.NewDecoder(r.Body).Decode(ni); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ni.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		invite, err := ni.ToInvite(admin.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := ctx.InviteStore.Insert(invite); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(invite)
+	} else {
+		http.Error(w, "http method must be GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//AdminSpecificInviteHandler handles admin requests to view an invite
+//and its redemptions (GET) or revoke it (DELETE)
+func (ctx *HandlerCtx) AdminSpecificInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if !ctx.requireAdmin(w, r) {
+		return
+	}
+	code := invites.NormalizeCode(strings.TrimPrefix(r.URL.Path, adminInvitesPath))
+	if r.Method == http.MethodGet {
+		invite, err := ctx.InviteStore.Get(code)
+		if err != nil {
+			http.Error(w, err.Error(), inviteStoreStatus(err))
+			return
+		}
+		redemptions, err := ctx.InviteStore.GetRedemptions(code)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&InviteDetails{invite, redemptions})
+	} else if r.Method == http.MethodDelete {
+		if err := ctx.InviteStore.Delete(code); err != nil {
+			http.Error(w, err.Error(), inviteStoreStatus(err))
+			return
+		}
+		w.Write([]byte("invite deleted"))
+	} else {
+		http.Error(w, "http method must be GET or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+//checkSignupAllowed returns the normalized invite code to redeem
+//once the new user is inserted, or an error and the HTTP status code
+//to respond with if the signup policy doesn't let the user sign up.
+//The code is empty if the user doesn't need an invite.
+func (ctx *HandlerCtx) checkSignupAllowed(su *SignUp) (string, int, error) {
+	if ctx.SignupPolicy == nil || !ctx.SignupPolicy.RequiresInvite(su.Email) {
+		return "", 0, nil
+	}
+	code := invites.NormalizeCode(su.InviteCode)
+	if len(code) == 0 {
+		return "", http.StatusForbidden, errInviteRequired
+	}
+	invite, err := ctx.InviteStore.Get(code)
+	if err == invites.ErrInviteNotFound {
+		return "", http.StatusForbidden, errInvalidInvite
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	if err := invite.Check(time.Now()); err != nil {
+		return "", http.StatusForbidden, err
+	}
+	return code, 0, nil
+}
+
+//redeemInvite redeems the invite for the newly-inserted user. If the
+//invite can't be redeemed, the user is deleted so that they can try
+//again with another invite, and the HTTP status code is returned.
+func (ctx *HandlerCtx) redeemInvite(c context.Context, code string, user *users.User) (int, error) {
+	if len(code) == 0 {
+		return 0, nil
+	}
+	if err := ctx.InviteStore.Redeem(code, user.ID); err != nil {
+		ctx.UserStore.Delete(c, user.ID)
+		return inviteStoreStatus(err), err
+	}
+	return 0, nil
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/invites"
+)
+
+//fakeInviteStore is an invites.Store holding invites in a map
+type fakeInviteStore struct {
+	invites.Store
+	invites map[string]*invites.Invite
+}
+
+func (fs *fakeInviteStore) Get(code string) (*invites.Invite, error) {
+	invite, ok := fs.invites[code]
+	if !ok {
+		return nil, invites.ErrInviteNotFound
+	}
+	return invite, nil
+}
+
+func (fs *fakeInviteStore) Redeem(code string, userID int64) error {
+	invite, err := fs.Get(code)
+	if err != nil {
+		return err
+	}
+	if err := invite.Check(time.Now()); err != nil {
+		return err
+	}
+	invite.Uses++
+	return nil
+}
+
+func TestInviteOnlySignUp(t *testing.T) {
+	ctx := newTestContext()
+	expired := time.Now().Add(-time.Hour)
+	ctx.InviteStore = &fakeInviteStore{invites: map[string]*invites.Invite{
+		"SINGLE":  {Code: "SINGLE", MaxUses: 1},
+		"EXPIRED": {Code: "EXPIRED", ExpiresAt: &expired},
+	}}
+	ctx.SignupPolicy, _ = invites.ParsePolicy("domains", "example.com")
+
+	signUp := func(name string, email string, inviteCode string) int {
+		body := `{"email": "` + email + `", "password": "password1234", "passwordConf": "password1234",
+			"userName": "` + name + `", "firstName": "Test", "lastName": "User", "inviteCode": "` + inviteCode + `"}`
+		rr := httptest.NewRecorder()
+		ctx.UsersHandler(rr, jsonRequest(http.MethodPost, "/v1/users", body))
+		return rr.Code
+	}
+
+	cases := []struct {
+		name       string
+		email      string
+		inviteCode string
+		expected   int
+	}{
+		{"Allowed Domain", "allowed@example.com", "", http.StatusCreated},
+		{"No Invite", "outsider@gmail.com", "", http.StatusForbidden},
+		{"Unknown Invite", "outsider@gmail.com", "UNKNOWN", http.StatusForbidden},
+		{"Expired Invite", "outsider@gmail.com", "EXPIRED", http.StatusForbidden},
+		{"Invite", "invited@gmail.com", " single ", http.StatusCreated},
+		{"Used Invite", "late@gmail.com", "SINGLE", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		name := strings.Split(c.email, "@")[0]
+		if code := signUp(name, c.email, c.inviteCode); code != c.expected {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d", c.name, c.expected, code)
+		}
+	}
+}
@@ -7,21 +7,63 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/blocks"
 	"github.com/gorilla/websocket"
 	"github.com/streadway/amqp"
 )
 
 type Notifier struct {
 	Connections map[int64]*websocket.Conn
+	BlockStore  blocks.Store
 	lock        sync.Mutex
 }
 
-func NewNotifier() *Notifier {
-	return &Notifier{}
+func NewNotifier(blockStore blocks.Store) *Notifier {
+	return &Notifier{BlockStore: blockStore}
 }
 
 type Message map[string]interface{}
 
+//creatorID returns the ID of the user who created the message or
+//channel that the event is about, or 0 if there is no creator
+func (m Message) creatorID() int64 {
+	for _, key := range []string{"message", "channel"} {
+		obj, ok := m[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		creator, ok := obj["creator"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		//messages identify their creator by "id" and channels by "userId"
+		for _, idKey := range []string{"id", "userId"} {
+			if id, ok := creator[idKey].(float64); ok {
+				return int64(id)
+			}
+		}
+	}
+	return 0
+}
+
+//blockersOf returns the set of users who have blocked the
+//given creator, and so shouldn't be notified of their events
+func (n *Notifier) blockersOf(creatorID int64) map[int64]bool {
+	blockers := make(map[int64]bool)
+	if n.BlockStore == nil || creatorID == 0 {
+		return blockers
+	}
+	ids, err := n.BlockStore.GetBlockers(creatorID)
+	if err != nil {
+		log.Printf("Error getting blockers: %s", err.Error())
+		return blockers
+	}
+	for _, id := range ids {
+		blockers[id] = true
+	}
+	return blockers
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -49,38 +91,86 @@ func (n *Notifier) RemoveConnection(userID int64) {
 	delete(n.Connections, userID)
 }
 
+//CloseConnection closes and removes the websocket
+//connection of the given user, if they have one
+func (n *Notifier) CloseConnection(userID int64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if conn, ok := n.Connections[userID]; ok {
+		conn.Close()
+		delete(n.Connections, userID)
+	}
+}
+
+//Notify sends the event as JSON to the given users over their
+//websocket connections, dropping any connection that fails
+func (n *Notifier) Notify(event interface{}, userIDs ...int64) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding notification: %s", err.Error())
+		return
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, id := range userIDs {
+		conn, ok := n.Connections[id]
+		if !ok {
+			continue
+		}
+		if err := conn.WriteMessage(TextMessage, data); err != nil {
+			log.Printf("Error writing notification: %s", err.Error())
+			conn.Close()
+			delete(n.Connections, id)
+		}
+	}
+}
+
+//WriteToAllConnections writes the data to every connection, dropping
+//any that fail, and returns the first error. The caller must hold the
+//notifier's lock.
 func (n *Notifier) WriteToAllConnections(messageType int, data []byte) error {
 	var writeError error
-	for id, conn := range n.Connections {
-		writeError = conn.WriteMessage(messageType, data)
-		if writeError != nil {
-			n.RemoveConnection(id)
-			conn.Close()
-			return writeError
+	for id := range n.Connections {
+		if err := n.writeTo(id, messageType, data); err != nil && writeError == nil {
+			writeError = err
 		}
 	}
-	return nil
+	return writeError
 }
 
+//WriteToConnection writes the data to the connections of the given
+//users, dropping any that fail, and returns the first error. The
+//caller must hold the notifier's lock.
 func (n *Notifier) WriteToConnection(messageType int, data []byte, userIDs []int64) error {
 	var writeError error
 	for _, id := range userIDs {
-		if conn, ok := n.Connections[id]; ok {
-			writeError = conn.WriteMessage(messageType, data)
-			if writeError != nil {
-				n.RemoveConnection(id)
-				conn.Close()
-				return writeError
-			}
+		if err := n.writeTo(id, messageType, data); err != nil && writeError == nil {
+			writeError = err
 		}
 	}
+	return writeError
+}
+
+//writeTo writes the data to the connection of the given user, if they
+//have one, and closes and deletes it if the write fails. It deletes it
+//itself rather than calling RemoveConnection, since the caller already
+//holds the notifier's lock.
+func (n *Notifier) writeTo(userID int64, messageType int, data []byte) error {
+	conn, ok := n.Connections[userID]
+	if !ok {
+		return nil
+	}
+	if err := conn.WriteMessage(messageType, data); err != nil {
+		conn.Close()
+		delete(n.Connections, userID)
+		return err
+	}
 	return nil
 }
 
 // go routine executed in main for sending message to the proper websockets
 func (n *Notifier) NotifyWebSockets(msgs <-chan amqp.Delivery) {
 	for m := range msgs {
-		n.lock.Lock()
 		log.Printf("Received a message: %s", m.Body)
 
 		var message Message
@@ -90,22 +180,37 @@ func (n *Notifier) NotifyWebSockets(msgs <-chan amqp.Delivery) {
 		if err != nil {
 			log.Printf("Error receiving from queue: %s", err.Error())
 		}
-		if message["userIDs"] == nil {
+		//the blockers are read from the database before
+		//locking, so that a slow query doesn't hold up
+		//connecting and disconnecting clients
+		blockers := n.blockersOf(message.creatorID())
+		n.lock.Lock()
+		if message["userIDs"] == nil && len(blockers) == 0 {
 			err = n.WriteToAllConnections(TextMessage, m.Body)
 			if err != nil {
 				log.Printf("Error writing from queue: %s", err.Error())
 			}
 		} else {
-			idArr := message["userIDs"].([]interface{})
-			convertedIdArr := make([]float64, len(idArr))
-			convertedIdArr2 := make([]int64, len(idArr))
-			for i := range idArr {
-				convertedIdArr[i] = idArr[i].(float64)
+			recipients := []int64{}
+			if message["userIDs"] == nil {
+				for id := range n.Connections {
+					recipients = append(recipients, id)
+				}
+			} else {
+				idArr, _ := message["userIDs"].([]interface{})
+				for i := range idArr {
+					if id, ok := idArr[i].(float64); ok {
+						recipients = append(recipients, int64(id))
+					}
+				}
 			}
-			for i := range idArr {
-				convertedIdArr2[i] = int64(convertedIdArr[i])
+			notified := make([]int64, 0, len(recipients))
+			for _, id := range recipients {
+				if !blockers[id] {
+					notified = append(notified, id)
+				}
 			}
-			err = n.WriteToConnection(TextMessage, m.Body, convertedIdArr2)
+			err = n.WriteToConnection(TextMessage, m.Body, notified)
 			if err != nil {
 				log.Printf("Error writing from queue: %s", err.Error())
 			}
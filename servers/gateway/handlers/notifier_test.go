@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/streadway/amqp"
+)
+
+func TestMessageCreatorID(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		expected int64
+	}{
+		{
+			"New message",
+			`{"type": "message-new", "message": {"creator": {"id": 4, "userName": "rio"}}, "userIDs": null}`,
+			4,
+		},
+		{
+			"New channel",
+			`{"type": "channel-new", "channel": {"creator": {"userId": 7}}, "userIDs": [7, 8]}`,
+			7,
+		},
+		{
+			"Deleted message",
+			`{"type": "message-delete", "messageID": "abc", "userIDs": null}`,
+			0,
+		},
+	}
+
+	for _, c := range cases {
+		var message Message
+		if err := json.Unmarshal([]byte(c.body), &message); err != nil {
+			t.Fatalf("case %s: error decoding message: %v", c.name, err)
+		}
+		if id := message.creatorID(); id != c.expected {
+			t.Errorf("case %s: expected creator %d but got %d", c.name, c.expected, id)
+		}
+	}
+}
+
+//slowBlockStore is a blocks.Store whose GetBlockers waits to be released
+type slowBlockStore struct {
+	fakeBlockStore
+	started chan bool
+	release chan bool
+}
+
+func (ss *slowBlockStore) GetBlockers(blockedID int64) ([]int64, error) {
+	ss.started <- true
+	<-ss.release
+	return nil, nil
+}
+
+func TestNotifyWebSocketsDoesNotLockDuringQueries(t *testing.T) {
+	store := &slowBlockStore{started: make(chan bool), release: make(chan bool)}
+	n := NewNotifier(store)
+	msgs := make(chan amqp.Delivery, 1)
+	msgs <- amqp.Delivery{Body: []byte(`{"type": "message-new", "message": {"creator": {"id": 4}}, "userIDs": [5]}`)}
+	close(msgs)
+	done := make(chan bool)
+	go func() {
+		n.NotifyWebSockets(msgs)
+		done <- true
+	}()
+	<-store.started
+
+	//clients can still disconnect while the blockers are read
+	removed := make(chan bool)
+	go func() {
+		n.RemoveConnection(5)
+		removed <- true
+	}()
+	select {
+	case <-removed:
+	case <-time.After(time.Second):
+		t.Errorf("the notifier is locked while reading blockers")
+	}
+	close(store.release)
+	<-done
+}
+
+//dialNotifier connects a websocket client to a test server, and
+//returns the client's connection and the server's
+func dialNotifier(t *testing.T, server *httptest.Server, conns chan *websocket.Conn) (*websocket.Conn, *websocket.Conn) {
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("error dialing websocket: %v", err)
+	}
+	return client, <-conns
+}
+
+func TestNotifyWebSocketsDropsFailedConnections(t *testing.T) {
+	conns := make(chan *websocket.Conn)
+	testUpgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := testUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("error upgrading connection: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	defer server.Close()
+
+	n := NewNotifier(nil)
+	failingClient, failing := dialNotifier(t, server, conns)
+	defer failingClient.Close()
+	client, working := dialNotifier(t, server, conns)
+	defer client.Close()
+	n.InsertConnection(failing, 1)
+	n.InsertConnection(working, 2)
+	//writes to a closed connection fail
+	failing.Close()
+
+	msgs := make(chan amqp.Delivery, 1)
+	msgs <- amqp.Delivery{Body: []byte(`{"type": "message-delete", "messageID": "abc", "userIDs": null}`)}
+	close(msgs)
+	done := make(chan bool)
+	go func() {
+		n.NotifyWebSockets(msgs)
+		n.InsertConnection(failing, 3)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("the notifier deadlocked after a failed write")
+	}
+
+	if _, ok := n.Connections[1]; ok {
+		t.Errorf("the failed connection wasn't removed")
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := client.ReadMessage(); err != nil {
+		t.Errorf("the working connection wasn't notified: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//errInvalidCursor is returned for a cursor that can't be decoded
+var errInvalidCursor = errors.New("invalid cursor")
+
+//searchPage is a page of user search results
+type searchPage struct {
+	Users []*users.User `json:"users"`
+	//Next is the cursor of the next page, if there is one
+	Next string `json:"next,omitempty"`
+}
+
+//encodeSearchCursor encodes the index cursor of a page of
+//user search results as an opaque string for clients
+func encodeSearchCursor(cursor *indexes.Cursor) string {
+	buf, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+//decodeSearchCursor decodes a cursor encoded by encodeSearchCursor
+func decodeSearchCursor(s string) (*indexes.Cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	cursor := &indexes.Cursor{}
+	if err := json.Unmarshal(buf, cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+	//every key has a user, and user IDs start at 1
+	if len(cursor.Key) == 0 || cursor.Value < 1 {
+		return nil, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+//parsePageParams reads the "limit" and "cursor" query string
+//parameters of a user search. The cursor is nil for the first page.
+func parsePageParams(r *http.Request) (int, *indexes.Cursor, error) {
+	values := r.URL.Query()
+	limit := defaultSearchLimit
+	if s := values.Get("limit"); len(s) > 0 {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return 0, nil, fmt.Errorf("limit must be an integer between 1 and %d", maxSearchLimit)
+		}
+	}
+	if s := values.Get("cursor"); len(s) > 0 {
+		cursor, err := decodeSearchCursor(s)
+		if err != nil {
+			return 0, nil, err
+		}
+		return limit, cursor, nil
+	}
+	return limit, nil, nil
+}
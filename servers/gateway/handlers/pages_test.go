@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
+)
+
+func TestSearchCursor(t *testing.T) {
+	cursor := &indexes.Cursor{Key: "smith", Value: 42}
+	if decoded, err := decodeSearchCursor(encodeSearchCursor(cursor)); err != nil || *decoded != *cursor {
+		t.Errorf("cursor didn't survive encoding: %+v, %v", decoded, err)
+	}
+
+	invalid := []string{
+		"%%%",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"Key": "", "Value": 42}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"Key": "smith", "Value": -1}`)),
+	}
+	for _, s := range invalid {
+		if _, err := decodeSearchCursor(s); err == nil {
+			t.Errorf("expected error decoding %q", s)
+		}
+	}
+}
@@ -1,14 +1,40 @@
 package handlers
 
 import (
+	"errors"
+	"net/http"
 	"time"
 
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
 )
 
+//ErrPasswordResetRequired is returned when a session that can
+//only be used to change the user's password is used for anything else
+var ErrPasswordResetRequired = errors.New("password must be changed")
+
 //SessionState represents the user's time at which the session began
 //and the authenticated user who started the session
 type SessionState struct {
 	SessionBegin time.Time
 	User         *users.User
+	//PasswordResetRequired is true if the user was required to change
+	//their password when the session began, in which case the session
+	//can only be used to change it
+	PasswordResetRequired bool
+}
+
+//getState gets the session state of the request like sessions.GetState,
+//but returns ErrPasswordResetRequired if the session can only be used
+//to change the user's password. Every handler that requires an
+//authenticated user gets the state this way, except PasswordHandler.
+func (ctx *HandlerCtx) getState(r *http.Request, state *SessionState) (sessions.SessionID, error) {
+	sid, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, state)
+	if err != nil {
+		return sid, err
+	}
+	if state.PasswordResetRequired {
+		return sid, ErrPasswordResetRequired
+	}
+	return sid, nil
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//userNameHoldPeriod is how long a user name that was given up
+//stays reserved for its previous owner before others can take it
+const userNameHoldPeriod = 30 * 24 * time.Hour
+
+//userNameChangeInterval is the minimum time between
+//two user name changes by the same user
+const userNameChangeInterval = 24 * time.Hour
+
+//UserNameHandler handles requests to change the authenticated user's user name
+func (ctx *HandlerCtx) UserNameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "http method must be PUT", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionState := &SessionState{}
+	sid, err := ctx.getState(r, sessionState)
+	if err != nil {
+		http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+		return
+	}
+	contentType := r.Header.Get("Content-type")
+	if contentType != "application/json" {
+		http.Error(w, "request body must be in JSON", http.StatusUnsupportedMediaType)
+		return
+	}
+	change := users.UserNameChange{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := users.ValidateUserName(change.UserName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//the session's copy of the user may be out of date,
+	//and reindexing must remove the keys that are indexed
+	oldUser, err := ctx.getFreshUser(r.Context(), sessionState.User.ID)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	if change.UserName == oldUser.UserName {
+		http.Error(w, "user name is unchanged", http.StatusBadRequest)
+		return
+	}
+
+	past, err := ctx.UserStore.GetPastUserNames(r.Context(), oldUser.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(past) > 0 {
+		if wait := userNameChangeInterval - time.Since(past[0].ChangedAt); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "user name was changed too recently", http.StatusTooManyRequests)
+			return
+		}
+	}
+	if status, err := ctx.checkUserNameAvailable(r.Context(), change.UserName, oldUser.ID); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	user, err := ctx.UserStore.UpdateUserName(r.Context(), oldUser.ID, change.UserName)
+	if err != nil {
+		http.Error(w, err.Error(), userStoreStatus(err))
+		return
+	}
+	ctx.Indexer.ReindexUser(oldUser, user)
+	ctx.publishUserEvent(events.TypeUserUpdate, user)
+	updateEvent := audit.NewEvent(r, audit.TypeProfileUpdate, user.ID)
+	updateEvent.Detail = "userName"
+	ctx.recordAudit(updateEvent)
+
+	sessionState.User = user
+	if err := ctx.SessionStore.Save(sid, sessionState); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+//checkUserNameAvailable returns an error and the HTTP status code to respond
+//with if the user name can't be taken by the user with the given ID, either
+//because another user has it or because another user recently gave it up.
+//Use an ID of 0 for a user who is signing up.
+func (ctx *HandlerCtx) checkUserNameAvailable(c context.Context, userName string, userID int64) (int, error) {
+	existing, err := ctx.UserStore.GetByUserName(c, userName)
+	if err != nil && err != users.ErrUserNotFound {
+		return userStoreStatus(err), err
+	}
+	if err == nil && existing.ID != userID {
+		return http.StatusConflict, users.ErrDuplicateUserName
+	}
+	holder, err := ctx.UserStore.GetUserNameHolder(c, userName, time.Now().Add(-userNameHoldPeriod))
+	if err != nil {
+		return userStoreStatus(err), err
+	}
+	if holder != 0 && holder != userID {
+		return http.StatusConflict, users.ErrDuplicateUserName
+	}
+	return http.StatusOK, nil
+}
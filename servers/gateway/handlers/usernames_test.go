@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//unlimitedUserNameStore is a users.Store that has no past
+//user names, so user names can be changed any number of times
+type unlimitedUserNameStore struct {
+	users.Store
+}
+
+func (us *unlimitedUserNameStore) GetPastUserNames(c context.Context, id int64) ([]*users.PastUserName, error) {
+	return nil, nil
+}
+
+func (us *unlimitedUserNameStore) GetUserNameHolder(c context.Context, userName string, since time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestUserNameChangeWithStaleSession(t *testing.T) {
+	ctx := newTestContext()
+	ctx.UserStore = &unlimitedUserNameStore{ctx.UserStore}
+	ctx.BlockStore = &fakeBlockStore{}
+	ctx.ContactStore = &fakeContactStore{}
+	user, err := ctx.UserStore.Insert(context.Background(), &users.User{Email: "test@example.com", UserName: "first"})
+	if err != nil {
+		t.Fatalf("error inserting user: %v", err)
+	}
+	ctx.Indexer.IndexUser(user)
+	tokens := []string{}
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		if _, err := ctx.beginSession(user, rr); err != nil {
+			t.Fatalf("error beginning session: %v", err)
+		}
+		tokens = append(tokens, rr.Header().Get("Authorization"))
+	}
+
+	//the second session's copy of the user still has the first user name
+	for i, userName := range []string{"second", "third"} {
+		req := jsonRequest(http.MethodPut, "/v1/users/me/username", `{"userName": "`+userName+`"}`)
+		req.Header.Set("Authorization", tokens[i])
+		rr := httptest.NewRecorder()
+		ctx.UserNameHandler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("error changing user name to %s: %d %s", userName, rr.Code, rr.Body.String())
+		}
+	}
+	if found := ctx.Indexer.Search("second", 10, nil); len(found) != 0 {
+		t.Errorf("the replaced user name is still indexed: %v", found)
+	}
+	if found := ctx.Indexer.Search("third", 10, nil); len(found) != 1 {
+		t.Errorf("the new user name isn't indexed: %v", found)
+	}
+}
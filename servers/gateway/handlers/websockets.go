@@ -4,7 +4,6 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
 	"github.com/gorilla/websocket"
 )
 
@@ -36,7 +35,7 @@ func (ctx *HandlerCtx) WebSocketConnectionHandler(w http.ResponseWriter, r *http
 		http.Error(w, "Websocket Connection Refused", 403)
 	}
 	ss := &SessionState{}
-	_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, ss)
+	_, err := ctx.getState(r, ss)
 	if err != nil {
 		http.Error(w, "User is not authenticated", http.StatusUnauthorized)
 		return
@@ -57,7 +56,9 @@ func (ctx *HandlerCtx) WebSocketConnectionHandler(w http.ResponseWriter, r *http
 			messageType, p, err := conn.ReadMessage()
 
 			if messageType == TextMessage || messageType == BinaryMessage {
+				ctx.Notifier.lock.Lock()
 				ctx.Notifier.WriteToAllConnections(TextMessage, append([]byte("Hello from server: "), p...))
+				ctx.Notifier.lock.Unlock()
 			} else if messageType == CloseMessage {
 				log.Println("Close message received.")
 				break
@@ -0,0 +1,66 @@
+package indexes
+
+import "unicode/utf8"
+
+//CursorOf marks the position of a value in the order Find returns
+//values in, so that FindAfter can continue from there
+type CursorOf[V comparable] struct {
+	//Key is the key the value was found at
+	Key   string
+	Value V
+}
+
+//Cursor marks the position of an int64 value
+type Cursor = CursorOf[int64]
+
+//findAfter returns up to `max` of the values that visit yields after
+//the cursor, and the cursor of the last of them if there are more.
+//visit must yield keys in the order Find returns them, with each
+//key's values, until yield returns false. A value is only returned
+//at the first key it is found at, even if that is before the cursor,
+//so that no value is returned twice while paging through the results.
+//Values for which keep returns false are left out, unless keep is nil.
+func findAfter[V comparable](visit func(yield func(key string, vals valueSet[V]) bool), cursor *CursorOf[V], max int, less func(a, b V) bool, keep func(value V) bool) ([]V, *CursorOf[V]) {
+	var values []V
+	var next *CursorOf[V]
+	seen := make(map[V]bool)
+	last := CursorOf[V]{}
+	visit(func(key string, vals valueSet[V]) bool {
+		for _, value := range vals.sorted(less) {
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			if keep != nil && !keep(value) {
+				continue
+			}
+			if cursor != nil && !isAfter(key, value, cursor, less) {
+				continue
+			}
+			if len(values) == max {
+				//there are more values, so continue after the last one
+				next = &last
+				return false
+			}
+			values = append(values, value)
+			last = CursorOf[V]{key, value}
+		}
+		return true
+	})
+	return values, next
+}
+
+//isAfter returns true if the value at the key comes after the
+//cursor: its key is longer, or as long but later in order of
+//runes, or the same but the value is later in order of less
+func isAfter[V comparable](key string, value V, cursor *CursorOf[V], less func(a, b V) bool) bool {
+	length, cursorLength := utf8.RuneCountInString(key), utf8.RuneCountInString(cursor.Key)
+	if length != cursorLength {
+		return length > cursorLength
+	}
+	//comparing UTF-8 strings compares their runes
+	if key != cursor.Key {
+		return key > cursor.Key
+	}
+	return less(cursor.Value, value)
+}
@@ -0,0 +1,193 @@
+package indexes
+
+import "sort"
+
+//fuzzyMatch is the best match found for a value by FindFuzzy
+type fuzzyMatch[V comparable] struct {
+	value    V
+	distance int
+	//prefix is true if the query only matched
+	//the beginning of the value's key
+	prefix bool
+}
+
+//better returns true if m ranks ahead of other:
+//closer matches first, then whole keys before prefixes
+func (m *fuzzyMatch[V]) better(other *fuzzyMatch[V]) bool {
+	if m.distance != other.distance {
+		return m.distance < other.distance
+	}
+	return !m.prefix && other.prefix
+}
+
+//fuzzySearch holds the state of a FindFuzzy search, which walks
+//an index like a Levenshtein automaton, computing each node's row of
+//edit distances between the query's prefixes and the node's key from
+//its parent's row, and skipping branches that can't match
+type fuzzySearch[V comparable] struct {
+	runes       []rune
+	maxDistance int
+	best        map[V]*fuzzyMatch[V]
+	less        func(a, b V) bool
+}
+
+//newFuzzySearch starts a search for the query, returning the search
+//and the row of distances for the empty key. Equally good matches
+//are ordered by less.
+func newFuzzySearch[V comparable](query string, maxDistance int, less func(a, b V) bool) (*fuzzySearch[V], []int) {
+	fs := &fuzzySearch[V]{
+		runes:       []rune(query),
+		maxDistance: maxDistance,
+		best:        make(map[V]*fuzzyMatch[V]),
+		less:        less,
+	}
+	row := make([]int, len(fs.runes)+1)
+	for i := range row {
+		row[i] = i
+	}
+	return fs, row
+}
+
+//visit records the values of a node whose key has the given row of
+//distances, where prefixDistance is the least distance of any prefix
+//of the key, including the key itself
+func (fs *fuzzySearch[V]) visit(vals valueSet[V], row []int, prefixDistance int) {
+	n := len(fs.runes)
+	if len(vals) == 0 {
+		return
+	}
+	if row[n] <= fs.maxDistance {
+		fs.record(vals, fuzzyMatch[V]{distance: row[n]})
+	}
+	if prefixDistance < row[n] && prefixDistance <= fs.maxDistance {
+		fs.record(vals, fuzzyMatch[V]{distance: prefixDistance, prefix: true})
+	}
+}
+
+//record keeps the match for each value if it's the best so far
+func (fs *fuzzySearch[V]) record(vals valueSet[V], match fuzzyMatch[V]) {
+	for value := range vals {
+		match.value = value
+		if current, ok := fs.best[value]; !ok || match.better(current) {
+			m := match
+			fs.best[value] = &m
+		}
+	}
+}
+
+//step returns the row of distances for a key extended by the rune,
+//and whether any key extending it could still match
+func (fs *fuzzySearch[V]) step(row []int, name rune, prefixDistance int) ([]int, bool) {
+	n := len(fs.runes)
+	next := make([]int, n+1)
+	next[0] = row[0] + 1
+	minDistance := next[0]
+	for i := 1; i <= n; i++ {
+		cost := 1
+		if fs.runes[i-1] == name {
+			cost = 0
+		}
+		next[i] = minInt(next[i-1]+1, row[i]+1, row[i-1]+cost)
+		if next[i] < minDistance {
+			minDistance = next[i]
+		}
+	}
+	return next, minDistance <= fs.maxDistance || prefixDistance <= fs.maxDistance
+}
+
+//score returns the relevance score of the match: whole keys score
+//exactMatchScore more than prefixes, and each edit costs typoScore,
+//so that closer matches always score higher
+func (m *fuzzyMatch[V]) score() float64 {
+	score := -typoScore * float64(m.distance)
+	if !m.prefix {
+		score += exactMatchScore
+	}
+	return score
+}
+
+//scores returns the relevance score of every value found
+func (fs *fuzzySearch[V]) scores() map[V]float64 {
+	scores := make(map[V]float64, len(fs.best))
+	for value, match := range fs.best {
+		scores[value] = match.score()
+	}
+	return scores
+}
+
+//results returns up to `max` of the values found, best first
+func (fs *fuzzySearch[V]) results(max int) []V {
+	matches := make([]*fuzzyMatch[V], 0, len(fs.best))
+	for _, match := range fs.best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].better(matches[j]) || matches[j].better(matches[i]) {
+			return matches[i].better(matches[j])
+		}
+		return fs.less(matches[i].value, matches[j].value)
+	})
+	if len(matches) > max {
+		matches = matches[:max]
+	}
+	values := make([]V, len(matches))
+	for i, match := range matches {
+		values[i] = match.value
+	}
+	return values
+}
+
+//FindFuzzy finds up to `max` values whose keys are within maxDistance
+//edits (insertions, deletions or substitutions of a rune) of `query`,
+//or begin with a string that is. Values are ordered by distance, then
+//with whole-key matches before prefix matches, then by the trie's less
+//function. If the trie is empty, the query is empty, or max == 0, this
+//returns a nil slice.
+func (t *TrieOf[V]) FindFuzzy(query string, maxDistance int, max int) []V {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	if t.Len() == 0 || len(query) == 0 || max == 0 {
+		return nil
+	}
+	return t.searchFuzzy(query, maxDistance).results(max)
+}
+
+//scoreFuzzy returns the relevance score of every value FindFuzzy
+//would find. The caller must hold the trie's lock.
+func (t *TrieOf[V]) scoreFuzzy(query string, maxDistance int) map[V]float64 {
+	return t.searchFuzzy(query, maxDistance).scores()
+}
+
+//searchFuzzy finds the best match of every value for FindFuzzy.
+//The caller must hold the trie's lock.
+func (t *TrieOf[V]) searchFuzzy(query string, maxDistance int) *fuzzySearch[V] {
+	fs, row := newFuzzySearch(query, maxDistance, t.less)
+	if t.Len() == 0 || len(query) == 0 {
+		return fs
+	}
+	n := len(fs.runes)
+	var walk func(node *trieNode[V], row []int, prefixDistance int)
+	walk = func(node *trieNode[V], row []int, prefixDistance int) {
+		if row[n] < prefixDistance {
+			prefixDistance = row[n]
+		}
+		fs.visit(node.vals, row, prefixDistance)
+		for name, child := range node.children {
+			if next, ok := fs.step(row, name, prefixDistance); ok {
+				walk(child, next, prefixDistance)
+			}
+		}
+	}
+	walk(t.Root, row, n+1)
+	return fs
+}
+
+func minInt(first int, others ...int) int {
+	min := first
+	for _, v := range others {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
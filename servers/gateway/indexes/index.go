@@ -0,0 +1,100 @@
+package indexes
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+//Index maps string keys to int64 values, such as words of users' names
+//to their IDs, and finds values by key prefix. Trie and RadixTree are
+//both Indexes, and are safe for concurrent use.
+type Index interface {
+	//Len returns the number of entries in the index
+	Len() int
+	//Add adds a key and value to the index
+	Add(key string, value int64)
+	//AddField adds a key and value to the index, recording
+	//the field of the value that the key came from
+	AddField(key string, value int64, field Field)
+	//Find finds `max` values matching `prefix`
+	Find(prefix string, max int) []int64
+	//FindAfter finds up to `max` values matching `prefix` after the cursor
+	FindAfter(prefix string, cursor *Cursor, max int) ([]int64, *Cursor)
+	//Remove removes a key/value pair from the index
+	Remove(key string, value int64)
+	//FindRanked finds up to `max` values matching `prefix`, ranked by relevance
+	FindRanked(prefix string, max int, boost Boost) []int64
+	//FindRankedAll finds up to `max` values matching every prefix, ranked by relevance
+	FindRankedAll(prefixes []string, max int, boost Boost) []int64
+	//FindFuzzy finds up to `max` values matching `query` with up to maxDistance typos
+	FindFuzzy(query string, maxDistance int, max int) []int64
+	//WriteTo writes a binary snapshot of the index to w
+	WriteTo(w io.Writer) (int64, error)
+
+	//locker returns the lock guarding the index, so that
+	//the Indexer can make several changes at once
+	locker() *sync.RWMutex
+	//add, remove and removeValues change the index
+	//and must be called holding its lock
+	add(key string, value int64, field Field)
+	remove(key string, value int64)
+	removeValues(values map[int64]bool)
+	//each calls fn with every entry of the index,
+	//and must be called holding its lock
+	each(fn func(key string, value int64, field Field))
+	//scorePrefix and scoreFuzzy score the values FindRankedAll and
+	//FindFuzzy would find, and must be called holding its lock
+	scorePrefix(prefix string) map[int64]float64
+	scoreFuzzy(query string, maxDistance int) map[int64]float64
+	//findAfterIf is FindAfter leaving out values for which keep
+	//returns false, and must be called holding its lock
+	findAfterIf(prefix string, cursor *Cursor, max int, keep func(value int64) bool) ([]int64, *Cursor)
+}
+
+//Index kinds, for NewIndex
+const (
+	//KindTrie is an index stored in a Trie
+	KindTrie = "trie"
+	//KindRadix is an index stored in a RadixTree
+	KindRadix = "radix"
+)
+
+//NewIndex constructs a new, empty index of the given kind
+func NewIndex(kind string) (Index, error) {
+	switch kind {
+	case KindTrie:
+		return NewTrie(), nil
+	case KindRadix:
+		return NewRadixTree(), nil
+	}
+	return nil, fmt.Errorf("unknown index kind %q", kind)
+}
+
+//emptyLike returns a new, empty index of the same kind as `like`
+func emptyLike(like Index) Index {
+	switch like := like.(type) {
+	case *NGramIndex:
+		return NewNGramIndex(emptyLike(like.Index))
+	case *RadixTree:
+		return NewRadixTree()
+	default:
+		return NewTrie()
+	}
+}
+
+//readIndex reads a snapshot of an index of the same kind as `like`
+func readIndex(like Index, r io.Reader) (Index, error) {
+	switch like := like.(type) {
+	case *NGramIndex:
+		index, err := readIndex(like.Index, r)
+		if err != nil {
+			return nil, err
+		}
+		return NewNGramIndex(index), nil
+	case *RadixTree:
+		return ReadRadixTree(r)
+	default:
+		return ReadTrie(r)
+	}
+}
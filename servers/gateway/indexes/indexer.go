@@ -0,0 +1,299 @@
+package indexes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//ErrQueryTooShort is returned by SearchContains if
+//a word of the query is too short to search for
+var ErrQueryTooShort = fmt.Errorf("each word of the query must be at least %d letters long", MinContainsLength)
+
+//ErrContainsUnsupported is returned by SearchContains if the
+//index can't find users by substrings of their names
+var ErrContainsUnsupported = errors.New("the index doesn't support substring search")
+
+//ErrInvalidCursor is returned by SearchAfter if the cursor
+//can't be from a search for the same query
+var ErrInvalidCursor = errors.New("the cursor isn't from a search for this query")
+
+//indexKey is a key a user is indexed by and the field it came from
+type indexKey struct {
+	key   string
+	field Field
+}
+
+//Indexer maintains the index of users for search. It knows which
+//fields of a user are indexed and how, and is the only thing that
+//changes its index, so that every code path indexes users the same way.
+type Indexer struct {
+	mx    sync.RWMutex
+	index Index
+	//publisher, if not nil, sends the indexer's
+	//mutations to the other gateway replicas
+	publisher MutationPublisher
+	//rebuilding is true while Reconcile builds a new index,
+	//and pending holds the mutations made in the meantime
+	rebuilding bool
+	pending    []*Mutation
+}
+
+//NewIndexer constructs a new Indexer maintaining the index
+func NewIndexer(index Index) *Indexer {
+	if index == nil {
+		panic("nil index")
+	}
+	return &Indexer{index: index}
+}
+
+//NewSyncedIndexer constructs a new Indexer maintaining the index that
+//publishes its mutations so that other replicas can apply them
+func NewSyncedIndexer(index Index, publisher MutationPublisher) *Indexer {
+	if publisher == nil {
+		panic("nil publisher")
+	}
+	ix := NewIndexer(index)
+	ix.publisher = publisher
+	return ix
+}
+
+//Len returns the number of entries in the index
+func (ix *Indexer) Len() int {
+	return ix.current().Len()
+}
+
+//IndexUser adds the user to the index
+func (ix *Indexer) IndexUser(user *users.User) {
+	ix.mutate(&Mutation{New: indexedFields(user)})
+}
+
+//ReindexUser updates the index for a change to the user from old to
+//new. Every key of the old user is removed before the keys of the new
+//user are added, since a key can come from more than one field. The
+//change is made at once, so searches never see the user half-indexed.
+func (ix *Indexer) ReindexUser(old *users.User, new *users.User) {
+	ix.mutate(&Mutation{Old: indexedFields(old), New: indexedFields(new)})
+}
+
+//RemoveUser removes the user from the index
+func (ix *Indexer) RemoveUser(user *users.User) {
+	ix.mutate(&Mutation{Old: indexedFields(user)})
+}
+
+//Search finds up to `max` users matching every word of the
+//query, ranked by relevance and the boost, if it isn't nil
+func (ix *Indexer) Search(query string, max int, boost Boost) []int64 {
+	return ix.current().FindRankedAll(Tokens(query), max, boost)
+}
+
+//SearchAfter finds up to `max` users matching every word of the query
+//that come after the cursor, starting from the first if it's nil, in
+//the order Find returns the users matching the first word. Users for
+//whom skip returns true are left out, unless skip is nil. It also
+//returns the cursor of the next page, or nil if there are no more, and
+//returns ErrInvalidCursor if the cursor's key doesn't begin with the
+//first word, since then it's from a search for a different query.
+func (ix *Indexer) SearchAfter(query string, cursor *Cursor, max int, skip func(id int64) bool) ([]int64, *Cursor, error) {
+	tokens := Tokens(query)
+	if cursor != nil && (len(tokens) == 0 || !strings.HasPrefix(cursor.Key, tokens[0])) {
+		return nil, nil, ErrInvalidCursor
+	}
+	if len(tokens) == 0 {
+		return nil, nil, nil
+	}
+	index := ix.current()
+	index.locker().RLock()
+	defer index.locker().RUnlock()
+	others := make([]map[int64]float64, 0, len(tokens)-1)
+	for _, token := range tokens[1:] {
+		others = append(others, index.scorePrefix(token))
+	}
+	keep := func(id int64) bool {
+		if skip != nil && skip(id) {
+			return false
+		}
+		for _, scores := range others {
+			if _, ok := scores[id]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	values, next := index.findAfterIf(tokens[0], cursor, max, keep)
+	return values, next, nil
+}
+
+//Rank orders users found by the query by their relevance to every word
+//of it, as Search ranks them, plus the boost, if it isn't nil. Users
+//with equal scores stay in the order they were given in.
+func (ix *Indexer) Rank(query string, ids []int64, boost Boost) []int64 {
+	index := ix.current()
+	index.locker().RLock()
+	scores := make(map[int64]float64, len(ids))
+	for _, token := range Tokens(query) {
+		tokenScores := index.scorePrefix(token)
+		for _, id := range ids {
+			scores[id] += tokenScores[id]
+		}
+	}
+	index.locker().RUnlock()
+	if boost != nil {
+		for _, id := range ids {
+			scores[id] += boost(id)
+		}
+	}
+	ranked := append([]int64{}, ids...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	return ranked
+}
+
+//SearchFuzzy finds up to `max` users matching every word of the query
+//with up to `distance` typos in each, ranked by how closely they match
+//every word, plus the boost, if it isn't nil. If distance is negative,
+//it's chosen for each word by its length.
+func (ix *Indexer) SearchFuzzy(query string, distance int, max int, boost Boost) []int64 {
+	index := ix.current()
+	index.locker().RLock()
+	defer index.locker().RUnlock()
+	return rankAll(Tokens(query), func(token string) map[int64]float64 {
+		return index.scoreFuzzy(token, fuzzyDistance(token, distance))
+	}, max, boost, lessInt64)
+}
+
+//SearchContains finds up to `max` users with every word of the query
+//in some word of their names, ranked by where and in how long a word
+//they contain each word, plus the boost, if it isn't nil. It returns
+//ErrQueryTooShort if a word of the query is shorter than
+//MinContainsLength, and ErrContainsUnsupported if the index is not
+//an NGramIndex.
+func (ix *Indexer) SearchContains(query string, max int, boost Boost) ([]int64, error) {
+	tokens := Tokens(query)
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) < MinContainsLength {
+			return nil, ErrQueryTooShort
+		}
+	}
+	index, ok := ix.current().(*NGramIndex)
+	if !ok {
+		return nil, ErrContainsUnsupported
+	}
+	index.locker().RLock()
+	defer index.locker().RUnlock()
+	return rankAll(tokens, index.scoreContaining, max, boost, lessInt64), nil
+}
+
+//Reconcile rebuilds the index from every user passed to fn by forEach,
+//such as users.SQLStore.ForEachUser, healing any mutations this replica
+//missed. Mutations made while the new index is being built are replayed
+//onto it before it replaces the current one.
+func (ix *Indexer) Reconcile(forEach func(fn func(user *users.User)) error) error {
+	ix.mx.Lock()
+	if ix.rebuilding {
+		ix.mx.Unlock()
+		return fmt.Errorf("index is already being rebuilt")
+	}
+	ix.rebuilding = true
+	ix.mx.Unlock()
+
+	index := emptyLike(ix.current())
+	err := forEach(func(user *users.User) {
+		(&Mutation{New: user}).applyTo(index)
+	})
+
+	ix.mx.Lock()
+	defer ix.mx.Unlock()
+	pending := ix.pending
+	ix.rebuilding = false
+	ix.pending = nil
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		m.applyTo(index)
+	}
+	ix.index = index
+	return nil
+}
+
+//ReconcileEvery calls Reconcile with forEach at every interval, forever
+func (ix *Indexer) ReconcileEvery(interval time.Duration, forEach func(fn func(user *users.User)) error) {
+	for range time.Tick(interval) {
+		if err := ix.Reconcile(forEach); err != nil {
+			log.Printf("Error reconciling user index: %s", err.Error())
+		}
+	}
+}
+
+//mutate applies a mutation made by this replica
+//and publishes it to the other replicas
+func (ix *Indexer) mutate(m *Mutation) {
+	ix.apply(m)
+	if ix.publisher != nil {
+		if err := ix.publisher.Publish(m); err != nil {
+			log.Printf("Error publishing index mutation: %s", err.Error())
+		}
+	}
+}
+
+//apply applies the mutation to the index, keeping it
+//to replay if the index is being rebuilt
+func (ix *Indexer) apply(m *Mutation) {
+	ix.mx.Lock()
+	defer ix.mx.Unlock()
+	m.applyTo(ix.index)
+	if ix.rebuilding {
+		ix.pending = append(ix.pending, m)
+	}
+}
+
+//current returns the index currently being searched
+func (ix *Indexer) current() Index {
+	ix.mx.RLock()
+	defer ix.mx.RUnlock()
+	return ix.index
+}
+
+//userKeys returns the keys the user is indexed by: the
+//words of their first, last and user names
+func userKeys(user *users.User) []indexKey {
+	keys := []indexKey{}
+	for _, name := range []string{user.FirstName, user.LastName} {
+		for _, token := range Tokens(name) {
+			keys = append(keys, indexKey{token, FieldName})
+		}
+	}
+	for _, token := range Tokens(user.UserName) {
+		keys = append(keys, indexKey{token, FieldUserName})
+	}
+	return keys
+}
+
+//fuzzyDistance returns the number of typos tolerated in a word of a
+//fuzzy search. Unless a distance is given, short words tolerate fewer
+//typos, since with one typo in two letters almost anything matches.
+func fuzzyDistance(token string, distance int) int {
+	length := utf8.RuneCountInString(token)
+	if distance < 0 {
+		distance = 0
+		if length > 5 {
+			distance = 2
+		} else if length > 2 {
+			distance = 1
+		}
+	}
+	//a distance as long as the word would match every user
+	if distance >= length {
+		distance = length - 1
+	}
+	return distance
+}
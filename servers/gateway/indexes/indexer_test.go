@@ -0,0 +1,118 @@
+package indexes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+func TestIndexer(t *testing.T) {
+	indexer := NewIndexer(NewTrie())
+	john := &users.User{ID: 1, UserName: "jsmith", FirstName: "John", LastName: "Smith"}
+	johnny := &users.User{ID: 2, UserName: "johnny", FirstName: "John", LastName: "Doe-Ray"}
+	indexer.IndexUser(john)
+	indexer.IndexUser(johnny)
+
+	expectSearch := func(step string, query string, expected []int64) {
+		if found := indexer.Search(query, 10, nil); !reflect.DeepEqual(found, expected) {
+			t.Errorf("%s: search for %q: expected %v but got %v", step, query, expected, found)
+		}
+	}
+	expectSearch("indexed", "john", []int64{1, 2})
+	expectSearch("indexed", "ray", []int64{2})
+	expectSearch("indexed", "john smith", []int64{1})
+
+	//the new first name is the old user name, so reindexing
+	//must not leave the user indexed by only one of them
+	renamed := &users.User{ID: 1, UserName: "johnsmith", FirstName: "Jsmith", LastName: "Smith"}
+	indexer.ReindexUser(john, renamed)
+	expectSearch("reindexed", "jsmith", []int64{1})
+	expectSearch("reindexed", "johnsmith", []int64{1})
+	expectSearch("reindexed", "john", []int64{2, 1})
+
+	indexer.RemoveUser(renamed)
+	expectSearch("removed", "smith", nil)
+	expectSearch("removed", "john", []int64{2})
+	if found := indexer.SearchFuzzy("jonh dio", -1, 10, nil); !reflect.DeepEqual(found, []int64{2}) {
+		t.Errorf("fuzzy search: expected [2] but got %v", found)
+	}
+	if indexer.Len() != 4 {
+		t.Errorf("incorrect number of entries: expected 4 but got %d", indexer.Len())
+	}
+}
+
+func TestIndexerSearchFuzzyLimit(t *testing.T) {
+	indexer := NewIndexer(NewTrie())
+	indexer.IndexUser(&users.User{ID: 1, UserName: "jdoe", FirstName: "John", LastName: "Doe"})
+	indexer.IndexUser(&users.User{ID: 2, UserName: "jray", FirstName: "John", LastName: "Ray"})
+	indexer.IndexUser(&users.User{ID: 3, UserName: "jsmith", FirstName: "John", LastName: "Smith"})
+
+	//the users matching the first word best don't match the second,
+	//so the limit can't be applied before the words are intersected
+	if found := indexer.SearchFuzzy("jonh smith", -1, 1, nil); !reflect.DeepEqual(found, []int64{3}) {
+		t.Errorf("expected [3] but got %v", found)
+	}
+	if found := indexer.SearchFuzzy("jonh smith", -1, 0, nil); found != nil {
+		t.Errorf("expected nil for max 0 but got %v", found)
+	}
+}
+
+func TestIndexerSearchFuzzyBoost(t *testing.T) {
+	indexer := NewIndexer(NewTrie())
+	indexer.IndexUser(&users.User{ID: 1, UserName: "u1", FirstName: "Jon", LastName: "Xu"})
+	indexer.IndexUser(&users.User{ID: 2, UserName: "u2", FirstName: "Jonas", LastName: "Xu"})
+	indexer.IndexUser(&users.User{ID: 3, UserName: "u3", FirstName: "Joan", LastName: "Xu"})
+	indexer.IndexUser(&users.User{ID: 4, UserName: "u4", FirstName: "Jonny", LastName: "Xu"})
+
+	if found := indexer.SearchFuzzy("jon", 1, 10, nil); !reflect.DeepEqual(found, []int64{1, 2, 4, 3}) {
+		t.Errorf("without boost: expected [1 2 4 3] but got %v", found)
+	}
+	//the boost ranks a user ahead of others matching as well,
+	//but not ahead of a whole key or a closer match
+	boost := func(id int64) float64 {
+		if id == 3 || id == 4 {
+			return 3
+		}
+		return 0
+	}
+	if found := indexer.SearchFuzzy("jon", 1, 10, boost); !reflect.DeepEqual(found, []int64{1, 4, 2, 3}) {
+		t.Errorf("with boost: expected [1 4 2 3] but got %v", found)
+	}
+}
+
+func TestIndexerSearchAfter(t *testing.T) {
+	indexer := NewIndexer(NewTrie())
+	indexer.IndexUser(&users.User{ID: 1, UserName: "u1", FirstName: "John", LastName: "Smith"})
+	indexer.IndexUser(&users.User{ID: 2, UserName: "u2", FirstName: "Johnny", LastName: "Smith"})
+	indexer.IndexUser(&users.User{ID: 3, UserName: "u3", FirstName: "John", LastName: "Doe"})
+	indexer.IndexUser(&users.User{ID: 4, UserName: "u4", FirstName: "Jo", LastName: "Smith"})
+	indexer.IndexUser(&users.User{ID: 5, UserName: "u5", FirstName: "Johnson", LastName: "Smith"})
+
+	//users must match both words, and skipped users don't use up the page
+	skip := func(id int64) bool { return id == 2 }
+	values, cursor, err := indexer.SearchAfter("jo smith", nil, 2, skip)
+	if err != nil || !reflect.DeepEqual(values, []int64{4, 1}) || cursor == nil {
+		t.Fatalf("first page: expected [4 1] and a cursor but got %v, %+v, %v", values, cursor, err)
+	}
+	values, cursor, err = indexer.SearchAfter("jo smith", cursor, 2, skip)
+	if err != nil || !reflect.DeepEqual(values, []int64{5}) || cursor != nil {
+		t.Errorf("second page: expected [5] and no cursor but got %v, %+v, %v", values, cursor, err)
+	}
+
+	if _, _, err := indexer.SearchAfter("smith", &Cursor{Key: "john", Value: 1}, 2, nil); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor for another query's cursor but got %v", err)
+	}
+
+	//a page is ranked by relevance and the boost, so the exact match
+	//comes first, then the contact
+	boost := func(id int64) float64 {
+		if id == 5 {
+			return 3
+		}
+		return 0
+	}
+	if ranked := indexer.Rank("john", []int64{2, 5, 1}, boost); !reflect.DeepEqual(ranked, []int64{1, 5, 2}) {
+		t.Errorf("expected [1 5 2] but got %v", ranked)
+	}
+}
@@ -0,0 +1,306 @@
+package indexes
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+//gramLength is the length in runes of the n-grams an
+//NGramIndex indexes keys by: trigrams
+const gramLength = 3
+
+//MinContainsLength is the shortest substring an NGramIndex can find,
+//since a shorter one has no trigrams to look up
+const MinContainsLength = gramLength
+
+//gram is an n-gram of a key
+type gram [gramLength]rune
+
+//ngramKey is a key of an NGramIndex, with its values
+type ngramKey struct {
+	key  string
+	vals int64set
+}
+
+//NGramIndex is an Index that also finds values by any substring of
+//their keys, so that "smith" finds "goldsmith". It wraps another Index,
+//which stores the keys for every other kind of search, and indexes each
+//key by its trigrams: a key can only contain a substring if it contains
+//every trigram of the substring, so only the keys in the postings of all
+//of them need to be checked. It's as safe for concurrent use as the
+//Index it wraps, whose lock guards it too.
+type NGramIndex struct {
+	Index
+	//keys are the keys at least gramLength runes long,
+	//by their IDs, which are reused after a key is removed
+	keys []*ngramKey
+	ids  map[string]int32
+	free []int32
+	//postings are the IDs of the keys containing each gram,
+	//in ascending order so that they can be intersected quickly
+	postings map[gram][]int32
+}
+
+//NewNGramIndex constructs a new NGramIndex wrapping the index,
+//and indexes the substrings of the keys already in it.
+func NewNGramIndex(index Index) *NGramIndex {
+	if index == nil {
+		panic("nil index")
+	}
+	ni := &NGramIndex{
+		Index:    index,
+		ids:      make(map[string]int32),
+		postings: make(map[gram][]int32),
+	}
+	index.locker().RLock()
+	index.each(ni.addGrams)
+	index.locker().RUnlock()
+	return ni
+}
+
+//Add adds a key and value to the index
+func (ni *NGramIndex) Add(key string, value int64) {
+	ni.AddField(key, value, 0)
+}
+
+//AddField adds a key and value to the index, recording
+//the field of the value that the key came from
+func (ni *NGramIndex) AddField(key string, value int64, field Field) {
+	ni.locker().Lock()
+	ni.add(key, value, field)
+	ni.locker().Unlock()
+}
+
+//Remove removes a key/value pair from the index
+func (ni *NGramIndex) Remove(key string, value int64) {
+	ni.locker().Lock()
+	ni.remove(key, value)
+	ni.locker().Unlock()
+}
+
+//add adds a key and value to the index.
+//The caller must hold the index's lock.
+func (ni *NGramIndex) add(key string, value int64, field Field) {
+	ni.Index.add(key, value, field)
+	ni.addGrams(key, value, field)
+}
+
+//addGrams adds a key and value to the trigram index only
+func (ni *NGramIndex) addGrams(key string, value int64, field Field) {
+	if utf8.RuneCountInString(key) < gramLength {
+		//too short to contain any substring that can be searched for
+		return
+	}
+	id, ok := ni.ids[key]
+	if !ok {
+		id = ni.newKey(key)
+	}
+	ni.keys[id].vals.add(value, field)
+}
+
+//remove removes a key/value pair from the index.
+//The caller must hold the index's lock.
+func (ni *NGramIndex) remove(key string, value int64) {
+	ni.Index.remove(key, value)
+	id, ok := ni.ids[key]
+	if !ok {
+		return
+	}
+	if ni.keys[id].vals.remove(value) && len(ni.keys[id].vals) == 0 {
+		ni.removeKey(id)
+	}
+}
+
+//removeValues removes every key of the given values from the
+//index. The caller must hold the index's lock.
+func (ni *NGramIndex) removeValues(values map[int64]bool) {
+	ni.Index.removeValues(values)
+	for id, k := range ni.keys {
+		if k == nil {
+			continue
+		}
+		for value := range k.vals {
+			if values[value] {
+				k.vals.remove(value)
+			}
+		}
+		if len(k.vals) == 0 {
+			ni.removeKey(int32(id))
+		}
+	}
+}
+
+//newKey adds a key with no values yet to the
+//postings of its grams, and returns its ID
+func (ni *NGramIndex) newKey(key string) int32 {
+	var id int32
+	if len(ni.free) > 0 {
+		id = ni.free[len(ni.free)-1]
+		ni.free = ni.free[:len(ni.free)-1]
+		ni.keys[id] = &ngramKey{key, make(int64set)}
+	} else {
+		id = int32(len(ni.keys))
+		ni.keys = append(ni.keys, &ngramKey{key, make(int64set)})
+	}
+	ni.ids[key] = id
+	for _, g := range grams([]rune(key)) {
+		postings := ni.postings[g]
+		i := sort.Search(len(postings), func(i int) bool { return postings[i] >= id })
+		postings = append(postings, 0)
+		copy(postings[i+1:], postings[i:])
+		postings[i] = id
+		ni.postings[g] = postings
+	}
+	return id
+}
+
+//removeKey removes a key from the postings of its grams
+func (ni *NGramIndex) removeKey(id int32) {
+	key := ni.keys[id].key
+	for _, g := range grams([]rune(key)) {
+		postings := ni.postings[g]
+		i := sort.Search(len(postings), func(i int) bool { return postings[i] >= id })
+		if i == len(postings) || postings[i] != id {
+			continue
+		}
+		if len(postings) == 1 {
+			delete(ni.postings, g)
+			continue
+		}
+		ni.postings[g] = append(postings[:i], postings[i+1:]...)
+	}
+	delete(ni.ids, key)
+	ni.keys[id] = nil
+	ni.free = append(ni.free, id)
+}
+
+//grams returns the distinct grams of the runes
+func grams(runes []rune) []gram {
+	found := []gram{}
+	seen := make(map[gram]bool)
+	for i := 0; i+gramLength <= len(runes); i++ {
+		var g gram
+		copy(g[:], runes[i:i+gramLength])
+		if !seen[g] {
+			seen[g] = true
+			found = append(found, g)
+		}
+	}
+	return found
+}
+
+//FindContaining finds up to `max` values with keys containing
+//`substring`. Keys starting with it come first, then shorter keys,
+//then keys in order of their runes, and each key's values in order.
+//A value found at several keys is returned once, at the first. If
+//the substring is shorter than MinContainsLength, or max == 0, or
+//no key contains it, this returns a nil slice.
+func (ni *NGramIndex) FindContaining(substring string, max int) []int64 {
+	ni.locker().RLock()
+	defer ni.locker().RUnlock()
+	if max <= 0 {
+		return nil
+	}
+	matches := ni.containing(substring)
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i].key, matches[j].key
+		if startA, startB := strings.HasPrefix(a, substring), strings.HasPrefix(b, substring); startA != startB {
+			return startA
+		}
+		if lengthA, lengthB := utf8.RuneCountInString(a), utf8.RuneCountInString(b); lengthA != lengthB {
+			return lengthA < lengthB
+		}
+		return a < b
+	})
+
+	var values []int64
+	seen := make(map[int64]bool)
+	for _, k := range matches {
+		for _, value := range k.vals.sorted(lessInt64) {
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			values = append(values, value)
+			if len(values) == max {
+				return values
+			}
+		}
+	}
+	return values
+}
+
+//scoreContaining returns the relevance score of every value with
+//a key containing the substring: keys starting with it score
+//exactMatchScore more, and shorter keys score up to keyLengthScore
+//more than longer ones. Each value is scored by the best of its
+//keys. The caller must hold the index's lock.
+func (ni *NGramIndex) scoreContaining(substring string) map[int64]float64 {
+	scores := make(map[int64]float64)
+	length := utf8.RuneCountInString(substring)
+	for _, k := range ni.containing(substring) {
+		score := keyLengthScore / float64(utf8.RuneCountInString(k.key)-length+1)
+		if strings.HasPrefix(k.key, substring) {
+			score += exactMatchScore
+		}
+		for value := range k.vals {
+			if current, ok := scores[value]; !ok || score > current {
+				scores[value] = score
+			}
+		}
+	}
+	return scores
+}
+
+//containing returns the keys containing the substring, in no
+//particular order. The caller must hold the index's lock.
+func (ni *NGramIndex) containing(substring string) []*ngramKey {
+	runes := []rune(substring)
+	if len(runes) < MinContainsLength {
+		return nil
+	}
+
+	//intersect the postings of the substring's grams, shortest first
+	lists := [][]int32{}
+	for _, g := range grams(runes) {
+		postings, ok := ni.postings[g]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, postings)
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	candidates := lists[0]
+	for _, postings := range lists[1:] {
+		candidates = intersectIDs(candidates, postings)
+	}
+
+	//the grams can all be in a key without the substring
+	//being in it, as "ana" and "nan" are in "anaxnan"
+	matches := []*ngramKey{}
+	for _, id := range candidates {
+		if k := ni.keys[id]; strings.Contains(k.key, substring) {
+			matches = append(matches, k)
+		}
+	}
+	return matches
+}
+
+//intersectIDs returns the IDs in both ascending lists, in a new list
+func intersectIDs(a []int32, b []int32) []int32 {
+	both := []int32{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+	return both
+}
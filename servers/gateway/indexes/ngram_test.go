@@ -0,0 +1,211 @@
+package indexes
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+func TestNGramIndex(t *testing.T) {
+	ni := NewNGramIndex(NewTrie())
+	ni.Add("goldsmith", 1)
+	ni.Add("smith", 2)
+	ni.Add("smithers", 3)
+	ni.Add("blacksmith", 4)
+	ni.Add("smith", 4)
+	ni.Add("anaxnan", 5)
+	ni.Add("li", 6)
+	if ni.Len() != 7 {
+		t.Errorf("incorrect size: expected 7 but got %d", ni.Len())
+	}
+
+	cases := []struct {
+		name           string
+		substring      string
+		max            int
+		expectedValues []int64
+	}{
+		{"Prefix And Infix", "smith", 10, []int64{2, 4, 3, 1}},
+		{"Limited", "smith", 2, []int64{2, 4}},
+		{"Infix Only", "dsm", 10, []int64{1}},
+		{"Suffix", "ers", 10, []int64{3}},
+		{"Every Gram But Not The Substring", "anan", 10, nil},
+		{"Too Short", "li", 10, nil},
+		{"Not Found", "xyz", 10, nil},
+		{"Zero Max", "smith", 0, nil},
+	}
+	for _, c := range cases {
+		if values := ni.FindContaining(c.substring, c.max); !reflect.DeepEqual(values, c.expectedValues) {
+			t.Errorf("case %s: expected values %v but got %v", c.name, c.expectedValues, values)
+		}
+	}
+	if values := ni.Find("li", 10); !reflect.DeepEqual(values, []int64{6}) {
+		t.Errorf("prefix search of the wrapped index: expected [6] but got %v", values)
+	}
+
+	ni.Remove("smith", 2)
+	ni.Remove("smith", 4)
+	ni.Remove("goldsmith", 99)
+	ni.removeValues(map[int64]bool{3: true})
+	if values := ni.FindContaining("smith", 10); !reflect.DeepEqual(values, []int64{1, 4}) {
+		t.Errorf("after removing: expected [1 4] but got %v", values)
+	}
+	if _, ok := ni.postings[gram{'e', 'r', 's'}]; ok {
+		t.Errorf("grams of removed keys are still posted")
+	}
+	//IDs of removed keys are reused
+	ni.Add("smithson", 7)
+	if len(ni.keys) != 5 || ni.Len() != 5 {
+		t.Errorf("removed keys weren't reused: %d keys and %d entries", len(ni.keys), ni.Len())
+	}
+	if values := ni.FindContaining("smith", 10); !reflect.DeepEqual(values, []int64{7, 1, 4}) {
+		t.Errorf("after adding: expected [7 1 4] but got %v", values)
+	}
+}
+
+func TestNGramIndexMatchesScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(441))
+	for _, kind := range []string{KindTrie, KindRadix} {
+		index, _ := NewIndex(kind)
+		ni := NewNGramIndex(index)
+		keys := randomKeys(rnd, 500)
+		for i, key := range keys {
+			ni.Add(key, int64(i%200))
+		}
+		for i, key := range keys {
+			if rnd.Intn(3) == 0 {
+				ni.Remove(key, int64(i%200))
+				keys[i] = ""
+			}
+		}
+		//wrapping an index that already has keys indexes them too
+		rebuilt := NewNGramIndex(index)
+
+		for _, query := range append(randomKeys(rnd, 100), "thma", "李o", "ééé") {
+			expected := make(map[int64]bool)
+			for i, key := range keys {
+				if len([]rune(query)) >= MinContainsLength && strings.Contains(key, query) {
+					expected[int64(i%200)] = true
+				}
+			}
+			for name, index := range map[string]*NGramIndex{"maintained": ni, "rebuilt": rebuilt} {
+				found := index.FindContaining(query, 1000)
+				if len(found) != len(expected) {
+					t.Errorf("%s %s: search for %q: expected %d values but got %v", kind, name, query, len(expected), found)
+				}
+				for _, value := range found {
+					if !expected[value] {
+						t.Errorf("%s %s: search for %q: unexpected value %d", kind, name, query, value)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestIndexerSearchContains(t *testing.T) {
+	indexer := NewIndexer(NewNGramIndex(NewTrie()))
+	indexer.IndexUser(&users.User{ID: 1, UserName: "ggold", FirstName: "Ann", LastName: "Goldsmith"})
+	indexer.IndexUser(&users.User{ID: 2, UserName: "jsmith", FirstName: "John", LastName: "Smith"})
+	indexer.IndexUser(&users.User{ID: 3, UserName: "mrgold", FirstName: "Marigold", LastName: "Jones"})
+
+	cases := []struct {
+		query         string
+		expectedIDs   []int64
+		expectedError error
+	}{
+		{"smith", []int64{2, 1}, nil},
+		{"GOLD", []int64{1, 3}, nil},
+		{"gold smith", []int64{1}, nil},
+		{"gold sm", nil, ErrQueryTooShort},
+		{"--", nil, nil},
+	}
+	for _, c := range cases {
+		found, err := indexer.SearchContains(c.query, 10, nil)
+		if err != c.expectedError || !reflect.DeepEqual(found, c.expectedIDs) {
+			t.Errorf("query %q: expected %v, %v but got %v, %v", c.query, c.expectedIDs, c.expectedError, found, err)
+		}
+	}
+
+	//Goldsmith contains "gold" first, but only Marigold Jones contains
+	//both words, so the limit can't be applied before they're intersected
+	if found, err := indexer.SearchContains("gold jones", 1, nil); err != nil || !reflect.DeepEqual(found, []int64{3}) {
+		t.Errorf("limited search: expected [3] but got %v, %v", found, err)
+	}
+
+	//the index is still an NGramIndex after it's rebuilt or read from a snapshot
+	if err := indexer.Reconcile(func(fn func(user *users.User)) error {
+		fn(&users.User{ID: 4, UserName: "bsmith", FirstName: "Bob", LastName: "Blacksmith"})
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error reconciling: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "index.snapshot")
+	if err := indexer.SaveSnapshot(path); err != nil {
+		t.Fatalf("unexpected error saving snapshot: %v", err)
+	}
+	loaded := NewIndexer(NewNGramIndex(NewTrie()))
+	if _, err := loaded.LoadSnapshot(path); err != nil {
+		t.Fatalf("unexpected error loading snapshot: %v", err)
+	}
+	if found, err := loaded.SearchContains("smith", 10, nil); err != nil || !reflect.DeepEqual(found, []int64{4}) {
+		t.Errorf("search of loaded index: expected [4] but got %v, %v", found, err)
+	}
+
+	if _, err := NewIndexer(NewTrie()).SearchContains("smith", 10, nil); err != ErrContainsUnsupported {
+		t.Errorf("expected ErrContainsUnsupported from a plain trie but got %v", err)
+	}
+}
+
+func TestIndexerSearchContainsBoost(t *testing.T) {
+	indexer := NewIndexer(NewNGramIndex(NewTrie()))
+	indexer.IndexUser(&users.User{ID: 1, UserName: "u1", FirstName: "Ann", LastName: "Goldsmith"})
+	indexer.IndexUser(&users.User{ID: 2, UserName: "u2", FirstName: "Ann", LastName: "Marigold"})
+	indexer.IndexUser(&users.User{ID: 3, UserName: "u3", FirstName: "Ann", LastName: "Rosegold"})
+
+	if found, err := indexer.SearchContains("gold", 10, nil); err != nil || !reflect.DeepEqual(found, []int64{1, 2, 3}) {
+		t.Errorf("without boost: expected [1 2 3] but got %v, %v", found, err)
+	}
+	//the boost ranks Rosegold ahead of Marigold, but not of a
+	//name starting with the query
+	boost := func(id int64) float64 {
+		if id == 3 {
+			return 3
+		}
+		return 0
+	}
+	if found, err := indexer.SearchContains("gold", 10, boost); err != nil || !reflect.DeepEqual(found, []int64{1, 3, 2}) {
+		t.Errorf("with boost: expected [1 3 2] but got %v, %v", found, err)
+	}
+}
+
+func BenchmarkIndexFindContaining(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	ni := NewNGramIndex(NewRadixTree())
+	for id, key := range keys {
+		ni.Add(key, int64(id))
+	}
+	queries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		//the middle of each key, so that they aren't prefixes
+		if len(key) > 4 {
+			queries = append(queries, key[1:4])
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ni.FindContaining(queries[i%len(queries)], 20)
+	}
+}
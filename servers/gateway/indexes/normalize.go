@@ -0,0 +1,34 @@
+package indexes
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+//Normalize returns s in the form keys are indexed and searched in:
+//compatibility characters such as full-width letters are replaced
+//with their usual forms, diacritics are removed, and case is folded,
+//so that "Ｊｏｓé" and "JOSE" both become "jose"
+func Normalize(s string) string {
+	//transformers keep state, so each call needs its own
+	stripMarks := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(stripMarks, s)
+	if err != nil {
+		stripped = norm.NFKC.String(s)
+	}
+	return cases.Fold().String(stripped)
+}
+
+//Tokens normalizes s and splits it into the words it is
+//indexed and searched by, breaking at spaces, hyphens,
+//punctuation and anything else that isn't a letter or digit
+func Tokens(s string) []string {
+	return strings.FieldsFunc(Normalize(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,30 @@
+package indexes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"Lowercase", "John Smith", []string{"john", "smith"}},
+		{"Diacritics", "José Müller", []string{"jose", "muller"}},
+		{"Combining Marks", "Jose\u0301", []string{"jose"}},
+		{"Full Width", "Ｊｏｈｎ", []string{"john"}},
+		{"Case Folding", "STRASSE Straße", []string{"strasse", "strasse"}},
+		{"Hyphens And Punctuation", "Jean-Luc O'Brien", []string{"jean", "luc", "o", "brien"}},
+		{"Extra Spaces", "  Mary   Ann ", []string{"mary", "ann"}},
+		{"Underscores And Digits", "john_doe99", []string{"john", "doe99"}},
+		{"Non-Latin", "Ἀθηνᾶ 李", []string{"αθηνα", "李"}},
+		{"Nothing To Index", "--", []string{}},
+	}
+	for _, c := range cases {
+		if tokens := Tokens(c.input); !reflect.DeepEqual(tokens, c.expected) {
+			t.Errorf("case %s: expected %q but got %q", c.name, c.expected, tokens)
+		}
+	}
+}
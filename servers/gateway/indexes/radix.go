@@ -0,0 +1,479 @@
+package indexes
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"sync"
+)
+
+//radixNode is a node of a RadixTree. Its label holds the runes of
+//the edge from its parent, so a chain of nodes with no values and
+//one child each is stored as a single node.
+type radixNode struct {
+	label []rune
+	//vals is nil for nodes with no values
+	vals int64set
+	//children are ordered by the first rune of their labels
+	children []*radixNode
+}
+
+//RadixTree implements a path-compressed trie mapping strings to int64s
+//that is safe for concurrent use. It has the same API as Trie, but
+//stores runs of runes that only one branch uses in a single node, keeps
+//children in a sorted slice instead of a map, and has no parent pointers,
+//so it uses much less memory for the same keys.
+type RadixTree struct {
+	mx   sync.RWMutex
+	root *radixNode
+	size int
+}
+
+//NewRadixTree constructs a new RadixTree.
+func NewRadixTree() *RadixTree {
+	return &RadixTree{root: &radixNode{}}
+}
+
+//Len returns the number of entries in the tree.
+func (rt *RadixTree) Len() int {
+	return rt.size
+}
+
+//Add adds a key and value to the tree.
+func (rt *RadixTree) Add(key string, value int64) {
+	rt.AddField(key, value, 0)
+}
+
+//AddField adds a key and value to the tree, recording
+//the field of the value that the key came from.
+func (rt *RadixTree) AddField(key string, value int64, field Field) {
+	rt.mx.Lock()
+	rt.add(key, value, field)
+	rt.mx.Unlock()
+}
+
+//add adds a key and value to the tree.
+//The caller must hold the tree's lock.
+func (rt *RadixTree) add(key string, value int64, field Field) {
+	runes := []rune(key)
+	node := rt.root
+	for len(runes) > 0 {
+		i, ok := node.child(runes[0])
+		if !ok {
+			leaf := &radixNode{label: runes}
+			node.children = append(node.children, nil)
+			copy(node.children[i+1:], node.children[i:])
+			node.children[i] = leaf
+			node = leaf
+			break
+		}
+		child := node.children[i]
+		common := commonPrefixLength(child.label, runes)
+		if common < len(child.label) {
+			//split the child's edge where the key leaves it
+			split := &radixNode{label: child.label[:common:common], children: []*radixNode{child}}
+			child.label = child.label[common:]
+			node.children[i] = split
+			child = split
+		}
+		node = child
+		runes = runes[common:]
+	}
+	if node.vals == nil {
+		node.vals = make(int64set)
+	}
+	if node.vals.add(value, field) {
+		rt.size++
+	}
+}
+
+//Find finds `max` values matching `prefix`, in the same order as
+//Trie.Find. If the tree is entirely empty, or the prefix is empty,
+//or max == 0, or the prefix is not found, this returns a nil slice.
+func (rt *RadixTree) Find(prefix string, max int) []int64 {
+	values, _ := rt.FindAfter(prefix, nil, max)
+	return values
+}
+
+//FindAfter finds up to `max` values matching `prefix` that come
+//after the cursor, like Trie.FindAfter.
+func (rt *RadixTree) FindAfter(prefix string, cursor *Cursor, max int) ([]int64, *Cursor) {
+	rt.mx.RLock()
+	defer rt.mx.RUnlock()
+	return rt.findAfterIf(prefix, cursor, max, nil)
+}
+
+//findAfterIf is like FindAfter, but leaves out values for which keep
+//returns false, unless it's nil. The caller must hold the tree's lock.
+func (rt *RadixTree) findAfterIf(prefix string, cursor *Cursor, max int, keep func(value int64) bool) ([]int64, *Cursor) {
+	if rt.Len() == 0 || len(prefix) == 0 || max <= 0 {
+		return nil, nil
+	}
+	node, extra := rt.locate(prefix)
+	if node == nil {
+		return nil, nil
+	}
+	//keys aren't all as long as their depth in the tree, so
+	//every key in the subtree is found, then sorted like a Trie's
+	type entry struct {
+		key    string
+		length int
+		vals   int64set
+	}
+	entries := []*entry{}
+	var walk func(node *radixNode, key []rune)
+	walk = func(node *radixNode, key []rune) {
+		if len(node.vals) > 0 {
+			entries = append(entries, &entry{string(key), len(key), node.vals})
+		}
+		for _, child := range node.children {
+			walk(child, append(key[:len(key):len(key)], child.label...))
+		}
+	}
+	start := []rune(prefix)
+	walk(node, append(start, node.label[len(node.label)-extra:]...))
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].length != entries[j].length {
+			return entries[i].length < entries[j].length
+		}
+		return entries[i].key < entries[j].key
+	})
+	visit := func(yield func(key string, vals int64set) bool) {
+		for _, e := range entries {
+			if !yield(e.key, e.vals) {
+				return
+			}
+		}
+	}
+	return findAfter(visit, cursor, max, lessInt64, keep)
+}
+
+//Remove removes a key/value pair from the tree
+//and merges or trims nodes left with no values.
+func (rt *RadixTree) Remove(key string, value int64) {
+	rt.mx.Lock()
+	rt.remove(key, value)
+	rt.mx.Unlock()
+}
+
+//remove removes a key/value pair from the tree.
+//The caller must hold the tree's lock.
+func (rt *RadixTree) remove(key string, value int64) {
+	runes := []rune(key)
+	path := []*radixNode{rt.root}
+	node := rt.root
+	for len(runes) > 0 {
+		i, ok := node.child(runes[0])
+		if !ok {
+			return
+		}
+		node = node.children[i]
+		if commonPrefixLength(node.label, runes) < len(node.label) {
+			return
+		}
+		runes = runes[len(node.label):]
+		path = append(path, node)
+	}
+	if !node.vals.remove(value) {
+		return
+	}
+	rt.size--
+	for i := len(path) - 1; i > 0; i-- {
+		path[i-1].compact(path[i])
+	}
+}
+
+//removeValues removes every key of the given values from the tree
+//and merges or trims nodes left with no values, visiting every node
+//once. The caller must hold the tree's lock.
+func (rt *RadixTree) removeValues(values map[int64]bool) {
+	var prune func(node *radixNode)
+	prune = func(node *radixNode) {
+		for value := range node.vals {
+			if values[value] {
+				node.vals.remove(value)
+				rt.size--
+			}
+		}
+		//children are visited from last to first,
+		//since compact may remove the current one
+		for i := len(node.children) - 1; i >= 0; i-- {
+			child := node.children[i]
+			prune(child)
+			node.compact(child)
+		}
+	}
+	prune(rt.root)
+}
+
+//each calls fn with every key and value in the tree and
+//the value's fields. The caller must hold the tree's lock.
+func (rt *RadixTree) each(fn func(key string, value int64, field Field)) {
+	var walk func(node *radixNode, key []rune)
+	walk = func(node *radixNode, key []rune) {
+		for value, field := range node.vals {
+			fn(string(key), value, field)
+		}
+		for _, child := range node.children {
+			walk(child, append(key[:len(key):len(key)], child.label...))
+		}
+	}
+	walk(rt.root, nil)
+}
+
+//locate returns the node with the shortest key beginning with `prefix`,
+//and how many runes longer than the prefix that key is, or nil if no
+//key begins with the prefix. The caller must hold the tree's lock.
+func (rt *RadixTree) locate(prefix string) (*radixNode, int) {
+	runes := []rune(prefix)
+	node := rt.root
+	for len(runes) > 0 {
+		i, ok := node.child(runes[0])
+		if !ok {
+			return nil, 0
+		}
+		child := node.children[i]
+		common := commonPrefixLength(child.label, runes)
+		if common == len(runes) {
+			return child, len(child.label) - common
+		}
+		if common < len(child.label) {
+			return nil, 0
+		}
+		node = child
+		runes = runes[common:]
+	}
+	return node, 0
+}
+
+//locker returns the lock guarding the tree
+func (rt *RadixTree) locker() *sync.RWMutex {
+	return &rt.mx
+}
+
+//child returns the index of the child whose label begins with the
+//rune, or where one would be inserted, and whether there is one
+func (node *radixNode) child(name rune) (int, bool) {
+	i := sort.Search(len(node.children), func(i int) bool {
+		return node.children[i].label[0] >= name
+	})
+	return i, i < len(node.children) && node.children[i].label[0] == name
+}
+
+//compact removes the child if it has no values or children,
+//or merges it with its only child if it has no values
+func (node *radixNode) compact(child *radixNode) {
+	if len(child.vals) > 0 {
+		return
+	}
+	child.vals = nil
+	i, ok := node.child(child.label[0])
+	if !ok || node.children[i] != child {
+		return
+	}
+	switch len(child.children) {
+	case 0:
+		node.children = append(node.children[:i], node.children[i+1:]...)
+	case 1:
+		grandchild := child.children[0]
+		label := make([]rune, 0, len(child.label)+len(grandchild.label))
+		grandchild.label = append(append(label, child.label...), grandchild.label...)
+		node.children[i] = grandchild
+	}
+}
+
+//commonPrefixLength returns the number of runes a and b begin with in common
+func commonPrefixLength(a []rune, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+//FindRanked finds up to `max` values whose keys begin with `prefix`,
+//ranked like Trie.FindRanked.
+func (rt *RadixTree) FindRanked(prefix string, max int, boost Boost) []int64 {
+	return rt.FindRankedAll([]string{prefix}, max, boost)
+}
+
+//FindRankedAll finds up to `max` values with a key beginning
+//with each of the prefixes, ranked like Trie.FindRankedAll.
+func (rt *RadixTree) FindRankedAll(prefixes []string, max int, boost Boost) []int64 {
+	rt.mx.RLock()
+	defer rt.mx.RUnlock()
+	if rt.Len() == 0 {
+		return nil
+	}
+	return rankAll(prefixes, rt.scorePrefix, max, boost, lessInt64)
+}
+
+//scorePrefix returns the relevance score of every value with a key
+//beginning with the prefix, like Trie.scorePrefix.
+//The caller must hold the tree's lock.
+func (rt *RadixTree) scorePrefix(prefix string) map[int64]float64 {
+	scores := make(map[int64]float64)
+	if len(prefix) == 0 {
+		return scores
+	}
+	node, extra := rt.locate(prefix)
+	if node == nil {
+		return scores
+	}
+	var walk func(node *radixNode, extra int)
+	walk = func(node *radixNode, extra int) {
+		for value, fields := range node.vals {
+			score := scoreKey(extra, fields)
+			if current, ok := scores[value]; !ok || score > current {
+				scores[value] = score
+			}
+		}
+		for _, child := range node.children {
+			walk(child, extra+len(child.label))
+		}
+	}
+	walk(node, extra)
+	return scores
+}
+
+//FindFuzzy finds up to `max` values whose keys are within maxDistance
+//edits of `query`, or begin with a string that is, ordered like
+//Trie.FindFuzzy.
+func (rt *RadixTree) FindFuzzy(query string, maxDistance int, max int) []int64 {
+	rt.mx.RLock()
+	defer rt.mx.RUnlock()
+	if rt.Len() == 0 || len(query) == 0 || max == 0 {
+		return nil
+	}
+	return rt.searchFuzzy(query, maxDistance).results(max)
+}
+
+//scoreFuzzy returns the relevance score of every value FindFuzzy
+//would find. The caller must hold the tree's lock.
+func (rt *RadixTree) scoreFuzzy(query string, maxDistance int) map[int64]float64 {
+	return rt.searchFuzzy(query, maxDistance).scores()
+}
+
+//searchFuzzy finds the best match of every value for FindFuzzy.
+//The caller must hold the tree's lock.
+func (rt *RadixTree) searchFuzzy(query string, maxDistance int) *fuzzySearch[int64] {
+	fs, row := newFuzzySearch(query, maxDistance, lessInt64)
+	if rt.Len() == 0 || len(query) == 0 {
+		return fs
+	}
+	n := len(fs.runes)
+	var walk func(node *radixNode, row []int, prefixDistance int)
+	walk = func(node *radixNode, row []int, prefixDistance int) {
+		if row[n] < prefixDistance {
+			prefixDistance = row[n]
+		}
+		fs.visit(node.vals, row, prefixDistance)
+		for _, child := range node.children {
+			//step through the label a rune at a time, as if each
+			//rune were a node with no values, as in a Trie
+			childRow, childPrefixDistance, ok := row, prefixDistance, true
+			for i, name := range child.label {
+				if i > 0 && childRow[n] < childPrefixDistance {
+					childPrefixDistance = childRow[n]
+				}
+				if childRow, ok = fs.step(childRow, name, childPrefixDistance); !ok {
+					break
+				}
+			}
+			if ok {
+				walk(child, childRow, childPrefixDistance)
+			}
+		}
+	}
+	walk(rt.root, row, n+1)
+	return fs
+}
+
+//radixMagic begins every radix tree snapshot, followed by the format version
+var radixMagic = []byte("RDIX")
+
+//radixVersion is the version of the radix tree snapshot format
+const radixVersion = 1
+
+//WriteTo writes a binary snapshot of the tree to w, which ReadRadixTree
+//reads back. Nodes are written depth-first as their label, their values
+//with the fields they were indexed from, and their number of children.
+func (rt *RadixTree) WriteTo(w io.Writer) (int64, error) {
+	rt.mx.RLock()
+	defer rt.mx.RUnlock()
+	sw := newSnapshotWriter(w)
+	sw.header(radixMagic, radixVersion, rt.size)
+	var write func(node *radixNode)
+	write = func(node *radixNode) {
+		sw.uvarint(uint64(len(node.label)))
+		for _, name := range node.label {
+			sw.uvarint(uint64(name))
+		}
+		writeValues(sw, node.vals, lessInt64)
+		sw.uvarint(uint64(len(node.children)))
+		for _, child := range node.children {
+			write(child)
+		}
+	}
+	write(rt.root)
+	return sw.flush()
+}
+
+//ReadRadixTree reads a tree from a snapshot written by RadixTree.WriteTo.
+//It returns ErrCorruptSnapshot if the snapshot is malformed.
+func ReadRadixTree(r io.Reader) (*RadixTree, error) {
+	sr := &snapshotReader{bufio.NewReader(r)}
+	_, size, err := sr.header(radixMagic, radixVersion, radixVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := NewRadixTree()
+	var read func(node *radixNode) error
+	read = func(node *radixNode) error {
+		length, err := sr.uvarint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < length; i++ {
+			name, err := sr.rune()
+			if err != nil {
+				return err
+			}
+			node.label = append(node.label, name)
+		}
+		vals := make(int64set)
+		added, err := readValues(sr, vals)
+		if err != nil {
+			return err
+		}
+		if len(vals) > 0 {
+			node.vals = vals
+			rt.size += added
+		}
+		numChildren, err := sr.uvarint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < numChildren; i++ {
+			child := &radixNode{}
+			if err := read(child); err != nil {
+				return err
+			}
+			//children must have labels, in order, for child to find them
+			last := len(node.children) - 1
+			if len(child.label) == 0 || (last >= 0 && child.label[0] <= node.children[last].label[0]) {
+				return ErrCorruptSnapshot
+			}
+			node.children = append(node.children, child)
+		}
+		return nil
+	}
+	if err := read(rt.root); err != nil {
+		return nil, err
+	}
+	if len(rt.root.label) > 0 || uint64(rt.size) != size {
+		return nil, ErrCorruptSnapshot
+	}
+	return rt, nil
+}
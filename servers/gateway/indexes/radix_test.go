@@ -0,0 +1,299 @@
+package indexes
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+func TestRadixTree(t *testing.T) {
+	rt := NewRadixTree()
+	rt.Add("go", 1)
+	rt.Add("git", 2)
+	rt.Add("gob", 3)
+	rt.Add("go", 4)
+	rt.Add("goal", 5)
+	rt.Add("foo", 1)
+	rt.Add("go", 1)
+	if rt.Len() != 6 {
+		t.Errorf("incorrect size: expected 6 but got %d", rt.Len())
+	}
+
+	cases := []struct {
+		name           string
+		prefix         string
+		max            int
+		expectedValues []int64
+	}{
+		{"Prefix Of Several Keys", "go", 10, []int64{1, 4, 3, 5}},
+		{"Prefix Within An Edge", "goa", 10, []int64{5}},
+		{"Single Result", "f", 1, []int64{1}},
+		{"Not Found", "x", 10, nil},
+		{"Leaves An Edge", "gox", 10, nil},
+		{"Limited", "g", 2, []int64{1, 4}},
+	}
+	for _, c := range cases {
+		if values := rt.Find(c.prefix, c.max); !reflect.DeepEqual(values, c.expectedValues) {
+			t.Errorf("case %s: expected values %v but got %v", c.name, c.expectedValues, values)
+		}
+	}
+
+	rt.Remove("gob", 3)
+	rt.Remove("go", 4)
+	rt.Remove("unknown", 1)
+	rt.Remove("goal", 20)
+	if values := rt.Find("go", 10); !reflect.DeepEqual(values, []int64{1, 5}) {
+		t.Errorf("after removing: expected [1 5] but got %v", values)
+	}
+	rt.Remove("go", 1)
+	//"go" and "al" should have been merged back into "goal"
+	g := rt.root.children[1]
+	if string(g.label) != "g" || len(g.children) != 2 || string(g.children[1].label) != "oal" {
+		t.Errorf("nodes weren't merged after removing: %q", string(g.children[1].label))
+	}
+	if rt.Len() != 3 {
+		t.Errorf("incorrect size after removing: expected 3 but got %d", rt.Len())
+	}
+	rt.Remove("goal", 5)
+	rt.Remove("git", 2)
+	rt.Remove("foo", 1)
+	if rt.Len() != 0 || len(rt.root.children) != 0 {
+		t.Errorf("tree isn't empty after removing every key: %d entries", rt.Len())
+	}
+}
+
+//randomKeys returns n random lowercase keys, sharing
+//prefixes often enough to exercise splits and merges
+func randomKeys(rnd *rand.Rand, n int) []string {
+	syllables := []string{"jo", "an", "smi", "th", "el", "ma", "ri", "é", "李", "o"}
+	keys := make([]string, n)
+	for i := range keys {
+		key := ""
+		for j := rnd.Intn(4) + 1; j > 0; j-- {
+			key += syllables[rnd.Intn(len(syllables))]
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func TestRadixTreeMatchesTrie(t *testing.T) {
+	rnd := rand.New(rand.NewSource(441))
+	trie := NewTrie()
+	rt := NewRadixTree()
+	keys := randomKeys(rnd, 500)
+	for i, key := range keys {
+		field := Field(1 << uint(rnd.Intn(2)))
+		trie.AddField(key, int64(i%200), field)
+		rt.AddField(key, int64(i%200), field)
+	}
+	removed := make(map[int64]bool)
+	for i, key := range keys {
+		switch rnd.Intn(4) {
+		case 0:
+			trie.Remove(key, int64(i%200))
+			rt.Remove(key, int64(i%200))
+		case 1:
+			removed[int64(i%200)] = true
+		}
+	}
+	trie.removeValues(removed)
+	rt.removeValues(removed)
+
+	if rt.Len() != trie.Len() {
+		t.Fatalf("incorrect size: expected %d but got %d", trie.Len(), rt.Len())
+	}
+	for _, query := range append(randomKeys(rnd, 100), "j", "sm", "李") {
+		if expected, found := trie.FindRanked(query, 10, nil), rt.FindRanked(query, 10, nil); !reflect.DeepEqual(found, expected) {
+			t.Errorf("ranked search for %q: expected %v but got %v", query, expected, found)
+		}
+		if expected, found := trie.FindFuzzy(query, 1, 10), rt.FindFuzzy(query, 1, 10); !reflect.DeepEqual(found, expected) {
+			t.Errorf("fuzzy search for %q: expected %v but got %v", query, expected, found)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := rt.WriteTo(buf); err != nil {
+		t.Fatalf("unexpected error writing snapshot: %v", err)
+	}
+	snapshot := buf.Bytes()
+	if _, err := ReadTrie(bytes.NewReader(snapshot)); err == nil {
+		t.Errorf("expected error reading a radix tree snapshot as a trie")
+	}
+	read, err := ReadRadixTree(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	again := &bytes.Buffer{}
+	read.WriteTo(again)
+	if !bytes.Equal(again.Bytes(), snapshot) || read.Len() != rt.Len() {
+		t.Errorf("tree read from snapshot differs from the original")
+	}
+	if expected, found := rt.FindRanked("jo", 10, nil), read.FindRanked("jo", 10, nil); !reflect.DeepEqual(found, expected) {
+		t.Errorf("search of tree read from snapshot: expected %v but got %v", expected, found)
+	}
+	if _, err := ReadRadixTree(bytes.NewReader(snapshot[:len(snapshot)-1])); err == nil {
+		t.Errorf("expected error reading a truncated snapshot")
+	}
+}
+
+func TestNewIndex(t *testing.T) {
+	for _, kind := range []string{KindTrie, KindRadix} {
+		index, err := NewIndex(kind)
+		if err != nil {
+			t.Fatalf("unexpected error for kind %s: %v", kind, err)
+		}
+		indexer := NewIndexer(index)
+		if err := indexer.Reconcile(func(fn func(user *users.User)) error { return nil }); err != nil {
+			t.Errorf("unexpected error reconciling %s: %v", kind, err)
+		}
+		if reflect.TypeOf(indexer.current()) != reflect.TypeOf(index) {
+			t.Errorf("reconciling changed the kind of index from %T to %T", index, indexer.current())
+		}
+	}
+	if _, err := NewIndex("btree"); err == nil {
+		t.Errorf("expected error for an unknown kind")
+	}
+}
+
+func TestIndexFindAfter(t *testing.T) {
+	for _, kind := range []string{KindTrie, KindRadix} {
+		index, _ := NewIndex(kind)
+		index.Add("go", 1)
+		index.Add("git", 2)
+		index.Add("gob", 3)
+		index.Add("go", 4)
+		index.Add("goal", 5)
+		index.Add("gob", 1)
+		index.Add("gobi", 6)
+
+		var pages [][]int64
+		var cursor *Cursor
+		for {
+			var values []int64
+			values, cursor = index.FindAfter("g", cursor, 2)
+			pages = append(pages, values)
+			if cursor == nil {
+				break
+			}
+		}
+		//1 is found at "go" and "gob", but is only returned once
+		expected := [][]int64{{1, 4}, {2, 3}, {5, 6}}
+		if !reflect.DeepEqual(pages, expected) {
+			t.Errorf("%s: expected pages %v but got %v", kind, expected, pages)
+		}
+		if values, next := index.FindAfter("go", &Cursor{Key: "gob", Value: 3}, 10); !reflect.DeepEqual(values, []int64{5, 6}) || next != nil {
+			t.Errorf("%s: expected [5 6] after gob/3 but got %v, %v", kind, values, next)
+		}
+		if values, next := index.FindAfter("g", &Cursor{Key: "gobi", Value: 6}, 10); values != nil || next != nil {
+			t.Errorf("%s: expected nothing after the last value but got %v, %v", kind, values, next)
+		}
+	}
+}
+
+//benchmarkIndexes are the kinds of index the benchmarks compare
+var benchmarkIndexes = []struct {
+	name     string
+	newIndex func() Index
+}{
+	{"Trie", func() Index { return NewTrie() }},
+	{"RadixTree", func() Index { return NewRadixTree() }},
+	{"NGramTrie", func() Index { return NewNGramIndex(NewTrie()) }},
+	{"NGramRadixTree", func() Index { return NewNGramIndex(NewRadixTree()) }},
+}
+
+//benchmarkKeys returns realistic keys: n words of names and user names
+func benchmarkKeys(n int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	keys := make([]string, n)
+	for i := range keys {
+		key := make([]byte, 3+rnd.Intn(8))
+		for j := range key {
+			key[j] = letters[rnd.Intn(len(letters))]
+		}
+		keys[i] = string(key)
+	}
+	return keys
+}
+
+func BenchmarkIndexMemory(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	for _, bi := range benchmarkIndexes {
+		b.Run(bi.name, func(b *testing.B) {
+			var bytesPerKey float64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				index := bi.newIndex()
+				for id, key := range keys {
+					index.Add(key, int64(id))
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				bytesPerKey = float64(after.HeapAlloc-before.HeapAlloc) / float64(len(keys))
+				runtime.KeepAlive(index)
+			}
+			b.ReportMetric(bytesPerKey, "bytes/key")
+		})
+	}
+}
+
+func BenchmarkIndexAdd(b *testing.B) {
+	keys := benchmarkKeys(10000)
+	for _, bi := range benchmarkIndexes {
+		b.Run(bi.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				index := bi.newIndex()
+				for id, key := range keys {
+					index.Add(key, int64(id))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkIndexFind(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	for _, bi := range benchmarkIndexes {
+		index := bi.newIndex()
+		for id, key := range keys {
+			index.Add(key, int64(id))
+		}
+		for _, length := range []int{1, 3, 6} {
+			b.Run(fmt.Sprintf("%s/Prefix%d", bi.name, length), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					key := keys[i%len(keys)]
+					if len(key) > length {
+						key = key[:length]
+					}
+					index.FindRanked(key, 20, nil)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkIndexFindFuzzy(b *testing.B) {
+	keys := benchmarkKeys(100000)
+	for _, bi := range benchmarkIndexes {
+		index := bi.newIndex()
+		for id, key := range keys {
+			index.Add(key, int64(id))
+		}
+		b.Run(bi.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				index.FindFuzzy(keys[i%len(keys)], 1, 20)
+			}
+		})
+	}
+}
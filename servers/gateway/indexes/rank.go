@@ -0,0 +1,158 @@
+package indexes
+
+import "sort"
+
+//Field identifies the field of a value that a key was indexed from.
+//Fields are bit flags, since the same key can come from several fields.
+type Field uint8
+
+//The fields of a user that are indexed
+const (
+	//FieldName is a word of a user's first or last name
+	FieldName Field = 1 << iota
+	//FieldUserName is a user name
+	FieldUserName
+)
+
+//The parts of a value's relevance score. An exact key match
+//outranks a prefix match, and a user name outranks a name. Within
+//those, shorter keys score up to keyLengthScore more than longer ones.
+const (
+	exactMatchScore = 4
+	userNameScore   = 2
+	keyLengthScore  = 1
+)
+
+//typoScore is subtracted from the score of a fuzzy match for each
+//edit. It's more than exactMatchScore plus a small boost, so that
+//a closer match outranks a whole key, or a contact.
+const typoScore = 8
+
+//BoostOf returns an extra relevance score for a value, such as
+//for users who are contacts of the user who is searching
+type BoostOf[V comparable] func(value V) float64
+
+//Boost returns an extra relevance score for an int64 value
+type Boost = BoostOf[int64]
+
+//rankedValue is a value found by FindRanked and its score
+type rankedValue[V comparable] struct {
+	value V
+	score float64
+}
+
+//FindRanked finds up to `max` values whose keys begin with `prefix`,
+//ordered by relevance: exact key matches before prefix matches, user
+//names before names, and shorter keys before longer ones, plus the
+//boost, if it isn't nil. Values with equal scores are ordered by the
+//trie's less function, so the same query always returns the same results. If the trie is
+//empty, the prefix is empty, or max == 0, this returns a nil slice.
+func (t *TrieOf[V]) FindRanked(prefix string, max int, boost BoostOf[V]) []V {
+	return t.FindRankedAll([]string{prefix}, max, boost)
+}
+
+//FindRankedAll is like FindRanked, but finds values with a key beginning
+//with each of the prefixes, such as each word of a query. A value's
+//score is the sum of its scores for each prefix. If nothing matches
+//every prefix, this returns a nil slice.
+func (t *TrieOf[V]) FindRankedAll(prefixes []string, max int, boost BoostOf[V]) []V {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	if t.Len() == 0 {
+		return nil
+	}
+	return rankAll(prefixes, t.scorePrefix, max, boost, t.less)
+}
+
+//rankAll returns up to `max` of the values that scorePrefix scores for
+//every prefix, ordered by the sum of their scores plus the boost, then by less
+func rankAll[V comparable](prefixes []string, scorePrefix func(prefix string) map[V]float64, max int, boost BoostOf[V], less func(a, b V) bool) []V {
+	if len(prefixes) == 0 || max == 0 {
+		return nil
+	}
+	var scores map[V]float64
+	for _, prefix := range prefixes {
+		prefixScores := scorePrefix(prefix)
+		if scores == nil {
+			scores = prefixScores
+			continue
+		}
+		for value, score := range scores {
+			if prefixScore, ok := prefixScores[value]; ok {
+				scores[value] = score + prefixScore
+			} else {
+				delete(scores, value)
+			}
+		}
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+
+	ranked := make([]*rankedValue[V], 0, len(scores))
+	for value, score := range scores {
+		if boost != nil {
+			score += boost(value)
+		}
+		ranked = append(ranked, &rankedValue[V]{value, score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return less(ranked[i].value, ranked[j].value)
+	})
+	if len(ranked) > max {
+		ranked = ranked[:max]
+	}
+	values := make([]V, len(ranked))
+	for i, rv := range ranked {
+		values[i] = rv.value
+	}
+	return values
+}
+
+//scoreKey returns the score of a value whose key is `extra` runes
+//longer than the prefix it matched, and was indexed from the fields
+func scoreKey(extra int, fields Field) float64 {
+	score := keyLengthScore / float64(extra+1)
+	if extra == 0 {
+		score += exactMatchScore
+	}
+	if fields&FieldUserName != 0 {
+		score += userNameScore
+	}
+	return score
+}
+
+//scorePrefix returns the relevance score of every value with a key
+//beginning with the prefix. Every value in the prefix's subtree
+//matches, so each is scored by the best of its matching keys.
+//The caller must hold the trie's lock.
+func (t *TrieOf[V]) scorePrefix(prefix string) map[V]float64 {
+	scores := make(map[V]float64)
+	if len(prefix) == 0 {
+		return scores
+	}
+	node := t.Root
+	for _, name := range prefix {
+		node = node.children[name]
+		if node == nil {
+			return scores
+		}
+	}
+	var walk func(node *trieNode[V], extra int)
+	walk = func(node *trieNode[V], extra int) {
+		for value, fields := range node.vals {
+			score := scoreKey(extra, fields)
+			if current, ok := scores[value]; !ok || score > current {
+				scores[value] = score
+			}
+		}
+		for _, child := range node.children {
+			walk(child, extra+1)
+		}
+	}
+	walk(node, 0)
+	return scores
+}
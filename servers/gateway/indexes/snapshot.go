@@ -0,0 +1,400 @@
+package indexes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+	"unicode"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//trieMagic begins every trie snapshot, followed by the format version
+var trieMagic = []byte("TRIE")
+
+//trieVersion is the version of the trie snapshot format. Version 2
+//added the kind of values, so tries of strings can be written too.
+//Version 1 snapshots, which only hold int64 values, can still be read.
+const trieVersion = 2
+
+//ErrCorruptSnapshot is returned when a snapshot can't be read, or
+//is a snapshot of a different kind of index than the one being read
+var ErrCorruptSnapshot = errors.New("corrupt snapshot")
+
+//snapshotWriter writes the parts of an index snapshot. It keeps the
+//first error, so only the error from flush needs to be checked.
+type snapshotWriter struct {
+	w   io.Writer
+	bw  *bufio.Writer
+	n   int64
+	buf []byte
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	sw := &snapshotWriter{w: w, buf: make([]byte, binary.MaxVarintLen64)}
+	sw.bw = bufio.NewWriter(sw)
+	return sw
+}
+
+//Write writes to the underlying writer, counting the bytes written
+func (sw *snapshotWriter) Write(p []byte) (int, error) {
+	n, err := sw.w.Write(p)
+	sw.n += int64(n)
+	return n, err
+}
+
+//header writes the magic bytes, format version and number of entries
+func (sw *snapshotWriter) header(magic []byte, version int, size int) {
+	sw.bw.Write(magic)
+	sw.uvarint(uint64(version))
+	sw.uvarint(uint64(size))
+}
+
+func (sw *snapshotWriter) uvarint(v uint64) {
+	sw.bw.Write(sw.buf[:binary.PutUvarint(sw.buf, v)])
+}
+
+//Kinds of values a snapshot can hold
+const (
+	valueKindInt64  = 1
+	valueKindString = 2
+)
+
+//valueKind returns the kind of values of type V
+//written in snapshots, or 0 if they can't be
+func valueKind[V comparable]() byte {
+	var v V
+	switch any(v).(type) {
+	case int64:
+		return valueKindInt64
+	case string:
+		return valueKindString
+	}
+	return 0
+}
+
+//writeValues writes the number of values and each value with its
+//fields, ordered by less, so equal indexes have equal snapshots
+func writeValues[V comparable](sw *snapshotWriter, vals valueSet[V], less func(a, b V) bool) {
+	values := vals.sorted(less)
+	sw.uvarint(uint64(len(values)))
+	for _, value := range values {
+		switch v := any(value).(type) {
+		case int64:
+			sw.bw.Write(sw.buf[:binary.PutVarint(sw.buf, v)])
+		case string:
+			sw.uvarint(uint64(len(v)))
+			sw.bw.WriteString(v)
+		}
+		sw.bw.WriteByte(byte(vals[value]))
+	}
+}
+
+//flush writes any buffered data and returns the
+//number of bytes written and the first error
+func (sw *snapshotWriter) flush() (int64, error) {
+	err := sw.bw.Flush()
+	return sw.n, err
+}
+
+//snapshotReader reads the parts of an index snapshot
+type snapshotReader struct {
+	br *bufio.Reader
+}
+
+//header reads the magic bytes and format version, returning an
+//error unless the magic bytes match and the version is between
+//oldest and newest, and returns the version and number of entries
+func (sr *snapshotReader) header(magic []byte, oldest int, newest int) (int, uint64, error) {
+	actual := make([]byte, len(magic))
+	if _, err := io.ReadFull(sr.br, actual); err != nil || !bytes.Equal(actual, magic) {
+		return 0, 0, ErrCorruptSnapshot
+	}
+	version, err := sr.uvarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if version < uint64(oldest) || version > uint64(newest) {
+		return 0, 0, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	size, err := sr.uvarint()
+	return int(version), size, err
+}
+
+func (sr *snapshotReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(sr.br)
+	if err != nil {
+		return 0, ErrCorruptSnapshot
+	}
+	return v, nil
+}
+
+//rune reads a rune, returning an error if it isn't valid
+func (sr *snapshotReader) rune() (rune, error) {
+	v, err := sr.uvarint()
+	if err != nil || v > unicode.MaxRune {
+		return 0, ErrCorruptSnapshot
+	}
+	return rune(v), nil
+}
+
+//maxStringValueLength is the longest string value a snapshot may hold,
+//so a corrupt length can't make readValues allocate a huge buffer
+const maxStringValueLength = 1 << 16
+
+//readValues reads values with their fields into vals,
+//returning the number of values that were new
+func readValues[V comparable](sr *snapshotReader, vals valueSet[V]) (int, error) {
+	count, err := sr.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for i := uint64(0); i < count; i++ {
+		var value V
+		switch v := any(&value).(type) {
+		case *int64:
+			if *v, err = binary.ReadVarint(sr.br); err != nil {
+				return 0, ErrCorruptSnapshot
+			}
+		case *string:
+			length, err := sr.uvarint()
+			if err != nil || length > maxStringValueLength {
+				return 0, ErrCorruptSnapshot
+			}
+			buf := make([]byte, length)
+			if _, err := io.ReadFull(sr.br, buf); err != nil {
+				return 0, ErrCorruptSnapshot
+			}
+			*v = string(buf)
+		}
+		field, err := sr.br.ReadByte()
+		if err != nil {
+			return 0, ErrCorruptSnapshot
+		}
+		if vals.add(value, Field(field)) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+//WriteTo writes a binary snapshot of the trie to w, which ReadTrieOf
+//reads back. The kind of values follows the header, then nodes are
+//written depth-first as their rune, their values with the fields they
+//were indexed from, and their number of children. Values and children
+//are written in order, so equal tries have equal snapshots. Only tries
+//of int64 or string values can be written.
+func (t *TrieOf[V]) WriteTo(w io.Writer) (int64, error) {
+	kind := valueKind[V]()
+	if kind == 0 {
+		var v V
+		return 0, fmt.Errorf("can't write snapshots of %T values", v)
+	}
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	sw := newSnapshotWriter(w)
+	sw.header(trieMagic, trieVersion, t.Size)
+	sw.bw.WriteByte(kind)
+	var write func(node *trieNode[V])
+	write = func(node *trieNode[V]) {
+		sw.uvarint(uint64(node.name))
+		writeValues(sw, node.vals, t.less)
+		names := make([]rune, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+		sw.uvarint(uint64(len(names)))
+		for _, name := range names {
+			write(node.children[name])
+		}
+	}
+	write(t.Root)
+	return sw.flush()
+}
+
+//ReadTrie reads a Trie from a snapshot written by Trie.WriteTo.
+//It returns ErrCorruptSnapshot if the snapshot is malformed.
+func ReadTrie(r io.Reader) (*Trie, error) {
+	return ReadTrieOf(r, lessInt64)
+}
+
+//ReadTrieOf reads a TrieOf values ordered by less from a snapshot
+//written by TrieOf.WriteTo. It returns ErrCorruptSnapshot if the
+//snapshot is malformed or holds a different kind of values.
+func ReadTrieOf[V comparable](r io.Reader, less func(a, b V) bool) (*TrieOf[V], error) {
+	sr := &snapshotReader{bufio.NewReader(r)}
+	version, size, err := sr.header(trieMagic, 1, trieVersion)
+	if err != nil {
+		return nil, err
+	}
+	//version 1 snapshots have no kind, since they only held int64 values
+	kind := byte(valueKindInt64)
+	if version > 1 {
+		if kind, err = sr.br.ReadByte(); err != nil {
+			return nil, ErrCorruptSnapshot
+		}
+	}
+	if kind != valueKind[V]() {
+		return nil, ErrCorruptSnapshot
+	}
+
+	t := NewTrieOf(less)
+	var read func(node *trieNode[V]) error
+	read = func(node *trieNode[V]) error {
+		added, err := readValues(sr, node.vals)
+		if err != nil {
+			return err
+		}
+		t.Size += added
+		numChildren, err := sr.uvarint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < numChildren; i++ {
+			name, err := sr.rune()
+			if err != nil || node.children[name] != nil {
+				return ErrCorruptSnapshot
+			}
+			node.newChild(name)
+			if err := read(node.children[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	//the root has no rune, but one is written for it
+	//so that every node is written the same way
+	if _, err := sr.uvarint(); err != nil {
+		return nil, err
+	}
+	t.Root.vals = make(valueSet[V])
+	if err := read(t.Root); err != nil {
+		return nil, err
+	}
+	if uint64(t.Size) != size {
+		return nil, ErrCorruptSnapshot
+	}
+	return t, nil
+}
+
+//indexMagic begins every index snapshot, followed by the
+//time it was taken and the snapshot of its Index
+var indexMagic = []byte("UIDX")
+
+//catchUpSlack is how long before a snapshot was taken CatchUp looks
+//for changes, since the times changes are recorded at come from the
+//clocks of every replica, which may be a little ahead of this one's
+const catchUpSlack = time.Minute
+
+//SaveSnapshot writes a snapshot of the index to the file at path, along
+//with the time it was taken, for LoadSnapshot to read when the gateway
+//next starts. The file is replaced at once, so it's never partly written.
+func (ix *Indexer) SaveSnapshot(path string) error {
+	//the time is taken before the index is written, so any change
+	//missing from the snapshot was made after it, and CatchUp finds it
+	takenAt := time.Now()
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".snapshot-")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %v", err)
+	}
+	bw := bufio.NewWriter(tmp)
+	bw.Write(indexMagic)
+	binary.Write(bw, binary.BigEndian, takenAt.Unix())
+	_, err = ix.current().WriteTo(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing snapshot: %v", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+//LoadSnapshot replaces the index with the snapshot in the file at path
+//and returns the time the snapshot was taken, to pass to CatchUp. The
+//snapshot must be of the same kind of Index as the one being replaced.
+func (ix *Indexer) LoadSnapshot(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic := make([]byte, len(indexMagic))
+	var takenAt int64
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, indexMagic) {
+		return time.Time{}, ErrCorruptSnapshot
+	}
+	if err := binary.Read(br, binary.BigEndian, &takenAt); err != nil {
+		return time.Time{}, ErrCorruptSnapshot
+	}
+	index, err := readIndex(ix.current(), br)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ix.mx.Lock()
+	ix.index = index
+	ix.mx.Unlock()
+	return time.Unix(takenAt, 0), nil
+}
+
+//CatchUp applies the changes made since a snapshot was taken to the
+//index. forEachChange, such as users.SQLStore.ForEachChangeSince,
+//calls updated with every user that changed since the given time,
+//and deleted with the ID of every user deleted since then. It's
+//meant for startup, before the index is ever reconciled.
+func (ix *Indexer) CatchUp(since time.Time, forEachChange func(since time.Time, updated func(user *users.User), deleted func(id int64)) error) error {
+	changed := make(map[int64]bool)
+	updated := []*users.User{}
+	err := forEachChange(since.Add(-catchUpSlack), func(user *users.User) {
+		changed[user.ID] = true
+		updated = append(updated, user)
+	}, func(id int64) {
+		changed[id] = true
+	})
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	//the keys users were indexed by before they changed
+	//aren't known, so they're removed wherever they are
+	ix.mx.Lock()
+	defer ix.mx.Unlock()
+	ix.index.locker().Lock()
+	ix.index.removeValues(changed)
+	ix.index.locker().Unlock()
+	for _, user := range updated {
+		(&Mutation{New: user}).applyTo(ix.index)
+	}
+	return nil
+}
+
+//SnapshotEvery calls SaveSnapshot with path at every interval, forever
+func (ix *Indexer) SnapshotEvery(interval time.Duration, path string) {
+	for range time.Tick(interval) {
+		if err := ix.SaveSnapshot(path); err != nil {
+			log.Printf("Error saving user index snapshot: %s", err.Error())
+		}
+	}
+}
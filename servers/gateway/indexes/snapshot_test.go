@@ -0,0 +1,137 @@
+package indexes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+func TestTrieSnapshot(t *testing.T) {
+	trie := NewTrie()
+	trie.AddField("john", 1, FieldName)
+	trie.AddField("johnny", 2, FieldUserName)
+	trie.AddField("johnny", 3, FieldName)
+	trie.AddField("josé", 3, FieldName)
+	trie.AddField("李", 4, FieldName)
+
+	buf := &bytes.Buffer{}
+	n, err := trie.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("unexpected error writing snapshot: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("incorrect length written: reported %d but wrote %d", n, buf.Len())
+	}
+	snapshot := buf.Bytes()
+
+	read, err := ReadTrie(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	if read.Len() != trie.Len() {
+		t.Errorf("incorrect length: expected %d but got %d", trie.Len(), read.Len())
+	}
+	for _, prefix := range []string{"jo", "johnny", "josé", "李"} {
+		expected := trie.FindRanked(prefix, 10, nil)
+		if found := read.FindRanked(prefix, 10, nil); !reflect.DeepEqual(found, expected) {
+			t.Errorf("search for %q: expected %v but got %v", prefix, expected, found)
+		}
+	}
+	again := &bytes.Buffer{}
+	read.WriteTo(again)
+	if !bytes.Equal(again.Bytes(), snapshot) {
+		t.Errorf("snapshot of the read trie differs from the original")
+	}
+
+	//a version 1 snapshot is the same, without the kind of values
+	//that follows the header, since it could only hold int64 values
+	headerLength := len(trieMagic) + 2
+	v1 := append([]byte{}, trieMagic...)
+	v1 = append(v1, 1, snapshot[len(trieMagic)+1])
+	v1 = append(v1, snapshot[headerLength+1:]...)
+	if read, err := ReadTrie(bytes.NewReader(v1)); err != nil {
+		t.Errorf("unexpected error reading version 1 snapshot: %v", err)
+	} else if found := read.FindRanked("jo", 10, nil); !reflect.DeepEqual(found, trie.FindRanked("jo", 10, nil)) {
+		t.Errorf("search of version 1 snapshot: expected %v but got %v", trie.FindRanked("jo", 10, nil), found)
+	}
+	if _, err := ReadTrieOf(bytes.NewReader(v1), func(a, b string) bool { return a < b }); err != ErrCorruptSnapshot {
+		t.Errorf("expected ErrCorruptSnapshot reading version 1 snapshot of strings but got %v", err)
+	}
+
+	corrupt := [][]byte{
+		nil,
+		[]byte("NOPE"),
+		snapshot[:len(snapshot)-1],
+		append(append([]byte{}, snapshot[:len(trieMagic)+1]...), 99),
+	}
+	for i, data := range corrupt {
+		if _, err := ReadTrie(bytes.NewReader(data)); err == nil {
+			t.Errorf("corrupt snapshot %d: expected error but didn't get one", i)
+		}
+	}
+}
+
+func TestIndexerSnapshotCatchUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.idx")
+
+	indexer := NewIndexer(NewTrie())
+	john := &users.User{ID: 1, UserName: "jsmith", FirstName: "John", LastName: "Smith"}
+	jane := &users.User{ID: 2, UserName: "jdoe", FirstName: "Jane", LastName: "Doe"}
+	indexer.IndexUser(john)
+	indexer.IndexUser(jane)
+	if err := indexer.SaveSnapshot(path); err != nil {
+		t.Fatalf("unexpected error saving snapshot: %v", err)
+	}
+
+	restarted := NewIndexer(NewTrie())
+	takenAt, err := restarted.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading snapshot: %v", err)
+	}
+	if time.Since(takenAt) > time.Minute {
+		t.Errorf("incorrect snapshot time: %v", takenAt)
+	}
+	if found := restarted.Search("smith", 10, nil); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("search of loaded snapshot: expected [1] but got %v", found)
+	}
+
+	//john was renamed and jane deleted after the snapshot was taken
+	var requestedSince time.Time
+	forEachChange := func(since time.Time, updated func(user *users.User), deleted func(id int64)) error {
+		requestedSince = since
+		updated(&users.User{ID: 1, UserName: "jsmith", FirstName: "Jon", LastName: "Smithers"})
+		updated(&users.User{ID: 3, UserName: "newbie", FirstName: "New", LastName: "User"})
+		deleted(2)
+		return nil
+	}
+	if err := restarted.CatchUp(takenAt, forEachChange); err != nil {
+		t.Fatalf("unexpected error catching up: %v", err)
+	}
+	if !requestedSince.Before(takenAt) {
+		t.Errorf("changes weren't requested from before the snapshot: %v", requestedSince)
+	}
+	expected := map[string][]int64{"john": nil, "smithers": {1}, "jane": nil, "newbie": {3}}
+	for query, ids := range expected {
+		if found := restarted.Search(query, 10, nil); !reflect.DeepEqual(found, ids) {
+			t.Errorf("search for %q: expected %v but got %v", query, ids, found)
+		}
+	}
+	if restarted.Len() != 6 {
+		t.Errorf("incorrect number of entries: expected 6 but got %d", restarted.Len())
+	}
+
+	if _, err := restarted.LoadSnapshot(filepath.Join(dir, "missing.idx")); !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error for a missing snapshot but got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package indexes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+	"github.com/go-redis/redis"
+)
+
+//DefaultSyncChannel is the redis channel index mutations are published on
+const DefaultSyncChannel = "userindex"
+
+//Mutation is a change to the index of users. Old is the user as it was
+//indexed and New is the user as it should be, so adding a user has a nil
+//Old, and removing a user has a nil New. Only indexed fields are kept.
+type Mutation struct {
+	Old *users.User `json:"old,omitempty"`
+	New *users.User `json:"new,omitempty"`
+}
+
+//MutationPublisher sends index mutations to the other gateway replicas
+type MutationPublisher interface {
+	Publish(m *Mutation) error
+}
+
+//applyTo removes the old user's keys from the index and adds the new
+//user's keys, holding the index's lock so the change is made at once
+func (m *Mutation) applyTo(index Index) {
+	index.locker().Lock()
+	defer index.locker().Unlock()
+	if m.Old != nil {
+		for _, k := range userKeys(m.Old) {
+			index.remove(k.key, m.Old.ID)
+		}
+	}
+	if m.New != nil {
+		for _, k := range userKeys(m.New) {
+			index.add(k.key, m.New.ID, k.field)
+		}
+	}
+}
+
+//indexedFields returns a copy of the user with only the fields
+//that are indexed, so no private fields are ever published
+func indexedFields(user *users.User) *users.User {
+	return &users.User{
+		ID:        user.ID,
+		UserName:  user.UserName,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}
+}
+
+//syncMessage is a mutation as it is published, along
+//with the replica it came from
+type syncMessage struct {
+	Origin   string    `json:"origin"`
+	Mutation *Mutation `json:"mutation"`
+}
+
+//RedisSync publishes index mutations over redis pub/sub
+//and applies the mutations published by other replicas
+type RedisSync struct {
+	client  *redis.Client
+	channel string
+	//origin identifies this replica, so it can
+	//ignore the mutations it published itself
+	origin string
+}
+
+//NewRedisSync constructs a new RedisSync publishing
+//and listening on the redis channel
+func NewRedisSync(client *redis.Client, channel string) *RedisSync {
+	if client == nil {
+		panic("nil redis client")
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprintf("error generating replica origin: %v", err))
+	}
+	return &RedisSync{
+		client:  client,
+		channel: channel,
+		origin:  hex.EncodeToString(id),
+	}
+}
+
+//Publish publishes the mutation to the other replicas
+func (rs *RedisSync) Publish(m *Mutation) error {
+	data, err := json.Marshal(&syncMessage{Origin: rs.origin, Mutation: m})
+	if err != nil {
+		return fmt.Errorf("error encoding mutation: %v", err)
+	}
+	return rs.client.Publish(rs.channel, data).Err()
+}
+
+//Listen applies the mutations published by other replicas
+//to the indexer, until the redis client is closed
+func (rs *RedisSync) Listen(indexer *Indexer) {
+	pubsub := rs.client.Subscribe(rs.channel)
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		if err := rs.handle(msg.Payload, indexer); err != nil {
+			log.Printf("Error applying index mutation: %s", err.Error())
+		}
+	}
+}
+
+//handle applies the mutation published in payload to the indexer
+//without publishing it again, unless this replica published it
+func (rs *RedisSync) handle(payload string, indexer *Indexer) error {
+	msg := &syncMessage{}
+	if err := json.Unmarshal([]byte(payload), msg); err != nil {
+		return fmt.Errorf("error decoding mutation: %v", err)
+	}
+	if msg.Origin == rs.origin {
+		return nil
+	}
+	if msg.Mutation == nil || (msg.Mutation.Old == nil && msg.Mutation.New == nil) {
+		return fmt.Errorf("empty mutation")
+	}
+	indexer.apply(msg.Mutation)
+	return nil
+}
@@ -0,0 +1,138 @@
+package indexes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//fakePublisher sends mutations straight to the other replicas' indexers
+type fakePublisher struct {
+	origin    string
+	published []*Mutation
+	replicas  []*RedisSync
+	indexers  []*Indexer
+}
+
+func (fp *fakePublisher) Publish(m *Mutation) error {
+	fp.published = append(fp.published, m)
+	payload, err := json.Marshal(&syncMessage{Origin: fp.origin, Mutation: m})
+	if err != nil {
+		return err
+	}
+	for i, replica := range fp.replicas {
+		if err := replica.handle(string(payload), fp.indexers[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestIndexerSync(t *testing.T) {
+	pubA := &fakePublisher{origin: "a"}
+	pubB := &fakePublisher{origin: "b"}
+	replicaA := NewSyncedIndexer(NewTrie(), pubA)
+	replicaB := NewSyncedIndexer(NewTrie(), pubB)
+	syncA := &RedisSync{origin: "a"}
+	syncB := &RedisSync{origin: "b"}
+	pubA.replicas, pubA.indexers = []*RedisSync{syncB, syncA}, []*Indexer{replicaB, replicaA}
+	pubB.replicas, pubB.indexers = []*RedisSync{syncA}, []*Indexer{replicaA}
+
+	john := &users.User{ID: 1, Email: "john@example.com", UserName: "jsmith", FirstName: "John", LastName: "Smith"}
+	replicaA.IndexUser(john)
+	if found := replicaB.Search("john", 10, nil); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("user indexed on A wasn't found on B: got %v", found)
+	}
+	if len(pubB.published) != 0 {
+		t.Errorf("B republished a mutation it received: %v", pubB.published)
+	}
+	//A also receives its own mutation, which it must ignore
+	if replicaA.Len() != 3 {
+		t.Errorf("A applied its own mutation twice: expected 3 entries but got %d", replicaA.Len())
+	}
+	if pubA.published[0].New.Email != "" {
+		t.Errorf("published mutation contains private fields: %+v", pubA.published[0].New)
+	}
+
+	renamed := &users.User{ID: 1, UserName: "jsmith", FirstName: "Jon", LastName: "Smith"}
+	replicaB.ReindexUser(john, renamed)
+	if found := replicaA.Search("john", 10, nil); len(found) != 0 {
+		t.Errorf("user reindexed on B still found on A by old name: got %v", found)
+	}
+	replicaA.RemoveUser(renamed)
+	if replicaB.Len() != 0 {
+		t.Errorf("user removed on A still indexed on B: %d entries", replicaB.Len())
+	}
+}
+
+func TestRedisSyncHandle(t *testing.T) {
+	cases := []struct {
+		name        string
+		payload     string
+		expectError bool
+		expectLen   int
+	}{
+		{"Valid Mutation", `{"origin":"other","mutation":{"new":{"id":1,"userName":"jsmith"}}}`, false, 1},
+		{"Own Mutation", `{"origin":"self","mutation":{"new":{"id":1,"userName":"jsmith"}}}`, false, 0},
+		{"Empty Mutation", `{"origin":"other","mutation":{}}`, true, 0},
+		{"Invalid JSON", `not json`, true, 0},
+	}
+	for _, c := range cases {
+		indexer := NewIndexer(NewTrie())
+		rs := &RedisSync{origin: "self"}
+		err := rs.handle(c.payload, indexer)
+		if err != nil && !c.expectError {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+		}
+		if err == nil && c.expectError {
+			t.Errorf("case %s: expected error but didn't get one", c.name)
+		}
+		if indexer.Len() != c.expectLen {
+			t.Errorf("case %s: expected %d entries but got %d", c.name, c.expectLen, indexer.Len())
+		}
+	}
+}
+
+func TestIndexerReconcile(t *testing.T) {
+	indexer := NewIndexer(NewTrie())
+	stale := &users.User{ID: 1, UserName: "stale", FirstName: "Old", LastName: "Name"}
+	indexer.IndexUser(stale)
+
+	stored := []*users.User{
+		{ID: 1, UserName: "fresh", FirstName: "New", LastName: "Name"},
+		{ID: 2, UserName: "missed", FirstName: "Missed", LastName: "Event"},
+	}
+	late := &users.User{ID: 3, UserName: "late", FirstName: "Late", LastName: "Arrival"}
+	forEach := func(fn func(user *users.User)) error {
+		for _, user := range stored {
+			fn(user)
+		}
+		//a user indexed while the index is being
+		//rebuilt must still be in the new index
+		indexer.IndexUser(late)
+		return nil
+	}
+	if err := indexer.Reconcile(forEach); err != nil {
+		t.Fatalf("unexpected error reconciling: %v", err)
+	}
+	expected := map[string][]int64{"stale": nil, "fresh": {1}, "missed": {2}, "late": {3}, "name": {1}}
+	for query, ids := range expected {
+		if found := indexer.Search(query, 10, nil); !reflect.DeepEqual(found, ids) {
+			t.Errorf("search for %q: expected %v but got %v", query, ids, found)
+		}
+	}
+
+	failing := func(fn func(user *users.User)) error {
+		fn(&users.User{ID: 4, UserName: "partial"})
+		return errors.New("database is unavailable")
+	}
+	if err := indexer.Reconcile(failing); err == nil {
+		t.Errorf("expected an error from a failed rebuild")
+	}
+	if found := indexer.Search("partial", 10, nil); len(found) != 0 || indexer.Search("fresh", 10, nil) == nil {
+		t.Errorf("a failed rebuild replaced the index")
+	}
+}
@@ -1,19 +1,24 @@
 package indexes
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
-type int64set map[int64]struct{}
+//valueSet is a set of values, each with
+//the fields its key was indexed from
+type valueSet[V comparable] map[V]Field
 
-func (s int64set) add(value int64) bool {
-	_, ok := s[value]
-	if ok {
-		return false
-	}
-	s[value] = struct{}{}
-	return true
+//int64set is a set of int64 values
+type int64set = valueSet[int64]
+
+func (s valueSet[V]) add(value V, field Field) bool {
+	fields, ok := s[value]
+	s[value] = fields | field
+	return !ok
 }
 
-func (s int64set) remove(value int64) bool {
+func (s valueSet[V]) remove(value V) bool {
 	_, ok := s[value]
 	if !ok {
 		return false
@@ -22,50 +27,91 @@ func (s int64set) remove(value int64) bool {
 	return true
 }
 
-func (s int64set) has(value int64) bool {
+func (s valueSet[V]) has(value V) bool {
 	_, ok := s[value]
 	return ok
 }
 
-func (s int64set) all() []int64 {
-	values := make([]int64, 0, len(s))
+func (s valueSet[V]) all() []V {
+	values := make([]V, 0, len(s))
 	for v := range s {
 		values = append(values, v)
 	}
 	return values
 }
 
-type trieNode struct {
+//sorted returns the values in the set, ordered by less
+func (s valueSet[V]) sorted(less func(a, b V) bool) []V {
+	values := s.all()
+	sort.Slice(values, func(i, j int) bool { return less(values[i], values[j]) })
+	return values
+}
+
+type trieNode[V comparable] struct {
 	name     rune
-	vals     int64set
-	children map[rune]*trieNode
-	parent   *trieNode
+	vals     valueSet[V]
+	children map[rune]*trieNode[V]
+	parent   *trieNode[V]
 }
 
-//Trie implements a trie data structure mapping strings to int64s
+//TrieOf implements a trie data structure mapping strings to values
+//of any comparable type, such as IDs of users, channels or hashtags,
 //that is safe for concurrent use.
-type Trie struct {
+type TrieOf[V comparable] struct {
 	mx   sync.RWMutex
-	Root *trieNode
+	Root *trieNode[V]
 	Size int
+	//less orders values, so that results
+	//and snapshots are always in the same order
+	less func(a, b V) bool
 }
 
-//NewTrie constructs a new Trie.
-func NewTrie() *Trie {
-	return &Trie{
-		Root: &trieNode{children: make(map[rune]*trieNode)},
+//Trie is a TrieOf int64s, such as the IDs of users.
+type Trie = TrieOf[int64]
+
+//NewTrieOf constructs a new TrieOf values ordered by less.
+func NewTrieOf[V comparable](less func(a, b V) bool) *TrieOf[V] {
+	if less == nil {
+		panic("nil less")
+	}
+	return &TrieOf[V]{
+		Root: &trieNode[V]{children: make(map[rune]*trieNode[V])},
 		Size: 0,
+		less: less,
 	}
 }
 
+//NewTrie constructs a new Trie.
+func NewTrie() *Trie {
+	return NewTrieOf(lessInt64)
+}
+
+//lessInt64 orders int64 values from least to greatest
+func lessInt64(a, b int64) bool {
+	return a < b
+}
+
 //Len returns the number of entries in the trie.
-func (t *Trie) Len() int {
+func (t *TrieOf[V]) Len() int {
 	return t.Size
 }
 
 //Add adds a key and value to the trie.
-func (t *Trie) Add(key string, value int64) {
+func (t *TrieOf[V]) Add(key string, value V) {
+	t.AddField(key, value, 0)
+}
+
+//AddField adds a key and value to the trie, recording
+//the field of the value that the key came from.
+func (t *TrieOf[V]) AddField(key string, value V, field Field) {
 	t.mx.Lock()
+	t.add(key, value, field)
+	t.mx.Unlock()
+}
+
+//add adds a key and value to the trie.
+//The caller must hold the trie's lock.
+func (t *TrieOf[V]) add(key string, value V, field Field) {
 	runes := []rune(key)
 	currNode := t.Root
 	for _, name := range runes {
@@ -74,72 +120,92 @@ func (t *Trie) Add(key string, value int64) {
 		}
 		currNode = currNode.children[name]
 	}
-	ok := currNode.vals.add(value)
+	ok := currNode.vals.add(value, field)
 	if ok {
 		t.Size++
 	}
-	t.mx.Unlock()
 }
 
-//Find finds `max` values matching `prefix`. If the trie
-//is entirely empty, or the prefix is empty, or max == 0,
-//or the prefix is not found, this returns a nil slice.
-func (t *Trie) Find(prefix string, max int) []int64 {
+//Find finds `max` values matching `prefix`, in the order of their keys:
+//shorter keys first, then keys in order of their runes, then values in
+//the order of the trie's less function. A value found at several keys is
+//returned once, at the first. If the trie is entirely empty, or the
+//prefix is empty, or max == 0, or the prefix is not found, this returns
+//a nil slice.
+func (t *TrieOf[V]) Find(prefix string, max int) []V {
+	values, _ := t.FindAfter(prefix, nil, max)
+	return values
+}
+
+//FindAfter finds up to `max` values matching `prefix` that come after
+//the cursor in the order Find returns them, starting from the first if
+//the cursor is nil. It also returns the cursor to pass to find the next
+//values, or nil if there are no more.
+func (t *TrieOf[V]) FindAfter(prefix string, cursor *CursorOf[V], max int) ([]V, *CursorOf[V]) {
 	t.mx.RLock()
 	defer t.mx.RUnlock()
-	if t.Len() == 0 || len(prefix) == 0 || max == 0 {
-		return nil
+	return t.findAfterIf(prefix, cursor, max, nil)
+}
+
+//findAfterIf is like FindAfter, but leaves out values for which keep
+//returns false, unless it's nil. The caller must hold the trie's lock.
+func (t *TrieOf[V]) findAfterIf(prefix string, cursor *CursorOf[V], max int, keep func(value V) bool) ([]V, *CursorOf[V]) {
+	if t.Len() == 0 || len(prefix) == 0 || max <= 0 {
+		return nil, nil
+	}
+	node := t.Root
+	for _, name := range prefix {
+		if node = node.children[name]; node == nil {
+			return nil, nil
+		}
 	}
-	visited := make(map[*trieNode]bool)
-	values := []int64{}
-	runes := []rune(prefix)
-	var dfsFind func(node *trieNode, index int)
-	dfsFind = func(node *trieNode, index int) {
-		if node != nil {
-			if index == len(runes) || node.name == runes[index] {
-				visited[node] = true
-				if len(node.vals) > 0 {
-					ids := node.vals.all()
-					for _, id := range ids {
-						if len(values) < max {
-							found := searchSlice(values, id)
-							if !found {
-								values = append(values, id)
-							}
-						} else {
-							break
-						}
-					}
+	//visit the prefix's subtree breadth-first, so that shorter keys
+	//come first, with each node's children in order of their runes
+	visit := func(yield func(key string, vals valueSet[V]) bool) {
+		type entry struct {
+			node *trieNode[V]
+			key  string
+		}
+		level := []entry{{node, prefix}}
+		for len(level) > 0 {
+			var next []entry
+			for _, e := range level {
+				if !yield(e.key, e.node.vals) {
+					return
 				}
-				if index < len(runes) {
-					index++
+				names := make([]rune, 0, len(e.node.children))
+				for name := range e.node.children {
+					names = append(names, name)
 				}
-				for _, child := range node.children {
-					if _, ok := visited[child]; !ok {
-						dfsFind(child, index)
-					}
+				sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+				for _, name := range names {
+					next = append(next, entry{e.node.children[name], e.key + string(name)})
 				}
 			}
+			level = next
 		}
 	}
-	if n, ok := t.Root.children[runes[0]]; ok {
-		dfsFind(n, 0)
-	} else {
-		return nil
-	}
-	return values
+	return findAfter(visit, cursor, max, t.less, keep)
 }
 
 //Remove removes a key/value pair from the trie
 //and trims branches with no values.
-func (t *Trie) Remove(key string, value int64) {
+func (t *TrieOf[V]) Remove(key string, value V) {
 	t.mx.Lock()
+	t.remove(key, value)
+	t.mx.Unlock()
+}
+
+//remove removes a key/value pair from the trie.
+//The caller must hold the trie's lock.
+func (t *TrieOf[V]) remove(key string, value V) {
 	runes := []rune(key)
 	lastNode := findLastNode(t.Root, runes, value)
-	var deleteNodes func(node *trieNode, index int)
-	deleteNodes = func(node *trieNode, index int) {
+	var deleteNodes func(node *trieNode[V], index int)
+	deleteNodes = func(node *trieNode[V], index int) {
 		if node != nil && index >= 0 {
-			if len(node.getChildren()) == 0 {
+			//keep nodes that still hold other values
+			if len(node.getChildren()) == 0 && len(node.vals) == 0 {
 				parent := node.getParent()
 				if parent != nil {
 					parent.removeChild(runes[index])
@@ -152,21 +218,58 @@ func (t *Trie) Remove(key string, value int64) {
 		deleteNodes(lastNode, len(runes)-1)
 		t.Size--
 	}
-	t.mx.Unlock()
 }
-func (node *trieNode) newChild(name rune) {
-	newNode := &trieNode{
+
+//removeValues removes every key of the given values from the trie
+//and trims branches with no values, visiting every node once.
+//The caller must hold the trie's lock.
+func (t *TrieOf[V]) removeValues(values map[V]bool) {
+	var prune func(node *trieNode[V])
+	prune = func(node *trieNode[V]) {
+		for value := range node.vals {
+			if values[value] {
+				node.vals.remove(value)
+				t.Size--
+			}
+		}
+		for name, child := range node.children {
+			prune(child)
+			if len(child.children) == 0 && len(child.vals) == 0 {
+				node.removeChild(name)
+			}
+		}
+	}
+	prune(t.Root)
+}
+
+// each calls fn with every key and value in the trie and
+// the value's fields. The caller must hold the trie's lock.
+func (t *TrieOf[V]) each(fn func(key string, value V, field Field)) {
+	var walk func(node *trieNode[V], key []rune)
+	walk = func(node *trieNode[V], key []rune) {
+		for value, field := range node.vals {
+			fn(string(key), value, field)
+		}
+		for name, child := range node.children {
+			walk(child, append(key, name))
+		}
+	}
+	walk(t.Root, nil)
+}
+
+func (node *trieNode[V]) newChild(name rune) {
+	newNode := &trieNode[V]{
 		name:     name,
-		vals:     make(int64set),
-		children: make(map[rune]*trieNode),
+		vals:     make(valueSet[V]),
+		children: make(map[rune]*trieNode[V]),
 		parent:   node,
 	}
 	node.children[name] = newNode
 }
-func (node *trieNode) removeChild(name rune) {
+func (node *trieNode[V]) removeChild(name rune) {
 	delete(node.children, name)
 }
-func findLastNode(node *trieNode, runes []rune, value int64) *trieNode {
+func findLastNode[V comparable](node *trieNode[V], runes []rune, value V) *trieNode[V] {
 	if node == nil {
 		return nil
 	}
@@ -189,20 +292,16 @@ func findLastNode(node *trieNode, runes []rune, value int64) *trieNode {
 	}
 	return findLastNode(n, nrunes, value)
 }
-func (node trieNode) getParent() *trieNode {
+func (node trieNode[V]) getParent() *trieNode[V] {
 	return node.parent
 }
 
 // Returns the children of this node.
-func (node trieNode) getChildren() map[rune]*trieNode {
+func (node trieNode[V]) getChildren() map[rune]*trieNode[V] {
 	return node.children
 }
 
-func searchSlice(slice []int64, val int64) bool {
-	for _, item := range slice {
-		if item == val {
-			return true
-		}
-	}
-	return false
+// locker returns the lock guarding the trie
+func (t *TrieOf[V]) locker() *sync.RWMutex {
+	return &t.mx
 }
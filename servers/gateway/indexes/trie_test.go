@@ -1,6 +1,7 @@
 package indexes
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 )
@@ -164,3 +165,229 @@ func TestTrieRemove(t *testing.T) {
 	}
 
 }
+
+func TestTrieFindFuzzy(t *testing.T) {
+	trie := NewTrie()
+	trie.Add("john", 1)
+	trie.Add("johnson", 2)
+	trie.Add("joan", 3)
+	trie.Add("jon", 4)
+	trie.Add("mary", 5)
+	trie.Add("jonh", 6)
+
+	cases := []struct {
+		name           string
+		query          string
+		maxDistance    int
+		max            int
+		expectedValues []int64
+	}{
+		{
+			"Exact Matches Only",
+			"john",
+			0,
+			10,
+			[]int64{1, 2},
+		},
+		{
+			"Typo",
+			"jonh",
+			1,
+			10,
+			[]int64{6, 4, 1, 2},
+		},
+		{
+			"Whole Keys Before Prefixes",
+			"jon",
+			1,
+			10,
+			[]int64{4, 6, 1, 3, 2},
+		},
+		{
+			"Limited",
+			"jon",
+			1,
+			2,
+			[]int64{4, 6},
+		},
+		{
+			"No Matches",
+			"xyz",
+			1,
+			10,
+			[]int64{},
+		},
+		{
+			"Empty Query",
+			"",
+			1,
+			10,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		values := trie.FindFuzzy(c.query, c.maxDistance, c.max)
+		if !reflect.DeepEqual(values, c.expectedValues) {
+			t.Errorf("case %s: expected values: %v (got %v)", c.name, c.expectedValues, values)
+		}
+	}
+}
+
+func TestTrieFindRanked(t *testing.T) {
+	trie := NewTrie()
+	trie.AddField("johnson", 1, FieldName)
+	trie.AddField("john", 2, FieldName)
+	trie.AddField("johnny", 3, FieldUserName)
+	trie.AddField("john", 4, FieldUserName)
+	trie.AddField("johnathan", 5, FieldName)
+	trie.AddField("johnny", 6, FieldName)
+	trie.AddField("john", 6, FieldName)
+
+	contacts := map[int64]bool{5: true}
+	boost := func(value int64) float64 {
+		if contacts[value] {
+			return 3
+		}
+		return 0
+	}
+
+	cases := []struct {
+		name           string
+		prefix         string
+		max            int
+		boost          Boost
+		expectedValues []int64
+	}{
+		{
+			"Exact User Name First",
+			"john",
+			10,
+			nil,
+			[]int64{4, 2, 6, 3, 1, 5},
+		},
+		{
+			"Boosted",
+			"john",
+			10,
+			boost,
+			[]int64{4, 2, 6, 5, 3, 1},
+		},
+		{
+			"Limited",
+			"john",
+			2,
+			nil,
+			[]int64{4, 2},
+		},
+		{
+			"Prefix Only",
+			"johnn",
+			10,
+			nil,
+			[]int64{3, 6},
+		},
+		{
+			"Not Found",
+			"jane",
+			10,
+			nil,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		values := trie.FindRanked(c.prefix, c.max, c.boost)
+		if !reflect.DeepEqual(values, c.expectedValues) {
+			t.Errorf("case %s: expected values: %v (got %v)", c.name, c.expectedValues, values)
+		}
+	}
+}
+
+func TestTrieFindRankedAll(t *testing.T) {
+	trie := NewTrie()
+	trie.AddField("jean", 1, FieldName)
+	trie.AddField("luc", 1, FieldName)
+	trie.AddField("jean", 2, FieldName)
+	trie.AddField("lucas", 2, FieldName)
+	trie.AddField("jeanne", 3, FieldName)
+
+	cases := []struct {
+		name           string
+		prefixes       []string
+		expectedValues []int64
+	}{
+		{"One Word", []string{"jean"}, []int64{1, 2, 3}},
+		{"Every Word", []string{"jean", "luc"}, []int64{1, 2}},
+		{"No Value Matches Every Word", []string{"jeanne", "luc"}, nil},
+		{"No Words", []string{}, nil},
+	}
+	for _, c := range cases {
+		values := trie.FindRankedAll(c.prefixes, 10, nil)
+		if !reflect.DeepEqual(values, c.expectedValues) {
+			t.Errorf("case %s: expected values: %v (got %v)", c.name, c.expectedValues, values)
+		}
+	}
+}
+
+func TestTrieRemoveSharedKey(t *testing.T) {
+	trie := NewTrie()
+	trie.Add("jo", 1)
+	trie.Add("jo", 2)
+	trie.Remove("jo", 1)
+	if values := trie.Find("jo", 10); !reflect.DeepEqual(values, []int64{2}) {
+		t.Errorf("expected the other value to remain: expected [2] but got %v", values)
+	}
+}
+
+func TestTrieOfStrings(t *testing.T) {
+	lessString := func(a, b string) bool { return a < b }
+	hashtags := NewTrieOf(lessString)
+	hashtags.Add("golang", "channel-go")
+	hashtags.Add("gopher", "channel-go")
+	hashtags.Add("gopher", "channel-pets")
+	hashtags.Add("go", "channel-board-games")
+
+	if hashtags.Len() != 4 {
+		t.Errorf("incorrect size: expected 4 but got %d", hashtags.Len())
+	}
+	pets := func(value string) float64 {
+		if value == "channel-pets" {
+			return 10
+		}
+		return 0
+	}
+	expected := []string{"channel-pets", "channel-board-games", "channel-go"}
+	if values := hashtags.FindRanked("go", 10, pets); !reflect.DeepEqual(values, expected) {
+		t.Errorf("ranked search: expected %v but got %v", expected, values)
+	}
+	if values := hashtags.FindFuzzy("gopjer", 1, 10); !reflect.DeepEqual(values, []string{"channel-go", "channel-pets"}) {
+		t.Errorf("fuzzy search: expected [channel-go channel-pets] but got %v", values)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := hashtags.WriteTo(buf); err != nil {
+		t.Fatalf("unexpected error writing snapshot: %v", err)
+	}
+	if _, err := ReadTrie(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Errorf("expected error reading a snapshot of strings as int64s")
+	}
+	read, err := ReadTrieOf(buf, lessString)
+	if err != nil {
+		t.Fatalf("unexpected error reading snapshot: %v", err)
+	}
+	read.Remove("gopher", "channel-go")
+	if values := read.FindRanked("gop", 10, nil); !reflect.DeepEqual(values, []string{"channel-pets"}) {
+		t.Errorf("search of trie read from snapshot: expected [channel-pets] but got %v", values)
+	}
+
+	type point struct{ x, y int }
+	points := NewTrieOf(func(a, b point) bool { return a.x < b.x || (a.x == b.x && a.y < b.y) })
+	points.Add("origin", point{0, 0})
+	if values := points.Find("or", 10); !reflect.DeepEqual(values, []point{{0, 0}}) {
+		t.Errorf("search of struct values: expected [{0 0}] but got %v", values)
+	}
+	if _, err := points.WriteTo(&bytes.Buffer{}); err == nil {
+		t.Errorf("expected error writing a snapshot of struct values")
+	}
+}
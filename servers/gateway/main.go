@@ -1,26 +1,33 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"os"
+	"os/signal"
 	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/audit"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/blocks"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/contacts"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/invites"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
 	"github.com/streadway/amqp"
 
-	_ "github.com/go-sql-driver/mysql"
-
 	"github.com/go-redis/redis"
 	"github.com/gorilla/mux"
 
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/blobs"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/events"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/exports"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/handlers"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
 	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sessions"
 )
 
@@ -32,12 +39,24 @@ func customDirector(targets []string, ctx *handlers.HandlerCtx) director {
 
 	return func(r *http.Request) {
 		r.Header.Del("X-User")
+		r.Header.Del("X-User-Blocks")
 		sessionState := &handlers.SessionState{}
 		_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, sessionState)
-		if sessionState.User != nil && err == nil {
-			json, _ := json.Marshal(sessionState.User)
-			log.Println(string(json))
-			r.Header.Add("X-User", string(json))
+		//users who must reset their password are treated as
+		//unauthenticated by the backends until they change it
+		if sessionState.User != nil && err == nil && !sessionState.PasswordResetRequired {
+			sessionState.User.Status = sessionState.User.ActiveStatus(time.Now())
+			userJSON, _ := json.Marshal(sessionState.User)
+			log.Println(string(userJSON))
+			r.Header.Add("X-User", string(userJSON))
+
+			blockList, err := ctx.GetBlockList(sessionState.User.ID)
+			if err != nil {
+				log.Printf("error getting block list: %v", err)
+			} else {
+				blocksJSON, _ := json.Marshal(blockList)
+				r.Header.Add("X-User-Blocks", string(blocksJSON))
+			}
 		}
 		i32 := int32(len(targets))
 		targ := targets[counter%i32]
@@ -48,8 +67,31 @@ func customDirector(targets []string, ctx *handlers.HandlerCtx) director {
 	}
 }
 
+//loadIndex builds the index of users from the snapshot at snapshotPath
+//and the changes made since it was taken, or from every user in the
+//store if there is no snapshot or it can't be read
+func loadIndex(indexer *indexes.Indexer, store *users.SQLStore, snapshotPath string) error {
+	if len(snapshotPath) > 0 {
+		takenAt, err := indexer.LoadSnapshot(snapshotPath)
+		if err == nil {
+			err = indexer.CatchUp(takenAt, store.ForEachChangeSince)
+		}
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading user index snapshot, rebuilding index: %s", err.Error())
+		}
+	}
+	return indexer.Reconcile(store.ForEachUser)
+}
+
 // main is the main entry point for the server
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	addr := os.Getenv("ADDR")
 	if len(addr) == 0 {
 		addr = ":443"
@@ -92,21 +134,86 @@ func main() {
 	fmt.Println(pong, err)
 	sessionStore := sessions.NewRedisStore(client, time.Hour)
 
-	dsn := os.Getenv("DSN")
-	if len(dsn) == 0 {
-		dsn = fmt.Sprintf("root:%s@tcp(mysqlServer:3306)/userDB", os.Getenv("MYSQL_ROOT_PASSWORD"))
-	}
-	db, err := sql.Open("mysql", dsn)
+	db, dialect, err := openDB()
 	if err != nil {
 		fmt.Printf("error opening database: %v\n", err)
 		os.Exit(1)
 	}
-	sqlStore := users.NewSQLStore(db)
+	//gateways apply pending migrations on startup unless AUTOMIGRATE
+	//is "false", in which case run "gateway migrate" before deploying
+	if os.Getenv("AUTOMIGRATE") != "false" {
+		if err := migrateUp(db, dialect); err != nil {
+			log.Fatalf("Error migrating database: %s", err)
+		}
+	}
+	sqlStore := users.NewDialectStore(db, dialect)
+	var userStore users.Store = sqlStore
+	//USER_CACHE_TTL, if set, caches users looked up by ID for that long
+	if cacheTTL := os.Getenv("USER_CACHE_TTL"); len(cacheTTL) > 0 {
+		ttl, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			log.Fatalf("Error parsing USER_CACHE_TTL: %s", err)
+		}
+		userStore = users.NewCachedStore(userStore, ttl)
+	}
 
-	trie, err := sqlStore.GetAllUsers()
+	//every replica publishes the changes it makes to its index of
+	//users over redis and applies the changes the others publish
+	indexSync := indexes.NewRedisSync(client, indexes.DefaultSyncChannel)
+	//INDEX_KIND chooses the data structure of the index: "trie", the
+	//default, or "radix", a radix tree that uses much less memory
+	indexKind := os.Getenv("INDEX_KIND")
+	if len(indexKind) == 0 {
+		indexKind = indexes.KindTrie
+	}
+	index, err := indexes.NewIndex(indexKind)
 	if err != nil {
-		fmt.Printf("error creating trie: %v", err)
+		log.Fatalf("Error parsing INDEX_KIND: %s", err)
 	}
+	//INDEX_CONTAINS, unless it's "false", also indexes the trigrams of
+	//names so that users can be searched for by any part of their names
+	//with mode=contains, at the cost of about twice as much memory
+	if os.Getenv("INDEX_CONTAINS") != "false" {
+		index = indexes.NewNGramIndex(index)
+	}
+	indexer := indexes.NewSyncedIndexer(index, indexSync)
+	go indexSync.Listen(indexer)
+	//INDEX_SNAPSHOT_PATH, if set, is where a snapshot of the index is
+	//saved every INDEX_SNAPSHOT_INTERVAL and on shutdown, so that the
+	//next start only reads the users that changed since it was taken
+	snapshotPath := os.Getenv("INDEX_SNAPSHOT_PATH")
+	if err := loadIndex(indexer, sqlStore, snapshotPath); err != nil {
+		fmt.Printf("error indexing users: %v", err)
+	}
+	if len(snapshotPath) > 0 {
+		snapshotInterval := 5 * time.Minute
+		if interval := os.Getenv("INDEX_SNAPSHOT_INTERVAL"); len(interval) > 0 {
+			snapshotInterval, err = time.ParseDuration(interval)
+			if err != nil || snapshotInterval <= 0 {
+				log.Fatalf("Error parsing INDEX_SNAPSHOT_INTERVAL: %q", interval)
+			}
+		}
+		go indexer.SnapshotEvery(snapshotInterval, snapshotPath)
+		go func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			<-signals
+			if err := indexer.SaveSnapshot(snapshotPath); err != nil {
+				log.Printf("Error saving user index snapshot: %s", err.Error())
+			}
+			os.Exit(0)
+		}()
+	}
+	//INDEX_RECONCILE_INTERVAL is how often the index is rebuilt
+	//from the database, to heal any changes this replica missed
+	reconcileInterval := 10 * time.Minute
+	if interval := os.Getenv("INDEX_RECONCILE_INTERVAL"); len(interval) > 0 {
+		reconcileInterval, err = time.ParseDuration(interval)
+		if err != nil || reconcileInterval <= 0 {
+			log.Fatalf("Error parsing INDEX_RECONCILE_INTERVAL: %q", interval)
+		}
+	}
+	go indexer.ReconcileEvery(reconcileInterval, sqlStore.ForEachUser)
 
 	rabbitAddr := os.Getenv("RABBITADDR")
 	if len(rabbitAddr) == 0 {
@@ -142,9 +249,58 @@ func main() {
 		nil,    // args
 	)
 
-	notifier := handlers.NewNotifier()
+	//user events get their own channel on the same connection,
+	//since a channel that fails to publish is closed
+	eventsCh, err := conn.Channel()
+	if err != nil {
+		log.Fatalf("Error opening a channel: %s", err)
+	}
+	defer eventsCh.Close()
+	publisher, err := events.NewAMQPPublisher(eventsCh, events.DefaultQueue)
+	if err != nil {
+		log.Fatalf("Error creating user event publisher: %s", err)
+	}
+
+	//EXPORTBACKENDS lists the backends whose data is included in
+	//user data exports, as comma-separated name=url pairs
+	exportBackends, err := exports.ParseBackends(os.Getenv("EXPORTBACKENDS"))
+	if err != nil {
+		log.Fatalf("Error parsing EXPORTBACKENDS: %s", err)
+	}
+	exporter := exports.NewExporter(exportBackends)
+
+	avatarDir := os.Getenv("AVATARDIR")
+	if len(avatarDir) == 0 {
+		avatarDir = "/avatars"
+	}
+	blobStore, err := blobs.NewLocalStore(avatarDir)
+	if err != nil {
+		log.Fatalf("Error opening avatar storage: %s", err)
+	}
+
+	blockStore := blocks.NewDialectStore(db, dialect)
+	contactStore := contacts.NewDialectStore(db, dialect)
+	inviteStore := invites.NewDialectStore(db, dialect)
+
+	//SIGNUPMODE is "open", "invite" or "domains", in which case
+	//SIGNUPDOMAINS lists the email domains that don't need an invite
+	signupPolicy, err := invites.ParsePolicy(os.Getenv("SIGNUPMODE"), os.Getenv("SIGNUPDOMAINS"))
+	if err != nil {
+		log.Fatalf("Error parsing signup policy: %s", err)
+	}
+
+	auditStore := audit.NewDialectStore(db, dialect)
+	var auditSink audit.Sink = auditStore
+	//AUDITLOG set to "stdout" also writes security events to
+	//standard output as lines of JSON, for log collectors
+	if os.Getenv("AUDITLOG") == "stdout" {
+		auditSink = audit.MultiSink{auditStore, audit.NewWriterSink(os.Stdout)}
+	}
+
+	notifier := handlers.NewNotifier(blockStore)
 
-	ctx := handlers.NewHandlerContext(sessionKey, sessionStore, sqlStore, trie, notifier)
+	ctx := handlers.NewHandlerContext(sessionKey, sessionStore, userStore, indexer, notifier, blobStore, blockStore, contactStore,
+		publisher, exporter, inviteStore, signupPolicy, auditSink, auditStore)
 
 	go ctx.Notifier.NotifyWebSockets(msgs)
 
@@ -160,9 +316,27 @@ func main() {
 	mux.Handle("/v1/messages/{messageID}", messagingProxy)
 	mux.HandleFunc("/v1/users", ctx.UsersHandler)
 	mux.HandleFunc("/v1/users/{id}", ctx.SpecificUserHandler)
+	mux.HandleFunc("/v1/users/me/password", ctx.PasswordHandler)
+	mux.HandleFunc("/v1/users/me/avatar", ctx.AvatarUploadHandler)
+	mux.HandleFunc("/v1/users/me/username", ctx.UserNameHandler)
+	mux.HandleFunc("/v1/users/me/export", ctx.ExportHandler)
+	mux.HandleFunc("/v1/users/me/security-events", ctx.SecurityEventsHandler)
+	mux.HandleFunc("/v1/users/me/blocks", ctx.BlocksHandler)
+	mux.HandleFunc("/v1/users/me/contacts", ctx.ContactsHandler)
+	mux.HandleFunc("/v1/users/me/contacts/{id}", ctx.SpecificContactHandler)
+	mux.HandleFunc("/v1/users/me/contact-requests", ctx.ContactRequestsHandler)
+	mux.HandleFunc("/v1/users/me/contact-requests/{id}", ctx.SpecificContactRequestHandler)
+	mux.PathPrefix("/v1/avatars/").HandlerFunc(ctx.AvatarHandler)
 	mux.HandleFunc("/v1/sessions", ctx.SessionsHandler)
 	mux.HandleFunc("/v1/sessions/{id}", ctx.SpecificSessionHandler)
 	mux.HandleFunc("/v1/ws", ctx.WebSocketConnectionHandler)
+	mux.HandleFunc("/v1/admin/users", ctx.AdminUsersHandler)
+	mux.HandleFunc("/v1/admin/users/{id}", ctx.AdminSpecificUserHandler)
+	mux.HandleFunc("/v1/admin/users/{id}/suspension", ctx.AdminSuspensionHandler)
+	mux.HandleFunc("/v1/admin/users/{id}/password-reset", ctx.AdminPasswordResetHandler)
+	mux.HandleFunc("/v1/admin/invites", ctx.AdminInvitesHandler)
+	mux.HandleFunc("/v1/admin/invites/{code}", ctx.AdminSpecificInviteHandler)
+	mux.HandleFunc("/v1/admin/security-events", ctx.AdminSecurityEventsHandler)
 	wrappedMux := &handlers.CORS{Handler: mux}
 
 	log.Printf("server listening at: %s", addr)
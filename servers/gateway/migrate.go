@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/migrations"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+const migrateUsage = "usage: gateway migrate [up | down [steps] | version]"
+
+//openDB opens the gateway's database from the DSN environment variable,
+//whose scheme selects MySQL, PostgreSQL or SQLite
+func openDB() (*sql.DB, sqldb.Dialect, error) {
+	dsn := os.Getenv("DSN")
+	if len(dsn) == 0 {
+		dsn = fmt.Sprintf("root:%s@tcp(mysqlServer:3306)/userDB", os.Getenv("MYSQL_ROOT_PASSWORD"))
+	}
+	return sqldb.Open(dsn)
+}
+
+//newMigrator returns a migrator for the migrations
+//embedded in the binary for the given dialect
+func newMigrator(db *sql.DB, dialect sqldb.Dialect) (*migrations.Migrator, error) {
+	all, err := migrations.All(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return migrations.NewMigrator(db, dialect, all), nil
+}
+
+//migrateUp applies any pending migrations, logging each one
+func migrateUp(db *sql.DB, dialect sqldb.Dialect) error {
+	migrator, err := newMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up()
+	for _, m := range applied {
+		log.Printf("applied migration %d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+//runMigrate runs the "migrate" subcommand with the given
+//arguments and returns the process exit code
+func runMigrate(args []string) int {
+	db, dialect, err := openDB()
+	if err != nil {
+		log.Printf("error opening database: %v", err)
+		return 1
+	}
+	defer db.Close()
+	migrator, err := newMigrator(db, dialect)
+	if err != nil {
+		log.Printf("error loading migrations: %v", err)
+		return 1
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch {
+	case command == "up" && len(args) <= 1:
+		if err := migrateUp(db, dialect); err != nil {
+			log.Printf("error migrating: %v", err)
+			return 1
+		}
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Print(migrateUsage)
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			log.Printf("reverted migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("error migrating: %v", err)
+			return 1
+		}
+	case command == "version" && len(args) == 1:
+		version, err := migrator.Version()
+		if err != nil {
+			log.Printf("error getting schema version: %v", err)
+			return 1
+		}
+		fmt.Println(version)
+	default:
+		log.Print(migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+//go:embed sql/*/*.sql
+var files embed.FS
+
+//Migration is one numbered change to the database schema
+type Migration struct {
+	Version int
+	Name    string
+	//Up applies the change and Down reverts it
+	Up   string
+	Down string
+}
+
+//All returns the migrations for the dialect that ship embedded in the
+//gateway binary, ordered by version. Each dialect has its own migrations
+//in the directory named after it.
+func All(dialect sqldb.Dialect) ([]*Migration, error) {
+	sub, err := fs.Sub(files, path.Join("sql", dialect.String()))
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+//Load reads migrations from the root of the given file system, ordered
+//by version. Files must be named "{version}_{name}.up.sql" or
+//"{version}_{name}.down.sql", and every version needs an up file.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, fileName := range names {
+		base := strings.TrimSuffix(path.Base(fileName), ".sql")
+		direction := path.Ext(base)
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", fileName)
+		}
+		base = strings.TrimSuffix(base, direction)
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || version <= 0 || len(parts) != 2 {
+			return nil, fmt.Errorf("migration %s must be named {version}_{name}", fileName)
+		}
+		data, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, parts[1])
+		}
+		if direction == ".up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(strings.TrimSpace(m.Up)) == 0 {
+			return nil, fmt.Errorf("migration %d_%s has no up statements", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+//statements splits a migration into its individual statements, since
+//not every driver can run more than one statement per call. Statements are
+//separated by semicolons, so migrations must not use them in literals.
+func statements(script string) []string {
+	stmts := []string{}
+	for _, stmt := range strings.Split(script, ";") {
+		if stmt = strings.TrimSpace(stmt); len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
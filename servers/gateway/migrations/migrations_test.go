@@ -0,0 +1,111 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name        string
+		files       fstest.MapFS
+		expectedErr bool
+		expected    []*Migration
+	}{
+		{
+			"Sorted By Version",
+			fstest.MapFS{
+				"0010_second.up.sql":  {Data: []byte("create table b (id int)")},
+				"0002_first.up.sql":   {Data: []byte("create table a (id int)")},
+				"0002_first.down.sql": {Data: []byte("drop table a")},
+			},
+			false,
+			[]*Migration{
+				{2, "first", "create table a (id int)", "drop table a"},
+				{10, "second", "create table b (id int)", ""},
+			},
+		},
+		{
+			"Missing Up",
+			fstest.MapFS{
+				"0001_first.down.sql": {Data: []byte("drop table a")},
+			},
+			true,
+			nil,
+		},
+		{
+			"Bad Version",
+			fstest.MapFS{
+				"first.up.sql": {Data: []byte("create table a (id int)")},
+			},
+			true,
+			nil,
+		},
+		{
+			"Bad Direction",
+			fstest.MapFS{
+				"0001_first.sql": {Data: []byte("create table a (id int)")},
+			},
+			true,
+			nil,
+		},
+		{
+			"Conflicting Names",
+			fstest.MapFS{
+				"0001_first.up.sql":   {Data: []byte("create table a (id int)")},
+				"0001_other.up.sql":   {Data: []byte("create table b (id int)")},
+				"0001_first.down.sql": {Data: []byte("drop table a")},
+			},
+			true,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		migrations, err := Load(c.files)
+		if c.expectedErr {
+			if err == nil {
+				t.Errorf("case %s: expected error but didn't get one", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(migrations, c.expected) {
+			t.Errorf("case %s: incorrect migrations", c.name)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	for _, dialect := range []sqldb.Dialect{sqldb.MySQL, sqldb.Postgres, sqldb.SQLite} {
+		migrations, err := All(dialect)
+		if err != nil {
+			t.Fatalf("error loading embedded %s migrations: %v", dialect, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("no embedded %s migrations found", dialect)
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s migration versions should be sequential: expected %d but got %d", dialect, i+1, m.Version)
+			}
+			if len(m.Down) == 0 {
+				t.Errorf("%s migration %d_%s has no down statements", dialect, m.Version, m.Name)
+			}
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "alter table a add column b int;\n\nalter table a add column c int;\n"
+	expected := []string{"alter table a add column b int", "alter table a add column c int"}
+	if stmts := statements(script); !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("incorrect statements: expected %v but got %v", expected, stmts)
+	}
+}
@@ -0,0 +1,230 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+//ErrLockTimeout is returned when another gateway held the
+//migration lock for longer than the lock timeout
+var ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+
+//ErrUnknownVersion is returned when the database has a migration
+//applied that this binary doesn't know how to revert
+var ErrUnknownVersion = errors.New("database has a migration applied that is not known to this gateway")
+
+//lockName is the name of the MySQL advisory lock held while migrating
+const lockName = "gateway_schema_migrations"
+
+//lockKey identifies the PostgreSQL advisory lock held while migrating
+const lockKey = 4410202003
+
+//lockPollInterval is how often to retry taking the PostgreSQL lock
+const lockPollInterval = 250 * time.Millisecond
+
+const sqlCreateMigrationsTable = "create table if not exists schema_migrations (" +
+	"version bigint not null primary key, name varchar(255) not null, applied_at bigint not null)"
+const sqlGetLock = "select get_lock(?, ?)"
+const sqlReleaseLock = "select release_lock(?)"
+const sqlTryAdvisoryLock = "select pg_try_advisory_lock(?)"
+const sqlAdvisoryUnlock = "select pg_advisory_unlock(?)"
+const sqlGetVersions = "select version from schema_migrations order by version"
+const sqlInsertVersion = "insert into schema_migrations(version, name, applied_at) values (?,?,?)"
+const sqlDeleteVersion = "delete from schema_migrations where version = ?"
+
+//Migrator applies and reverts migrations on a database. On MySQL and
+//PostgreSQL it holds an advisory lock while it works so that gateways
+//starting at the same time don't apply the same migration twice. SQLite
+//databases are only used by a single gateway, so they aren't locked.
+type Migrator struct {
+	db          *sql.DB
+	dialect     sqldb.Dialect
+	migrations  []*Migration
+	LockTimeout time.Duration
+}
+
+//NewMigrator constructs a new Migrator for the given migrations,
+//which must be written for the given dialect
+func NewMigrator(db *sql.DB, dialect sqldb.Dialect, migrations []*Migration) *Migrator {
+	if db == nil {
+		panic("nil database")
+	}
+	return &Migrator{
+		db:          db,
+		dialect:     dialect,
+		migrations:  migrations,
+		LockTimeout: time.Minute,
+	}
+}
+
+//Up applies every migration that hasn't been applied yet, in order,
+//and returns the migrations it applied
+func (m *Migrator) Up() ([]*Migration, error) {
+	applied := []*Migration{}
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if versions[mig.Version] {
+				continue
+			}
+			if err := m.run(conn, mig, mig.Up, sqlInsertVersion, mig.Version, mig.Name, time.Now().Unix()); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+//Down reverts up to the given number of the most recently applied
+//migrations and returns the migrations it reverted
+func (m *Migrator) Down(steps int) ([]*Migration, error) {
+	reverted := []*Migration{}
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		known := make(map[int]bool, len(m.migrations))
+		for _, mig := range m.migrations {
+			known[mig.Version] = true
+		}
+		for version := range versions {
+			if !known[version] {
+				return ErrUnknownVersion
+			}
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.migrations[i]
+			if !versions[mig.Version] {
+				continue
+			}
+			if err := m.run(conn, mig, mig.Down, sqlDeleteVersion, mig.Version); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+//Version returns the highest applied migration version, or 0 if none
+//have been applied
+func (m *Migrator) Version() (int, error) {
+	version := 0
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for v := range versions {
+			if v > version {
+				version = v
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+//withLock creates the migrations table if needed and calls fn while
+//holding the migration lock. The lock belongs to a database session, so
+//fn must use the given connection rather than the pool.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %v", err)
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, sqlCreateMigrationsTable); err != nil {
+		return fmt.Errorf("error creating migrations table: %v", err)
+	}
+	return fn(conn)
+}
+
+//lock takes the migration lock for the dialect on the connection
+//and returns a function that releases it
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	switch m.dialect {
+	case sqldb.MySQL:
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, sqlGetLock, lockName, int(m.LockTimeout.Seconds())).Scan(&locked); err != nil {
+			return nil, fmt.Errorf("error acquiring migration lock: %v", err)
+		}
+		if !locked.Valid || locked.Int64 != 1 {
+			return nil, ErrLockTimeout
+		}
+		return func() { conn.ExecContext(ctx, sqlReleaseLock, lockName) }, nil
+	case sqldb.Postgres:
+		//poll rather than block so that the lock timeout applies
+		deadline := time.Now().Add(m.LockTimeout)
+		for {
+			var locked bool
+			if err := conn.QueryRowContext(ctx, m.dialect.Rebind(sqlTryAdvisoryLock), lockKey).Scan(&locked); err != nil {
+				return nil, fmt.Errorf("error acquiring migration lock: %v", err)
+			}
+			if locked {
+				return func() { conn.ExecContext(ctx, m.dialect.Rebind(sqlAdvisoryUnlock), lockKey) }, nil
+			}
+			if time.Now().After(deadline) {
+				return nil, ErrLockTimeout
+			}
+			time.Sleep(lockPollInterval)
+		}
+	default:
+		return func() {}, nil
+	}
+}
+
+//appliedVersions returns the set of migration versions already applied
+func (m *Migrator) appliedVersions(conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(context.Background(), sqlGetVersions)
+	if err != nil {
+		return nil, fmt.Errorf("error getting applied migrations: %v", err)
+	}
+	defer rows.Close()
+	versions := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	return versions, rows.Err()
+}
+
+//run executes the statements of one direction of a migration and then
+//records the result with the given statement. MySQL commits schema
+//changes implicitly, so a migration that fails partway must be repaired
+//by hand before it can be retried.
+func (m *Migrator) run(conn *sql.Conn, mig *Migration, script string, record string, args ...interface{}) error {
+	ctx := context.Background()
+	for _, stmt := range statements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("error running migration %d_%s: %v", mig.Version, mig.Name, err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, m.dialect.Rebind(record), args...); err != nil {
+		return fmt.Errorf("error recording migration %d_%s: %v", mig.Version, mig.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+var testMigrations = []*Migration{
+	{1, "create_a", "create table a (id int)", "drop table a"},
+	{2, "alter_a", "alter table a add column b int; alter table a add column c int", "alter table a drop column b, drop column c"},
+}
+
+//expectLock sets up the expectations for acquiring the migration
+//lock and creating the migrations table
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetLock)).
+		WithArgs(lockName, 60).
+		WillReturnRows(sqlmock.NewRows([]string{"get_lock"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(sqlCreateMigrationsTable)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(sqlReleaseLock)).
+		WithArgs(lockName).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigratorUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.MySQL, testMigrations)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetVersions)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("alter table a add column b int")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("alter table a add column c int")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertVersion)).
+		WithArgs(2, "alter_a", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectUnlock(mock)
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("unexpected error migrating up: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("incorrect migrations applied: %v", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMigratorUpFail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.MySQL, testMigrations)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetVersions)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectExec(regexp.QuoteMeta("create table a (id int)")).
+		WillReturnError(fmt.Errorf("table exists"))
+	expectUnlock(mock)
+
+	applied, err := migrator.Up()
+	if err == nil {
+		t.Errorf("expected error when a migration fails")
+	}
+	if len(applied) != 0 {
+		t.Errorf("no migrations should be recorded as applied: %v", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMigratorLockTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.MySQL, testMigrations)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetLock)).
+		WithArgs(lockName, 60).
+		WillReturnRows(sqlmock.NewRows([]string{"get_lock"}).AddRow(0))
+
+	if _, err := migrator.Up(); err != ErrLockTimeout {
+		t.Errorf("incorrect error: expected %v but got %v", ErrLockTimeout, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMigratorDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.MySQL, testMigrations)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetVersions)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta("alter table a drop column b, drop column c")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(sqlDeleteVersion)).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectUnlock(mock)
+
+	reverted, err := migrator.Down(1)
+	if err != nil {
+		t.Fatalf("unexpected error migrating down: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Errorf("incorrect migrations reverted: %v", reverted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMigratorDownUnknownVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.MySQL, testMigrations)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetVersions)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1).AddRow(2).AddRow(3))
+	expectUnlock(mock)
+
+	if _, err := migrator.Down(1); err != ErrUnknownVersion {
+		t.Errorf("incorrect error: expected %v but got %v", ErrUnknownVersion, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMigratorVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.MySQL, testMigrations)
+
+	expectLock(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetVersions)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1).AddRow(2))
+	expectUnlock(mock)
+
+	version, err := migrator.Version()
+	if err != nil {
+		t.Fatalf("unexpected error getting version: %v", err)
+	}
+	if version != 2 {
+		t.Errorf("incorrect version: expected 2 but got %d", version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestMigratorPostgresLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator := NewMigrator(db, sqldb.Postgres, testMigrations[:1])
+
+	//the first attempt finds the lock taken by another gateway
+	mock.ExpectQuery(regexp.QuoteMeta("select pg_try_advisory_lock($1)")).
+		WithArgs(lockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectQuery(regexp.QuoteMeta("select pg_try_advisory_lock($1)")).
+		WithArgs(lockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta(sqlCreateMigrationsTable)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetVersions)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectExec(regexp.QuoteMeta("create table a (id int)")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("insert into schema_migrations(version, name, applied_at) values ($1,$2,$3)")).
+		WithArgs(1, "create_a", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("select pg_advisory_unlock($1)")).
+		WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("unexpected error migrating up: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
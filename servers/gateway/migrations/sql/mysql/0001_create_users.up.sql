@@ -8,4 +8,4 @@ create table if not exists users (
     photo_url varchar(255) not null,
     UNIQUE(id),
     UNIQUE(user_name)
-);
\ No newline at end of file
+);
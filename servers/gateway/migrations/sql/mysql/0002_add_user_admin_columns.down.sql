@@ -0,0 +1,4 @@
+alter table users
+    drop column is_admin,
+    drop column suspended,
+    drop column reset_required;
@@ -0,0 +1,4 @@
+alter table users
+    add column is_admin boolean not null default false,
+    add column suspended boolean not null default false,
+    add column reset_required boolean not null default false;
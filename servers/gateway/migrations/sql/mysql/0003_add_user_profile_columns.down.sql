@@ -0,0 +1,7 @@
+alter table users
+    drop column bio,
+    drop column pronouns,
+    drop column time_zone,
+    drop column status_text,
+    drop column status_emoji,
+    drop column status_expires;
@@ -0,0 +1,7 @@
+alter table users
+    add column bio varchar(1200) not null default '',
+    add column pronouns varchar(128) not null default '',
+    add column time_zone varchar(64) not null default '',
+    add column status_text varchar(400) not null default '',
+    add column status_emoji varchar(128) not null default '',
+    add column status_expires bigint not null default 0;
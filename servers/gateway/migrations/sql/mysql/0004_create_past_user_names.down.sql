@@ -0,0 +1 @@
+drop table if exists past_user_names;
@@ -0,0 +1,8 @@
+create table if not exists past_user_names (
+    id int not null auto_increment primary key,
+    user_id int not null,
+    user_name varchar(255) not null,
+    changed_at bigint not null,
+    index (user_id),
+    index (user_name)
+);
@@ -0,0 +1,7 @@
+create table if not exists blocks (
+    blocker_id int not null,
+    blocked_id int not null,
+    created_at bigint not null,
+    primary key (blocker_id, blocked_id),
+    index (blocked_id)
+);
@@ -0,0 +1,8 @@
+create table if not exists contacts (
+    requester_id int not null,
+    addressee_id int not null,
+    accepted boolean not null default false,
+    created_at bigint not null,
+    primary key (requester_id, addressee_id),
+    index (addressee_id)
+);
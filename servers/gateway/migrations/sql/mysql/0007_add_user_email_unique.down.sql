@@ -0,0 +1 @@
+alter table users drop index email;
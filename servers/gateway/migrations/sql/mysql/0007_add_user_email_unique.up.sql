@@ -0,0 +1 @@
+alter table users add unique index email (email);
@@ -0,0 +1,16 @@
+create table if not exists invites (
+    code varchar(64) not null primary key,
+    max_uses int not null,
+    uses int not null default 0,
+    expires_at bigint not null default 0,
+    created_by int not null,
+    created_at bigint not null
+);
+
+create table if not exists invite_redemptions (
+    code varchar(64) not null,
+    user_id int not null,
+    redeemed_at bigint not null,
+    primary key (code, user_id),
+    index (user_id)
+);
@@ -0,0 +1,12 @@
+create table if not exists security_events (
+    id bigint not null auto_increment primary key,
+    type varchar(32) not null,
+    user_id int not null,
+    email varchar(320) not null,
+    ip varchar(64) not null,
+    user_agent varchar(512) not null,
+    detail varchar(255) not null,
+    created_at bigint not null,
+    index (user_id, created_at),
+    index (created_at)
+);
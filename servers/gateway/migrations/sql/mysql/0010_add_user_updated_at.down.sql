@@ -0,0 +1,4 @@
+drop table if exists deleted_users;
+alter table users
+    drop index users_updated_at,
+    drop column updated_at;
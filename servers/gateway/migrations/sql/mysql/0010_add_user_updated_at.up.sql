@@ -0,0 +1,8 @@
+alter table users
+    add column updated_at bigint not null default 0,
+    add index users_updated_at (updated_at);
+create table if not exists deleted_users (
+    user_id int not null primary key,
+    deleted_at bigint not null,
+    index (deleted_at)
+);
@@ -0,0 +1 @@
+alter table contacts drop index contacts_pair;
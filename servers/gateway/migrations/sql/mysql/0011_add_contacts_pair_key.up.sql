@@ -0,0 +1,8 @@
+update contacts c join contacts r
+    on r.requester_id = c.addressee_id and r.addressee_id = c.requester_id
+    set c.accepted = true;
+delete c from contacts c join contacts r
+    on r.requester_id = c.addressee_id and r.addressee_id = c.requester_id
+    where c.requester_id > c.addressee_id;
+alter table contacts
+    add unique index contacts_pair ((least(requester_id, addressee_id)), (greatest(requester_id, addressee_id)));
@@ -0,0 +1,4 @@
+drop table if exists contacts;
+drop table if exists blocks;
+drop table if exists past_user_names;
+drop table if exists users;
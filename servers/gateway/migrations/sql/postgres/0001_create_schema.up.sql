@@ -0,0 +1,46 @@
+create extension if not exists citext;
+
+create table if not exists users (
+    id bigserial primary key,
+    email citext not null unique,
+    pass_hash bytea not null,
+    user_name citext not null unique,
+    first_name varchar(64) not null,
+    last_name varchar(128) not null,
+    photo_url varchar(255) not null,
+    is_admin boolean not null default false,
+    suspended boolean not null default false,
+    reset_required boolean not null default false,
+    bio varchar(1200) not null default '',
+    pronouns varchar(128) not null default '',
+    time_zone varchar(64) not null default '',
+    status_text varchar(400) not null default '',
+    status_emoji varchar(128) not null default '',
+    status_expires bigint not null default 0
+);
+
+create table if not exists past_user_names (
+    id bigserial primary key,
+    user_id bigint not null,
+    user_name citext not null,
+    changed_at bigint not null
+);
+create index if not exists past_user_names_user_id on past_user_names (user_id);
+create index if not exists past_user_names_user_name on past_user_names (user_name);
+
+create table if not exists blocks (
+    blocker_id bigint not null,
+    blocked_id bigint not null,
+    created_at bigint not null,
+    primary key (blocker_id, blocked_id)
+);
+create index if not exists blocks_blocked_id on blocks (blocked_id);
+
+create table if not exists contacts (
+    requester_id bigint not null,
+    addressee_id bigint not null,
+    accepted boolean not null default false,
+    created_at bigint not null,
+    primary key (requester_id, addressee_id)
+);
+create index if not exists contacts_addressee_id on contacts (addressee_id);
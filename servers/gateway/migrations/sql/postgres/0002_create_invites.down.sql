@@ -0,0 +1,2 @@
+drop table if exists invite_redemptions;
+drop table if exists invites;
@@ -0,0 +1,12 @@
+create table if not exists security_events (
+    id bigserial primary key,
+    type varchar(32) not null,
+    user_id bigint not null,
+    email citext not null,
+    ip varchar(64) not null,
+    user_agent varchar(512) not null,
+    detail varchar(255) not null,
+    created_at bigint not null
+);
+create index if not exists security_events_user_id on security_events (user_id, created_at);
+create index if not exists security_events_created_at on security_events (created_at);
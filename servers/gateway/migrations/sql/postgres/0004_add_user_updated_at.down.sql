@@ -0,0 +1,3 @@
+drop table if exists deleted_users;
+drop index if exists users_updated_at;
+alter table users drop column updated_at;
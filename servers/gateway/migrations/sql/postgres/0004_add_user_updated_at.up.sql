@@ -0,0 +1,7 @@
+alter table users add column updated_at bigint not null default 0;
+create index if not exists users_updated_at on users (updated_at);
+create table if not exists deleted_users (
+    user_id bigint not null primary key,
+    deleted_at bigint not null
+);
+create index if not exists deleted_users_deleted_at on deleted_users (deleted_at);
@@ -0,0 +1,9 @@
+update contacts set accepted = true where exists (
+    select 1 from contacts r
+    where r.requester_id = contacts.addressee_id and r.addressee_id = contacts.requester_id
+);
+delete from contacts where requester_id > addressee_id and exists (
+    select 1 from contacts r
+    where r.requester_id = contacts.addressee_id and r.addressee_id = contacts.requester_id
+);
+create unique index if not exists contacts_pair on contacts (least(requester_id, addressee_id), greatest(requester_id, addressee_id));
@@ -0,0 +1,44 @@
+create table if not exists users (
+    id integer primary key autoincrement,
+    email text not null collate nocase unique,
+    pass_hash blob not null,
+    user_name text not null collate nocase unique,
+    first_name text not null,
+    last_name text not null,
+    photo_url text not null,
+    is_admin boolean not null default false,
+    suspended boolean not null default false,
+    reset_required boolean not null default false,
+    bio text not null default '',
+    pronouns text not null default '',
+    time_zone text not null default '',
+    status_text text not null default '',
+    status_emoji text not null default '',
+    status_expires bigint not null default 0
+);
+
+create table if not exists past_user_names (
+    id integer primary key autoincrement,
+    user_id bigint not null,
+    user_name text not null collate nocase,
+    changed_at bigint not null
+);
+create index if not exists past_user_names_user_id on past_user_names (user_id);
+create index if not exists past_user_names_user_name on past_user_names (user_name);
+
+create table if not exists blocks (
+    blocker_id bigint not null,
+    blocked_id bigint not null,
+    created_at bigint not null,
+    primary key (blocker_id, blocked_id)
+);
+create index if not exists blocks_blocked_id on blocks (blocked_id);
+
+create table if not exists contacts (
+    requester_id bigint not null,
+    addressee_id bigint not null,
+    accepted boolean not null default false,
+    created_at bigint not null,
+    primary key (requester_id, addressee_id)
+);
+create index if not exists contacts_addressee_id on contacts (addressee_id);
@@ -0,0 +1,16 @@
+create table if not exists invites (
+    code varchar(64) not null primary key,
+    max_uses integer not null,
+    uses integer not null default 0,
+    expires_at bigint not null default 0,
+    created_by bigint not null,
+    created_at bigint not null
+);
+
+create table if not exists invite_redemptions (
+    code varchar(64) not null,
+    user_id bigint not null,
+    redeemed_at bigint not null,
+    primary key (code, user_id)
+);
+create index if not exists invite_redemptions_user_id on invite_redemptions (user_id);
@@ -0,0 +1,12 @@
+create table if not exists security_events (
+    id integer primary key autoincrement,
+    type text not null,
+    user_id bigint not null,
+    email text not null collate nocase,
+    ip text not null,
+    user_agent text not null,
+    detail text not null,
+    created_at bigint not null
+);
+create index if not exists security_events_user_id on security_events (user_id, created_at);
+create index if not exists security_events_created_at on security_events (created_at);
@@ -0,0 +1 @@
+drop index if exists contacts_pair;
@@ -0,0 +1,55 @@
+package audit
+
+import "sync"
+
+//MemStore represents an audit.Store held in memory
+type MemStore struct {
+	mx     sync.RWMutex
+	events []*Event
+}
+
+//NewMemStore constructs a new MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{}
+}
+
+//Record appends a copy of the event to the log, assigning it the next ID
+func (ms *MemStore) Record(event *Event) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	recorded := *event
+	recorded.ID = int64(len(ms.events) + 1)
+	ms.events = append(ms.events, &recorded)
+	return nil
+}
+
+//Find returns the events matching the query, most recent first
+func (ms *MemStore) Find(q *Query) ([]*Event, error) {
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	found := []*Event{}
+	skipped := 0
+	for i := len(ms.events) - 1; i >= 0 && len(found) < q.Limit; i-- {
+		event := ms.events[i]
+		if !q.matches(event) {
+			continue
+		}
+		if skipped < q.Offset {
+			skipped++
+			continue
+		}
+		copied := *event
+		found = append(found, &copied)
+	}
+	return found, nil
+}
+
+//matches returns true if the event matches the query's filters
+func (q *Query) matches(event *Event) bool {
+	return (q.UserID == 0 || event.UserID == q.UserID) &&
+		(len(q.Type) == 0 || event.Type == q.Type) &&
+		(len(q.Email) == 0 || event.Email == q.Email) &&
+		(len(q.IP) == 0 || event.IP == q.IP) &&
+		(q.Since.IsZero() || !event.CreatedAt.Before(q.Since)) &&
+		(q.Until.IsZero() || event.CreatedAt.Before(q.Until))
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+//SQLStore represents an audit.Store backed by MySQL,
+//PostgreSQL or SQLite
+type SQLStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+}
+
+//NewSQLStore constructs a new SQLStore
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return NewDialectStore(db, sqldb.MySQL)
+}
+
+//NewDialectStore constructs a new SQLStore for a database of the given dialect
+func NewDialectStore(db *sql.DB, dialect sqldb.Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+//maxEmailLength is the width of the email column. Failed sign-ins
+//record whatever email was given, which may be longer.
+const maxEmailLength = 320
+
+const sqlEventColumns = "type, user_id, email, ip, user_agent, detail, created_at"
+const sqlInsertEvent = "insert into security_events(" + sqlEventColumns + ") values (?,?,?,?,?,?,?)"
+const sqlFindEvents = "select id, " + sqlEventColumns + " from security_events"
+
+//Record appends the event to the security_events table
+func (ss *SQLStore) Record(event *Event) error {
+	_, err := ss.db.Exec(ss.dialect.Rebind(sqlInsertEvent), event.Type, event.UserID, truncate(event.Email, maxEmailLength),
+		event.IP, event.UserAgent, event.Detail, event.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("error recording event: %v", err)
+	}
+	return nil
+}
+
+//Find returns the events matching the query, most recent first
+func (ss *SQLStore) Find(q *Query) ([]*Event, error) {
+	query := sqlFindEvents
+	conditions := []string{}
+	args := []interface{}{}
+	if q.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, q.UserID)
+	}
+	if len(q.Type) > 0 {
+		conditions = append(conditions, "type = ?")
+		args = append(args, q.Type)
+	}
+	if len(q.Email) > 0 {
+		conditions = append(conditions, "email = ?")
+		args = append(args, q.Email)
+	}
+	if len(q.IP) > 0 {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, q.IP)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, q.Until.Unix())
+	}
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by created_at desc, id desc limit ? offset ?"
+	args = append(args, q.Limit, q.Offset)
+
+	rows, err := ss.db.Query(ss.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error finding events: %v", err)
+	}
+	defer rows.Close()
+	events := []*Event{}
+	for rows.Next() {
+		event := &Event{}
+		var createdAt int64
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.Email, &event.IP,
+			&event.UserAgent, &event.Detail, &createdAt); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		event.CreatedAt = time.Unix(createdAt, 0).UTC()
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return events, nil
+}
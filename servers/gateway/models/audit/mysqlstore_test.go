@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var eventColumns = []string{"id", "type", "user_id", "email", "ip", "user_agent", "detail", "created_at"}
+
+func TestRecordEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+	event := &Event{Type: TypeSignInFailed, UserID: 1, Email: "test@example.com", IP: "10.0.0.1",
+		UserAgent: "curl", Detail: "wrong password", CreatedAt: time.Unix(1000, 0)}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertEvent)).
+		WithArgs(TypeSignInFailed, 1, "test@example.com", "10.0.0.1", "curl", "wrong password", 1000).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := store.Record(event); err != nil {
+		t.Errorf("unexpected error recording event: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestFindEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+	cases := []struct {
+		name          string
+		query         *Query
+		expectedQuery string
+		args          []driver.Value
+	}{
+		{
+			"No Filters",
+			&Query{Limit: 50},
+			sqlFindEvents + " order by created_at desc, id desc limit ? offset ?",
+			[]driver.Value{50, 0},
+		},
+		{
+			"All Filters",
+			&Query{UserID: 1, Type: TypeSignIn, Email: "test@example.com", IP: "10.0.0.1",
+				Since: time.Unix(1000, 0), Until: time.Unix(2000, 0), Offset: 10, Limit: 5},
+			sqlFindEvents + " where user_id = ? and type = ? and email = ? and ip = ? and created_at >= ? and created_at < ?" +
+				" order by created_at desc, id desc limit ? offset ?",
+			[]driver.Value{1, TypeSignIn, "test@example.com", "10.0.0.1", 1000, 2000, 5, 10},
+		},
+	}
+
+	for _, c := range cases {
+		mock.ExpectQuery(regexp.QuoteMeta(c.expectedQuery)).
+			WithArgs(c.args...).
+			WillReturnRows(sqlmock.NewRows(eventColumns).
+				AddRow(2, TypeSignIn, 1, "test@example.com", "10.0.0.1", "curl", "", 1500))
+
+		found, err := store.Find(c.query)
+		if err != nil {
+			t.Errorf("case %s: unexpected error finding events: %v", c.name, err)
+			continue
+		}
+		if len(found) != 1 || found[0].ID != 2 || found[0].Type != TypeSignIn || !found[0].CreatedAt.Equal(time.Unix(1500, 0)) {
+			t.Errorf("case %s: incorrect events: %+v", c.name, found)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//MultiSink records each event to every one of its sinks
+type MultiSink []Sink
+
+//Record records the event to every sink, returning the first error.
+//A failing sink doesn't stop the event reaching the others.
+func (ms MultiSink) Record(event *Event) error {
+	var firstErr error
+	for _, sink := range ms {
+		if err := sink.Record(event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+//WriterSink records events to a writer as lines of JSON,
+//so that they can be shipped to a log collector
+type WriterSink struct {
+	mx sync.Mutex
+	w  io.Writer
+}
+
+//NewWriterSink constructs a new WriterSink writing to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	if w == nil {
+		panic("nil writer")
+	}
+	return &WriterSink{w: w}
+}
+
+//Record writes the event as a line of JSON
+func (ws *WriterSink) Record(event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %v", err)
+	}
+	ws.mx.Lock()
+	defer ws.mx.Unlock()
+	if _, err := ws.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing event: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type failingSink struct{}
+
+func (fs failingSink) Record(event *Event) error {
+	return errors.New("sink is unavailable")
+}
+
+func TestMultiSink(t *testing.T) {
+	store := NewMemStore()
+	buf := &bytes.Buffer{}
+	sink := MultiSink{failingSink{}, store, NewWriterSink(buf)}
+
+	req := httptest.NewRequest("POST", "/v1/sessions", nil)
+	req.Header.Set("User-Agent", strings.Repeat("é", maxUserAgentLength))
+	event := NewEvent(req, TypeSignIn, 1)
+	if err := sink.Record(event); err == nil {
+		t.Errorf("expected the failing sink's error")
+	}
+
+	found, _ := store.Find(&Query{UserID: 1, Limit: 10})
+	if len(found) != 1 || found[0].Type != TypeSignIn || found[0].IP != "192.0.2.1" {
+		t.Errorf("incorrect events in store: %+v", found)
+	}
+	recorded := &Event{}
+	if err := json.Unmarshal(buf.Bytes(), recorded); err != nil {
+		t.Fatalf("error decoding written event: %v", err)
+	}
+	if recorded.UserID != 1 || recorded.Type != TypeSignIn {
+		t.Errorf("incorrect written event: %+v", recorded)
+	}
+	if len(recorded.UserAgent) != maxUserAgentLength || !strings.HasPrefix(req.UserAgent(), recorded.UserAgent) {
+		t.Errorf("user agent wasn't truncated to %d bytes: got %d", maxUserAgentLength, len(recorded.UserAgent))
+	}
+}
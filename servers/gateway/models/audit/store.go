@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"net"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+//The types of security events recorded in the audit log
+const (
+	TypeSignUp               = "sign-up"
+	TypeSignIn               = "sign-in"
+	TypeSignInFailed         = "sign-in-failed"
+	TypeSignOut              = "sign-out"
+	TypePasswordChange       = "password-change"
+	TypePasswordChangeFailed = "password-change-failed"
+	TypeProfileUpdate        = "profile-update"
+)
+
+//maxUserAgentLength is the longest user agent recorded,
+//since clients can send user agents of any length
+const maxUserAgentLength = 512
+
+//Event is a security-relevant action recorded in the audit log.
+//Events are never changed or deleted once they're recorded.
+type Event struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	//UserID is the user the event is about, or 0 for
+	//failed sign-ins with an email that has no user
+	UserID int64 `json:"userID"`
+	//Email is the email given when signing up or signing in
+	Email     string `json:"email,omitempty"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	//Detail describes the event, such as why a sign-in
+	//failed or which fields of a profile were changed
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//NewEvent returns an event of the given type about the user,
+//recording the client address and user agent of the request
+func NewEvent(r *http.Request, eventType string, userID int64) *Event {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return &Event{
+		Type:      eventType,
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		CreatedAt: time.Now(),
+	}
+}
+
+//Query filters and paginates the events returned from Store.Find
+type Query struct {
+	//UserID, if non-zero, only matches events about the given user
+	UserID int64
+	//Type, Email and IP, if non-empty, only match
+	//events with exactly the given value
+	Type  string
+	Email string
+	IP    string
+	//Since and Until, if non-zero, only match events
+	//recorded at or after and before the given times
+	Since time.Time
+	Until time.Time
+	//Offset is the number of matching events to skip
+	Offset int
+	//Limit is the maximum number of events to return
+	Limit int
+}
+
+//Sink is somewhere audit events are recorded
+type Sink interface {
+	//Record appends the event to the audit log
+	Record(event *Event) error
+}
+
+//Store is an audit log that can be queried
+type Store interface {
+	Sink
+
+	//Find returns the events matching the query, most recent first
+	Find(query *Query) ([]*Event, error)
+}
+
+//truncate shortens s to at most n bytes
+//without splitting a UTF-8 encoded character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
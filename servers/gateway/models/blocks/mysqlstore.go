@@ -0,0 +1,104 @@
+package blocks
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+//SQLStore represents a blocks.Store backed by MySQL,
+//PostgreSQL or SQLite
+type SQLStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+}
+
+//NewSQLStore constructs a new SQLStore
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return NewDialectStore(db, sqldb.MySQL)
+}
+
+//NewDialectStore constructs a new SQLStore for a database of the given dialect
+func NewDialectStore(db *sql.DB, dialect sqldb.Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+const sqlInsertBlock = "insert ignore into blocks(blocker_id, blocked_id, created_at) values (?,?,?)"
+const sqlInsertBlockOnConflict = "insert into blocks(blocker_id, blocked_id, created_at) values (?,?,?) on conflict do nothing"
+const sqlDeleteBlock = "delete from blocks where blocker_id = ? and blocked_id = ?"
+const sqlGetBlocked = "select blocked_id from blocks where blocker_id = ? order by created_at desc"
+const sqlGetBlockers = "select blocker_id from blocks where blocked_id = ?"
+const sqlDeleteUserBlocks = "delete from blocks where blocker_id = ? or blocked_id = ?"
+
+//Insert records that blockerID has blocked blockedID.
+//Blocking a user who is already blocked is not an error.
+func (ss *SQLStore) Insert(blockerID int64, blockedID int64) error {
+	if blockerID == blockedID {
+		return ErrBlockSelf
+	}
+	//PostgreSQL and SQLite don't support "insert ignore"
+	stmt := sqlInsertBlock
+	if ss.dialect != sqldb.MySQL {
+		stmt = sqlInsertBlockOnConflict
+	}
+	if _, err := ss.db.Exec(ss.dialect.Rebind(stmt), blockerID, blockedID, time.Now().Unix()); err != nil {
+		return fmt.Errorf("error inserting block: %v", err)
+	}
+	return nil
+}
+
+//Delete removes the block of blockedID by blockerID.
+//Removing a block that doesn't exist is not an error.
+func (ss *SQLStore) Delete(blockerID int64, blockedID int64) error {
+	if _, err := ss.db.Exec(ss.dialect.Rebind(sqlDeleteBlock), blockerID, blockedID); err != nil {
+		return fmt.Errorf("error deleting block: %v", err)
+	}
+	return nil
+}
+
+//GetBlocked returns the IDs of the users blocked
+//by the given user, most recently blocked first
+func (ss *SQLStore) GetBlocked(blockerID int64) ([]int64, error) {
+	return ss.getIDs(sqlGetBlocked, blockerID)
+}
+
+//GetBlockers returns the IDs of the users
+//who have blocked the given user
+func (ss *SQLStore) GetBlockers(blockedID int64) ([]int64, error) {
+	return ss.getIDs(sqlGetBlockers, blockedID)
+}
+
+//DeleteUser removes every block made by or against
+//the given user, for when the user is deleted
+func (ss *SQLStore) DeleteUser(userID int64) error {
+	if _, err := ss.db.Exec(ss.dialect.Rebind(sqlDeleteUserBlocks), userID, userID); err != nil {
+		return fmt.Errorf("error deleting blocks: %v", err)
+	}
+	return nil
+}
+
+//getIDs returns the user IDs selected by the query
+func (ss *SQLStore) getIDs(query string, args ...interface{}) ([]int64, error) {
+	rows, err := ss.db.Query(ss.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,115 @@
+package blocks
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestInsertBlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertBlock)).
+		WithArgs(1, 2, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := store.Insert(1, 2); err != nil {
+		t.Fatalf("unexpected error during successful insert: %v", err)
+	}
+	if err := store.Insert(1, 1); err != ErrBlockSelf {
+		t.Fatalf("incorrect error when blocking self: expected %v but got %v", ErrBlockSelf, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestInsertBlockFail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertBlock)).
+		WithArgs(1, 2, sqlmock.AnyArg()).
+		WillReturnError(fmt.Errorf("some error"))
+
+	if err := store.Insert(1, 2); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestGetBlocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetBlocked)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"blocked_id"}).AddRow(5).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetBlockers)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"blocker_id"}))
+
+	blocked, err := store.GetBlocked(1)
+	if err != nil {
+		t.Fatalf("unexpected error getting blocked users: %v", err)
+	}
+	if !reflect.DeepEqual(blocked, []int64{5, 3}) {
+		t.Errorf("incorrect blocked users: expected [5 3] but got %v", blocked)
+	}
+	blockers, err := store.GetBlockers(1)
+	if err != nil {
+		t.Fatalf("unexpected error getting blockers: %v", err)
+	}
+	if len(blockers) != 0 {
+		t.Errorf("incorrect blockers: expected none but got %v", blockers)
+	}
+}
+
+func TestDeleteBlocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlDeleteBlock)).
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(sqlDeleteUserBlocks)).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if err := store.Delete(1, 2); err != nil {
+		t.Fatalf("unexpected error during successful delete: %v", err)
+	}
+	if err := store.DeleteUser(1); err != nil {
+		t.Fatalf("unexpected error during successful delete: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
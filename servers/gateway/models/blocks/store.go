@@ -0,0 +1,31 @@
+package blocks
+
+import "errors"
+
+//ErrBlockSelf is returned when a user tries to block themselves
+var ErrBlockSelf = errors.New("users can't block themselves")
+
+//Store represents a store for the block relation between users.
+//A block is directional: the blocker no longer wants to be
+//contacted by the blocked user.
+type Store interface {
+	//Insert records that blockerID has blocked blockedID.
+	//Blocking a user who is already blocked is not an error.
+	Insert(blockerID int64, blockedID int64) error
+
+	//Delete removes the block of blockedID by blockerID.
+	//Removing a block that doesn't exist is not an error.
+	Delete(blockerID int64, blockedID int64) error
+
+	//GetBlocked returns the IDs of the users blocked
+	//by the given user, most recently blocked first
+	GetBlocked(blockerID int64) ([]int64, error)
+
+	//GetBlockers returns the IDs of the users
+	//who have blocked the given user
+	GetBlockers(blockedID int64) ([]int64, error)
+
+	//DeleteUser removes every block made by or against
+	//the given user, for when the user is deleted
+	DeleteUser(userID int64) error
+}
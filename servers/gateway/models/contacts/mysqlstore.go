@@ -0,0 +1,207 @@
+package contacts
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+//SQLStore represents a contacts.Store backed by MySQL,
+//PostgreSQL or SQLite.
+//Each relation is a single row keyed by the requesting user,
+//whose accepted column is set once the request is accepted.
+//A unique key on the unordered pair of users keeps two users
+//requesting each other at once from inserting two rows.
+type SQLStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+}
+
+//NewSQLStore constructs a new SQLStore
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return NewDialectStore(db, sqldb.MySQL)
+}
+
+//NewDialectStore constructs a new SQLStore for a database of the given dialect
+func NewDialectStore(db *sql.DB, dialect sqldb.Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+const sqlGetRelation = "select requester_id, accepted from contacts where " +
+	"(requester_id = ? and addressee_id = ?) or (requester_id = ? and addressee_id = ?)"
+const sqlInsertRequest = "insert into contacts(requester_id, addressee_id, accepted, created_at) values (?,?,false,?)"
+const sqlAcceptRequest = "update contacts set accepted = true where requester_id = ? and addressee_id = ? and accepted = false"
+const sqlDeleteRequest = "delete from contacts where requester_id = ? and addressee_id = ? and accepted = false"
+const sqlDeleteContact = "delete from contacts where accepted = true and " +
+	"((requester_id = ? and addressee_id = ?) or (requester_id = ? and addressee_id = ?))"
+const sqlGetContacts = "select addressee_id from contacts where requester_id = ? and accepted = true " +
+	"union select requester_id from contacts where addressee_id = ? and accepted = true"
+const sqlGetRequests = "select requester_id, addressee_id, created_at from contacts " +
+	"where (requester_id = ? or addressee_id = ?) and accepted = false order by created_at desc"
+const sqlDeleteUserContacts = "delete from contacts where requester_id = ? or addressee_id = ?"
+
+//insertRequestError is returned by request when inserting a request
+//fails, which happens if the other user's request for the same pair
+//was inserted at the same time, since each pair of users has only one row
+type insertRequestError struct {
+	err error
+}
+
+func (e *insertRequestError) Error() string {
+	return fmt.Sprintf("error inserting request: %v", e.err)
+}
+
+//Request records a contact request from fromID to toID. If toID
+//already requested fromID, the request is accepted instead and
+//accepted is true.
+func (cs *SQLStore) Request(fromID int64, toID int64) (bool, error) {
+	if fromID == toID {
+		return false, ErrRequestSelf
+	}
+	accepted, err := cs.request(fromID, toID)
+	if _, ok := err.(*insertRequestError); ok {
+		//if the users requested each other at the same time, the other
+		//request is committed by now, so trying again accepts it
+		accepted, err = cs.request(fromID, toID)
+	}
+	return accepted, err
+}
+
+//request records a contact request from fromID to toID in a transaction
+func (cs *SQLStore) request(fromID int64, toID int64) (bool, error) {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	var requesterID int64
+	var accepted bool
+	err = tx.QueryRow(cs.dialect.Rebind(sqlGetRelation), fromID, toID, toID, fromID).Scan(&requesterID, &accepted)
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec(cs.dialect.Rebind(sqlInsertRequest), fromID, toID, time.Now().Unix()); err != nil {
+			tx.Rollback()
+			return false, &insertRequestError{err}
+		}
+	case err != nil:
+		tx.Rollback()
+		return false, fmt.Errorf("error getting contact: %v", err)
+	case accepted:
+		tx.Rollback()
+		return false, ErrAlreadyContacts
+	case requesterID == fromID:
+		//the request was already sent
+		tx.Rollback()
+		return false, nil
+	default:
+		//the other user already asked, so this accepts their request
+		if _, err := tx.Exec(cs.dialect.Rebind(sqlAcceptRequest), toID, fromID); err != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("error accepting request: %v", err)
+		}
+		accepted = true
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return accepted, nil
+}
+
+//Accept accepts the pending request sent by fromID to toID
+func (cs *SQLStore) Accept(toID int64, fromID int64) error {
+	return cs.execOne(sqlAcceptRequest, fromID, toID)
+}
+
+//DeleteRequest declines or cancels the pending
+//request sent by fromID to toID
+func (cs *SQLStore) DeleteRequest(fromID int64, toID int64) error {
+	return cs.execOne(sqlDeleteRequest, fromID, toID)
+}
+
+//Remove removes the contact between the two users
+func (cs *SQLStore) Remove(userID int64, contactID int64) error {
+	if _, err := cs.db.Exec(cs.dialect.Rebind(sqlDeleteContact), userID, contactID, contactID, userID); err != nil {
+		return fmt.Errorf("error deleting contact: %v", err)
+	}
+	return nil
+}
+
+//GetContacts returns the IDs of the given user's contacts
+func (cs *SQLStore) GetContacts(userID int64) ([]int64, error) {
+	rows, err := cs.db.Query(cs.dialect.Rebind(sqlGetContacts), userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return ids, nil
+}
+
+//GetRequests returns the pending requests sent to (incoming)
+//and sent by (outgoing) the given user, newest first
+func (cs *SQLStore) GetRequests(userID int64) ([]*Request, []*Request, error) {
+	rows, err := cs.db.Query(cs.dialect.Rebind(sqlGetRequests), userID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	incoming := []*Request{}
+	outgoing := []*Request{}
+	for rows.Next() {
+		req := &Request{}
+		var createdAt int64
+		if err := rows.Scan(&req.FromID, &req.ToID, &createdAt); err != nil {
+			return nil, nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		req.CreatedAt = time.Unix(createdAt, 0).UTC()
+		if req.ToID == userID {
+			incoming = append(incoming, req)
+		} else {
+			outgoing = append(outgoing, req)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return incoming, outgoing, nil
+}
+
+//DeleteUser removes every contact and request involving
+//the given user, for when the user is deleted
+func (cs *SQLStore) DeleteUser(userID int64) error {
+	if _, err := cs.db.Exec(cs.dialect.Rebind(sqlDeleteUserContacts), userID, userID); err != nil {
+		return fmt.Errorf("error deleting contacts: %v", err)
+	}
+	return nil
+}
+
+//execOne executes the statement and returns ErrRequestNotFound
+//if it didn't change any rows
+func (cs *SQLStore) execOne(stmt string, args ...interface{}) error {
+	result, err := cs.db.Exec(cs.dialect.Rebind(stmt), args...)
+	if err != nil {
+		return fmt.Errorf("error updating request: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if n == 0 {
+		return ErrRequestNotFound
+	}
+	return nil
+}
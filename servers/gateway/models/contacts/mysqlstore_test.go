@@ -0,0 +1,183 @@
+package contacts
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRequestNew(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRelation)).
+		WithArgs(1, 2, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id", "accepted"}))
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertRequest)).
+		WithArgs(1, 2, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accepted, err := store.Request(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error during successful request: %v", err)
+	}
+	if accepted {
+		t.Error("new request should not be accepted")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRequestAcceptsReverse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRelation)).
+		WithArgs(1, 2, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id", "accepted"}).AddRow(2, false))
+	mock.ExpectExec(regexp.QuoteMeta(sqlAcceptRequest)).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accepted, err := store.Request(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error during successful request: %v", err)
+	}
+	if !accepted {
+		t.Error("request to a user who already asked should be accepted")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRequestConcurrentReverse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	//user 2's request is inserted between reading and inserting,
+	//so the insert violates the unique key on the pair
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRelation)).
+		WithArgs(1, 2, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id", "accepted"}))
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertRequest)).
+		WithArgs(1, 2, sqlmock.AnyArg()).
+		WillReturnError(errors.New("Duplicate entry '1-2' for key 'contacts_pair'"))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRelation)).
+		WithArgs(1, 2, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id", "accepted"}).AddRow(2, false))
+	mock.ExpectExec(regexp.QuoteMeta(sqlAcceptRequest)).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	accepted, err := store.Request(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error during concurrent request: %v", err)
+	}
+	if !accepted {
+		t.Error("request racing a request from the other user should be accepted")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRequestAlreadyContacts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRelation)).
+		WithArgs(1, 2, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id", "accepted"}).AddRow(2, true))
+	mock.ExpectRollback()
+
+	if _, err := store.Request(1, 2); err != ErrAlreadyContacts {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrAlreadyContacts, err)
+	}
+	if _, err := store.Request(3, 3); err != ErrRequestSelf {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrRequestSelf, err)
+	}
+}
+
+func TestAcceptNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlAcceptRequest)).
+		WithArgs(5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := store.Accept(1, 5); err != ErrRequestNotFound {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrRequestNotFound, err)
+	}
+}
+
+func TestGetRequests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRequests)).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"requester_id", "addressee_id", "created_at"}).
+			AddRow(3, 1, 200).
+			AddRow(1, 4, 100))
+
+	incoming, outgoing, err := store.GetRequests(1)
+	if err != nil {
+		t.Fatalf("unexpected error getting requests: %v", err)
+	}
+	if len(incoming) != 1 || incoming[0].FromID != 3 {
+		t.Errorf("incorrect incoming requests: %v", incoming)
+	}
+	if len(outgoing) != 1 || outgoing[0].ToID != 4 {
+		t.Errorf("incorrect outgoing requests: %v", outgoing)
+	}
+}
@@ -0,0 +1,55 @@
+package contacts
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrRequestNotFound is returned when there is no pending
+//contact request between the given users
+var ErrRequestNotFound = errors.New("contact request not found")
+
+//ErrAlreadyContacts is returned when requesting a
+//user who is already one of the user's contacts
+var ErrAlreadyContacts = errors.New("users are already contacts")
+
+//ErrRequestSelf is returned when a user tries to add themselves
+var ErrRequestSelf = errors.New("users can't add themselves as a contact")
+
+//Request represents a pending contact request
+type Request struct {
+	FromID    int64
+	ToID      int64
+	CreatedAt time.Time
+}
+
+//Store represents a store for the contact graph between users.
+//Contacts are mutual: a request from one user becomes a contact
+//for both once the other user accepts it.
+type Store interface {
+	//Request records a contact request from fromID to toID. If toID
+	//already requested fromID, the request is accepted instead and
+	//accepted is true.
+	Request(fromID int64, toID int64) (accepted bool, err error)
+
+	//Accept accepts the pending request sent by fromID to toID
+	Accept(toID int64, fromID int64) error
+
+	//DeleteRequest declines or cancels the pending
+	//request sent by fromID to toID
+	DeleteRequest(fromID int64, toID int64) error
+
+	//Remove removes the contact between the two users
+	Remove(userID int64, contactID int64) error
+
+	//GetContacts returns the IDs of the given user's contacts
+	GetContacts(userID int64) ([]int64, error)
+
+	//GetRequests returns the pending requests sent to (incoming)
+	//and sent by (outgoing) the given user, newest first
+	GetRequests(userID int64) (incoming []*Request, outgoing []*Request, err error)
+
+	//DeleteUser removes every contact and request involving
+	//the given user, for when the user is deleted
+	DeleteUser(userID int64) error
+}
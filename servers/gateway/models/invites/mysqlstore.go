@@ -0,0 +1,174 @@
+package invites
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+//SQLStore represents an invites.Store backed by MySQL,
+//PostgreSQL or SQLite
+type SQLStore struct {
+	db      *sql.DB
+	dialect sqldb.Dialect
+}
+
+//NewSQLStore constructs a new SQLStore
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return NewDialectStore(db, sqldb.MySQL)
+}
+
+//NewDialectStore constructs a new SQLStore for a database of the given dialect
+func NewDialectStore(db *sql.DB, dialect sqldb.Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+const sqlInviteColumns = "code, max_uses, uses, expires_at, created_by, created_at"
+const sqlInsertInvite = "insert into invites(" + sqlInviteColumns + ") values (?,?,?,?,?,?)"
+const sqlGetInvite = "select " + sqlInviteColumns + " from invites where code = ?"
+const sqlListInvites = "select " + sqlInviteColumns + " from invites order by created_at desc, code"
+const sqlDeleteInvite = "delete from invites where code = ?"
+const sqlUseInvite = "update invites set uses = uses + 1 where code = ? and (max_uses = 0 or uses < max_uses)"
+const sqlInsertRedemption = "insert into invite_redemptions(code, user_id, redeemed_at) values (?,?,?)"
+const sqlGetRedemptions = "select code, user_id, redeemed_at from invite_redemptions where code = ? order by redeemed_at desc"
+
+//Insert inserts the invite
+func (ss *SQLStore) Insert(invite *Invite) error {
+	var expiresAt int64
+	if invite.ExpiresAt != nil {
+		expiresAt = invite.ExpiresAt.Unix()
+	}
+	_, err := ss.db.Exec(ss.dialect.Rebind(sqlInsertInvite), invite.Code, invite.MaxUses, invite.Uses,
+		expiresAt, invite.CreatedBy, invite.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("error inserting invite: %v", err)
+	}
+	return nil
+}
+
+//Get returns the invite with the given code
+func (ss *SQLStore) Get(code string) (*Invite, error) {
+	rows, err := ss.db.Query(ss.dialect.Rebind(sqlGetInvite), code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	invites, err := scanInvites(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(invites) == 0 {
+		return nil, ErrInviteNotFound
+	}
+	return invites[0], nil
+}
+
+//List returns every invite, most recently created first
+func (ss *SQLStore) List() ([]*Invite, error) {
+	rows, err := ss.db.Query(sqlListInvites)
+	if err != nil {
+		return nil, fmt.Errorf("error listing invites: %v", err)
+	}
+	defer rows.Close()
+	return scanInvites(rows)
+}
+
+//Delete deletes the invite with the given code
+func (ss *SQLStore) Delete(code string) error {
+	result, err := ss.db.Exec(ss.dialect.Rebind(sqlDeleteInvite), code)
+	if err != nil {
+		return fmt.Errorf("error deleting invite: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+//Redeem records that the user with the given ID signed up with the
+//invite. The use is counted with a conditional update so that
+//concurrent signups can't use an invite more times than it allows.
+func (ss *SQLStore) Redeem(code string, userID int64) error {
+	invite, err := ss.Get(code)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := invite.Check(now); err != nil {
+		return err
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	result, err := tx.Exec(ss.dialect.Rebind(sqlUseInvite), code)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error using invite: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		tx.Rollback()
+		return ErrInviteUsedUp
+	}
+	if _, err := tx.Exec(ss.dialect.Rebind(sqlInsertRedemption), code, userID, now.Unix()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error recording redemption: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+//GetRedemptions returns the redemptions of the invite
+//with the given code, most recent first
+func (ss *SQLStore) GetRedemptions(code string) ([]*Redemption, error) {
+	rows, err := ss.db.Query(ss.dialect.Rebind(sqlGetRedemptions), code)
+	if err != nil {
+		return nil, fmt.Errorf("error getting redemptions: %v", err)
+	}
+	defer rows.Close()
+	redemptions := []*Redemption{}
+	for rows.Next() {
+		redemption := &Redemption{}
+		var redeemedAt int64
+		if err := rows.Scan(&redemption.Code, &redemption.UserID, &redeemedAt); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		redemption.RedeemedAt = time.Unix(redeemedAt, 0).UTC()
+		redemptions = append(redemptions, redemption)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return redemptions, nil
+}
+
+//scanInvites scans every row into an Invite,
+//using the columns in sqlInviteColumns
+func scanInvites(rows *sql.Rows) ([]*Invite, error) {
+	invites := []*Invite{}
+	for rows.Next() {
+		invite := &Invite{}
+		var expiresAt, createdAt int64
+		if err := rows.Scan(&invite.Code, &invite.MaxUses, &invite.Uses, &expiresAt, &invite.CreatedBy, &createdAt); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		if expiresAt > 0 {
+			t := time.Unix(expiresAt, 0).UTC()
+			invite.ExpiresAt = &t
+		}
+		invite.CreatedAt = time.Unix(createdAt, 0).UTC()
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return invites, nil
+}
@@ -0,0 +1,151 @@
+package invites
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var inviteColumns = []string{"code", "max_uses", "uses", "expires_at", "created_by", "created_at"}
+
+func TestInsertAndGetInvite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+	expiresAt := time.Unix(2000, 0).UTC()
+	invite := &Invite{Code: "ABC", MaxUses: 5, ExpiresAt: &expiresAt, CreatedBy: 1, CreatedAt: time.Unix(1000, 0).UTC()}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertInvite)).
+		WithArgs("ABC", 5, 0, 2000, 1, 1000).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetInvite)).
+		WithArgs("ABC").
+		WillReturnRows(sqlmock.NewRows(inviteColumns).AddRow("ABC", 5, 0, 2000, 1, 1000))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetInvite)).
+		WithArgs("MISSING").
+		WillReturnRows(sqlmock.NewRows(inviteColumns))
+
+	if err := store.Insert(invite); err != nil {
+		t.Fatalf("unexpected error inserting invite: %v", err)
+	}
+	got, err := store.Get("ABC")
+	if err != nil {
+		t.Fatalf("unexpected error getting invite: %v", err)
+	}
+	if got.Code != "ABC" || got.MaxUses != 5 || !got.ExpiresAt.Equal(expiresAt) || !got.CreatedAt.Equal(invite.CreatedAt) {
+		t.Errorf("incorrect invite: %+v", got)
+	}
+	if _, err := store.Get("MISSING"); err != ErrInviteNotFound {
+		t.Errorf("incorrect error: expected %v but got %v", ErrInviteNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRedeemInvite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetInvite)).
+		WithArgs("ABC").
+		WillReturnRows(sqlmock.NewRows(inviteColumns).AddRow("ABC", 1, 0, 0, 1, 1000))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(sqlUseInvite)).
+		WithArgs("ABC").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertRedemption)).
+		WithArgs("ABC", 7, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := store.Redeem("ABC", 7); err != nil {
+		t.Fatalf("unexpected error redeeming invite: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRedeemInviteUsedConcurrently(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	//the invite had a use left when read, but another
+	//signup used it before this one could
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetInvite)).
+		WithArgs("ABC").
+		WillReturnRows(sqlmock.NewRows(inviteColumns).AddRow("ABC", 1, 0, 0, 1, 1000))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(sqlUseInvite)).
+		WithArgs("ABC").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := store.Redeem("ABC", 7); err != ErrInviteUsedUp {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrInviteUsedUp, err)
+	}
+}
+
+func TestRedeemInviteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetInvite)).
+		WithArgs("ABC").
+		WillReturnRows(sqlmock.NewRows(inviteColumns).AddRow("ABC", 0, 0, 2000, 1, 1000))
+
+	if err := store.Redeem("ABC", 7); err != ErrInviteExpired {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrInviteExpired, err)
+	}
+}
+
+func TestGetRedemptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewSQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetRedemptions)).
+		WithArgs("ABC").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "user_id", "redeemed_at"}).
+			AddRow("ABC", 8, 3000).
+			AddRow("ABC", 7, 2000))
+
+	redemptions, err := store.GetRedemptions("ABC")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(redemptions) != 2 || redemptions[0].UserID != 8 || !redemptions[1].RedeemedAt.Equal(time.Unix(2000, 0)) {
+		t.Errorf("incorrect redemptions: %v", redemptions)
+	}
+}
@@ -0,0 +1,71 @@
+package invites
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Mode is a policy for who may sign up
+type Mode string
+
+//The signup modes
+const (
+	//ModeOpen lets anyone sign up
+	ModeOpen Mode = "open"
+	//ModeInvite requires a valid invite code to sign up
+	ModeInvite Mode = "invite"
+	//ModeDomains lets users with an email address in one of the
+	//allowed domains sign up, and anyone else with an invite code
+	ModeDomains Mode = "domains"
+)
+
+//Policy decides who may sign up
+type Policy struct {
+	Mode    Mode
+	Domains []string
+}
+
+//ParsePolicy returns the policy with the given mode and comma-separated
+//allowed email domains. An empty mode is ModeOpen.
+func ParsePolicy(mode string, domains string) (*Policy, error) {
+	policy := &Policy{Mode: Mode(mode)}
+	if len(mode) == 0 {
+		policy.Mode = ModeOpen
+	}
+	for _, domain := range strings.Split(domains, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if len(domain) > 0 {
+			policy.Domains = append(policy.Domains, domain)
+		}
+	}
+	switch policy.Mode {
+	case ModeOpen, ModeInvite:
+	case ModeDomains:
+		if len(policy.Domains) == 0 {
+			return nil, fmt.Errorf("signup mode %q requires at least one allowed domain", ModeDomains)
+		}
+	default:
+		return nil, fmt.Errorf("unknown signup mode %q: must be %q, %q or %q", mode, ModeOpen, ModeInvite, ModeDomains)
+	}
+	return policy, nil
+}
+
+//RequiresInvite returns whether a user with
+//the given email needs an invite to sign up
+func (p *Policy) RequiresInvite(email string) bool {
+	switch p.Mode {
+	case ModeInvite:
+		return true
+	case ModeDomains:
+		at := strings.LastIndex(email, "@")
+		domain := strings.ToLower(email[at+1:])
+		for _, allowed := range p.Domains {
+			if domain == allowed {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
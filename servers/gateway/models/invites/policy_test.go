@@ -0,0 +1,89 @@
+package invites
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		mode          string
+		domains       string
+		expectedMode  Mode
+		expectedError bool
+	}{
+		{"", "", ModeOpen, false},
+		{"open", "", ModeOpen, false},
+		{"invite", "", ModeInvite, false},
+		{"domains", "example.com, Example.org", ModeDomains, false},
+		{"domains", "", "", true},
+		{"closed", "", "", true},
+	}
+	for _, c := range cases {
+		policy, err := ParsePolicy(c.mode, c.domains)
+		if (err != nil) != c.expectedError {
+			t.Errorf("case %q: expected error %v but got %v", c.mode, c.expectedError, err)
+			continue
+		}
+		if err == nil && policy.Mode != c.expectedMode {
+			t.Errorf("case %q: incorrect mode: expected %s but got %s", c.mode, c.expectedMode, policy.Mode)
+		}
+	}
+}
+
+func TestRequiresInvite(t *testing.T) {
+	domains, _ := ParsePolicy("domains", "example.com,Example.org")
+	cases := []struct {
+		name     string
+		policy   *Policy
+		email    string
+		expected bool
+	}{
+		{"Open", &Policy{Mode: ModeOpen}, "anyone@gmail.com", false},
+		{"Invite", &Policy{Mode: ModeInvite}, "anyone@example.com", true},
+		{"Allowed Domain", domains, "test@example.com", false},
+		{"Allowed Domain Case", domains, "test@EXAMPLE.ORG", false},
+		{"Other Domain", domains, "test@gmail.com", true},
+		{"Subdomain", domains, "test@mail.example.com", true},
+	}
+	for _, c := range cases {
+		if required := c.policy.RequiresInvite(c.email); required != c.expected {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, required)
+		}
+	}
+}
+
+func TestInviteCheck(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	cases := []struct {
+		name     string
+		invite   *Invite
+		expected error
+	}{
+		{"Unlimited", &Invite{Uses: 100}, nil},
+		{"Uses Left", &Invite{MaxUses: 2, Uses: 1, ExpiresAt: &future}, nil},
+		{"Used Up", &Invite{MaxUses: 1, Uses: 1}, ErrInviteUsedUp},
+		{"Expired", &Invite{ExpiresAt: &past}, ErrInviteExpired},
+	}
+	for _, c := range cases {
+		if err := c.invite.Check(now); err != c.expected {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, err)
+		}
+	}
+}
+
+func TestNewInviteToInvite(t *testing.T) {
+	first, err := (&NewInvite{MaxUses: 1}).ToInvite(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := (&NewInvite{}).ToInvite(3)
+	if len(first.Code) != 16 || first.Code == second.Code || NormalizeCode(first.Code) != first.Code {
+		t.Errorf("codes should be distinct, normalized and 16 characters: got %s and %s", first.Code, second.Code)
+	}
+	if first.MaxUses != 1 || first.CreatedBy != 3 || first.ExpiresAt != nil {
+		t.Errorf("incorrect invite: %+v", first)
+	}
+}
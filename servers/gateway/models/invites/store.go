@@ -0,0 +1,123 @@
+package invites
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//ErrInviteNotFound is returned when there is no invite with the given code
+var ErrInviteNotFound = errors.New("invite not found")
+
+//ErrInviteExpired is returned when redeeming an invite that has expired
+var ErrInviteExpired = errors.New("invite has expired")
+
+//ErrInviteUsedUp is returned when redeeming an invite
+//that has already been used as many times as it allows
+var ErrInviteUsedUp = errors.New("invite has already been used")
+
+//codeBytes is the number of random bytes in an invite code
+const codeBytes = 10
+
+//Invite is a code that lets users sign up when signup is invite-only
+type Invite struct {
+	Code string `json:"code"`
+	//MaxUses is the number of users who can sign up
+	//with the invite, or 0 if there is no limit
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	CreatedBy int64      `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+//Redemption records a user signing up with an invite
+type Redemption struct {
+	Code       string    `json:"code"`
+	UserID     int64     `json:"userID"`
+	RedeemedAt time.Time `json:"redeemedAt"`
+}
+
+//NewInvite represents an administrator's request to create an invite
+type NewInvite struct {
+	MaxUses   int        `json:"maxUses"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+//Check returns ErrInviteExpired or ErrInviteUsedUp if
+//the invite can't be redeemed at the given time
+func (i *Invite) Check(now time.Time) error {
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return ErrInviteExpired
+	}
+	if i.MaxUses > 0 && i.Uses >= i.MaxUses {
+		return ErrInviteUsedUp
+	}
+	return nil
+}
+
+//Validate returns an error if the new invite is invalid
+func (ni *NewInvite) Validate() error {
+	if ni.MaxUses < 0 {
+		return fmt.Errorf("maxUses must not be negative")
+	}
+	if ni.ExpiresAt != nil && !ni.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expiresAt must be in the future")
+	}
+	return nil
+}
+
+//ToInvite returns an Invite with a new random code,
+//created by the administrator with the given ID
+func (ni *NewInvite) ToInvite(createdBy int64) (*Invite, error) {
+	buf := make([]byte, codeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("error generating invite code: %v", err)
+	}
+	invite := &Invite{
+		Code:      base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf),
+		MaxUses:   ni.MaxUses,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().Truncate(time.Second).UTC(),
+	}
+	if ni.ExpiresAt != nil {
+		expiresAt := ni.ExpiresAt.Truncate(time.Second).UTC()
+		invite.ExpiresAt = &expiresAt
+	}
+	return invite, nil
+}
+
+//NormalizeCode returns the code as it's stored, so that
+//users can type invite codes in either case
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+//Store represents a store for invites
+type Store interface {
+	//Insert inserts the invite
+	Insert(invite *Invite) error
+
+	//Get returns the invite with the given code,
+	//or ErrInviteNotFound if there isn't one
+	Get(code string) (*Invite, error)
+
+	//List returns every invite, most recently created first
+	List() ([]*Invite, error)
+
+	//Delete deletes the invite with the given code so that it
+	//can't be redeemed, keeping the record of its redemptions
+	Delete(code string) error
+
+	//Redeem records that the user with the given ID signed up with
+	//the invite, returning ErrInviteNotFound, ErrInviteExpired or
+	//ErrInviteUsedUp if the invite can't be redeemed
+	Redeem(code string, userID int64) error
+
+	//GetRedemptions returns the redemptions of the invite
+	//with the given code, most recent first
+	GetRedemptions(code string) ([]*Redemption, error)
+}
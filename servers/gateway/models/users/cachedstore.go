@@ -0,0 +1,157 @@
+package users
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+//CachedStore is a read-through cache in front of another Store.
+//Users looked up by ID are cached until they expire or are changed
+//through the CachedStore. The cache is local to the process, so
+//changes made by other gateways are only seen once entries expire.
+type CachedStore struct {
+	Store
+	users *cache.Cache
+}
+
+//NewCachedStore constructs a new CachedStore in front of the store,
+//caching each user for the given duration
+func NewCachedStore(store Store, ttl time.Duration) *CachedStore {
+	if store == nil {
+		panic("nil store")
+	}
+	return &CachedStore{
+		Store: store,
+		users: cache.New(ttl, 2*ttl),
+	}
+}
+
+//GetByID returns the User with the given ID
+func (cs *CachedStore) GetByID(ctx context.Context, id int64) (*User, error) {
+	if user, ok := cs.get(id); ok {
+		return user, nil
+	}
+	user, err := cs.Store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cs.set(user)
+	return user, nil
+}
+
+//GetByIDFresh returns the User with the given ID from the store
+//behind the cache, and caches it in place of any older copy
+func (cs *CachedStore) GetByIDFresh(ctx context.Context, id int64) (*User, error) {
+	user, err := cs.Store.GetByIDFresh(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cs.set(user)
+	return user, nil
+}
+
+//GetByIDs returns the Users with the given IDs in the same order
+//as the IDs, skipping any IDs for which there is no user.
+//Only the users that aren't cached are read from the store.
+func (cs *CachedStore) GetByIDs(ctx context.Context, ids []int64) ([]*User, error) {
+	byID := make(map[int64]*User, len(ids))
+	missing := []int64{}
+	for _, id := range ids {
+		if user, ok := cs.get(id); ok {
+			byID[id] = user
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		fetched, err := cs.Store.GetByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range fetched {
+			cs.set(user)
+			byID[user.ID] = user
+		}
+	}
+
+	found := []*User{}
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			found = append(found, user)
+		}
+	}
+	return found, nil
+}
+
+//Update applies UserUpdates to the given user ID
+//and returns the newly-updated user
+func (cs *CachedStore) Update(ctx context.Context, id int64, updates *Updates) (*User, error) {
+	defer cs.invalidate(id)
+	return cs.Store.Update(ctx, id, updates)
+}
+
+//Delete deletes the user with the given ID
+func (cs *CachedStore) Delete(ctx context.Context, id int64) error {
+	defer cs.invalidate(id)
+	return cs.Store.Delete(ctx, id)
+}
+
+//SetSuspended sets whether the user with the given ID
+//is suspended and returns the newly-updated user
+func (cs *CachedStore) SetSuspended(ctx context.Context, id int64, suspended bool) (*User, error) {
+	defer cs.invalidate(id)
+	return cs.Store.SetSuspended(ctx, id, suspended)
+}
+
+//SetPasswordResetRequired sets whether the user with the given ID
+//must change their password and returns the newly-updated user
+func (cs *CachedStore) SetPasswordResetRequired(ctx context.Context, id int64, required bool) (*User, error) {
+	defer cs.invalidate(id)
+	return cs.Store.SetPasswordResetRequired(ctx, id, required)
+}
+
+//UpdatePhotoURL sets the photo URL of the user with
+//the given ID and returns the newly-updated user
+func (cs *CachedStore) UpdatePhotoURL(ctx context.Context, id int64, photoURL string) (*User, error) {
+	defer cs.invalidate(id)
+	return cs.Store.UpdatePhotoURL(ctx, id, photoURL)
+}
+
+//UpdateUserName changes the user name of the user with the given ID,
+//records the old user name as a PastUserName, and returns the newly-updated user
+func (cs *CachedStore) UpdateUserName(ctx context.Context, id int64, userName string) (*User, error) {
+	defer cs.invalidate(id)
+	return cs.Store.UpdateUserName(ctx, id, userName)
+}
+
+//UpdatePassword replaces the password hash of the user with the given ID,
+//clears any required password reset, and returns the newly-updated user
+func (cs *CachedStore) UpdatePassword(ctx context.Context, id int64, passHash []byte) (*User, error) {
+	defer cs.invalidate(id)
+	return cs.Store.UpdatePassword(ctx, id, passHash)
+}
+
+//get returns a copy of the cached user with the given ID, if any
+func (cs *CachedStore) get(id int64) (*User, bool) {
+	cached, ok := cs.users.Get(strconv.FormatInt(id, 10))
+	if !ok {
+		return nil, false
+	}
+	return copyUser(cached.(*User), time.Now()), true
+}
+
+//set caches a copy of the user, so that callers
+//changing the user don't change the cache
+func (cs *CachedStore) set(user *User) {
+	cs.users.SetDefault(strconv.FormatInt(user.ID, 10), copyUser(user, time.Time{}))
+}
+
+//invalidate removes the user with the given ID from the cache. It's
+//called once the change is made, though a lookup that read the user
+//before the change may still cache the old user until it expires.
+func (cs *CachedStore) invalidate(id int64) {
+	cs.users.Delete(strconv.FormatInt(id, 10))
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+//countingStore counts the users read from the store it wraps
+type countingStore struct {
+	Store
+	reads int
+}
+
+func (cs *countingStore) GetByID(ctx context.Context, id int64) (*User, error) {
+	cs.reads++
+	return cs.Store.GetByID(ctx, id)
+}
+
+func (cs *countingStore) GetByIDs(ctx context.Context, ids []int64) ([]*User, error) {
+	cs.reads += len(ids)
+	return cs.Store.GetByIDs(ctx, ids)
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	counter := &countingStore{Store: NewMemStore()}
+	store := NewCachedStore(counter, time.Minute)
+	first, _ := store.Insert(ctx, &User{Email: "first@example.com", UserName: "first", FirstName: "First"})
+	second, _ := store.Insert(ctx, &User{Email: "second@example.com", UserName: "second"})
+
+	user, err := store.GetByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("unexpected error getting user: %v", err)
+	}
+	//changing a returned user shouldn't change the cache
+	user.FirstName = "Changed"
+	if user, _ := store.GetByID(ctx, first.ID); user.FirstName != "First" || counter.reads != 1 {
+		t.Errorf("expected cached user with first name First but got %s after %d reads", user.FirstName, counter.reads)
+	}
+
+	//only the uncached user is read from the store
+	found, err := store.GetByIDs(ctx, []int64{second.ID, first.ID})
+	if err != nil || len(found) != 2 || found[0].ID != second.ID || found[1].ID != first.ID {
+		t.Fatalf("incorrect users: %v (error %v)", found, err)
+	}
+	if counter.reads != 2 {
+		t.Errorf("incorrect number of reads: expected 2 but got %d", counter.reads)
+	}
+
+	if _, err := store.Update(ctx, first.ID, &Updates{FirstName: "Updated"}); err != nil {
+		t.Fatalf("unexpected error updating user: %v", err)
+	}
+	if user, _ := store.GetByID(ctx, first.ID); user.FirstName != "Updated" {
+		t.Errorf("expected updated first name but got %s", user.FirstName)
+	}
+
+	if err := store.Delete(ctx, second.ID); err != nil {
+		t.Fatalf("unexpected error deleting user: %v", err)
+	}
+	if _, err := store.GetByID(ctx, second.ID); err != ErrUserNotFound {
+		t.Errorf("incorrect error for deleted user: expected %v but got %v", ErrUserNotFound, err)
+	}
+}
+
+func TestCachedStoreGetByIDFresh(t *testing.T) {
+	ctx := context.Background()
+	backing := NewMemStore()
+	store := NewCachedStore(backing, time.Minute)
+	user, _ := store.Insert(ctx, &User{Email: "test@example.com", UserName: "tester"})
+	if _, err := store.GetByID(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error getting user: %v", err)
+	}
+
+	//a change made by another gateway goes straight to the backing store
+	if _, err := backing.SetSuspended(ctx, user.ID, true); err != nil {
+		t.Fatalf("unexpected error suspending user: %v", err)
+	}
+	if cached, _ := store.GetByID(ctx, user.ID); cached.Suspended {
+		t.Fatalf("expected the cached user before reading it fresh")
+	}
+	if fresh, err := store.GetByIDFresh(ctx, user.ID); err != nil || !fresh.Suspended {
+		t.Errorf("expected fresh suspended user but got %+v (error %v)", fresh, err)
+	}
+	//the fresh user replaces the cached one
+	if cached, _ := store.GetByID(ctx, user.ID); !cached.Suspended {
+		t.Errorf("expected the fresh user to be cached")
+	}
+}
@@ -0,0 +1,345 @@
+package users
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//MemStore represents an in-process memory users store with the same
+//semantics as SQLStore. This should be used only for testing and
+//development. Production systems should use a shared database store.
+type MemStore struct {
+	mx        sync.RWMutex
+	users     map[int64]*User
+	pastNames []*PastUserName
+	lastID    int64
+}
+
+//NewMemStore constructs and returns a new, empty MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{
+		users: make(map[int64]*User),
+	}
+}
+
+//GetByID returns the User with the given ID
+func (ms *MemStore) GetByID(ctx context.Context, id int64) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	user, ok := ms.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return copyUser(user, time.Now()), nil
+}
+
+//GetByIDFresh returns the User with the given ID. A MemStore
+//has no cache, so it's the same as GetByID.
+func (ms *MemStore) GetByIDFresh(ctx context.Context, id int64) (*User, error) {
+	return ms.GetByID(ctx, id)
+}
+
+//GetByIDs returns the Users with the given IDs in the same order
+//as the IDs, skipping any IDs for which there is no user
+func (ms *MemStore) GetByIDs(ctx context.Context, ids []int64) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	now := time.Now()
+	found := []*User{}
+	for _, id := range ids {
+		if user, ok := ms.users[id]; ok {
+			found = append(found, copyUser(user, now))
+		}
+	}
+	return found, nil
+}
+
+//GetByEmail returns the User with the given email
+func (ms *MemStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return ms.find(ctx, func(u *User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+//GetByUserName returns the User with the given Username
+func (ms *MemStore) GetByUserName(ctx context.Context, username string) (*User, error) {
+	return ms.find(ctx, func(u *User) bool { return strings.EqualFold(u.UserName, username) })
+}
+
+//Insert inserts the user into the store, and returns
+//the newly-inserted User, complete with the assigned ID
+func (ms *MemStore) Insert(ctx context.Context, user *User) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, existing := range ms.users {
+		if strings.EqualFold(existing.Email, user.Email) {
+			return nil, ErrDuplicateEmail
+		}
+		if strings.EqualFold(existing.UserName, user.UserName) {
+			return nil, ErrDuplicateUserName
+		}
+	}
+	ms.lastID++
+	//like SQLStore, only the sign up fields are stored
+	ms.users[ms.lastID] = &User{
+		ID:        ms.lastID,
+		Email:     user.Email,
+		PassHash:  append([]byte(nil), user.PassHash...),
+		UserName:  user.UserName,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		PhotoURL:  user.PhotoURL,
+	}
+	user.ID = ms.lastID
+	return user, nil
+}
+
+//Update applies UserUpdates to the given user ID
+//and returns the newly-updated user
+func (ms *MemStore) Update(ctx context.Context, id int64, updates *Updates) (*User, error) {
+	var result *User
+	err := ms.update(ctx, id, func(user *User) error {
+		if err := user.ApplyUpdates(updates); err != nil {
+			return err
+		}
+		result = copyUser(user, time.Now())
+		//SQLStore returns the status even if it has already expired
+		result.Status = copyStatus(user.Status)
+		//and stores its expiry as whole seconds
+		if user.Status != nil && user.Status.ExpiresAt != nil {
+			expiresAt := time.Unix(user.Status.ExpiresAt.Unix(), 0).UTC()
+			user.Status.ExpiresAt = &expiresAt
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//Delete deletes the user with the given ID
+func (ms *MemStore) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if _, ok := ms.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(ms.users, id)
+	return nil
+}
+
+//List returns the users matching the given params, ordered by ID
+func (ms *MemStore) List(ctx context.Context, params *ListParams) ([]*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	query := strings.ToLower(params.Query)
+	now := time.Now()
+	matches := []*User{}
+	for _, user := range ms.users {
+		if len(query) > 0 && !strings.HasPrefix(strings.ToLower(user.UserName), query) &&
+			!strings.HasPrefix(strings.ToLower(user.Email), query) {
+			continue
+		}
+		if params.Suspended != nil && user.Suspended != *params.Suspended {
+			continue
+		}
+		matches = append(matches, copyUser(user, now))
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID < matches[j].ID
+	})
+	if params.Offset >= len(matches) {
+		return []*User{}, nil
+	}
+	matches = matches[params.Offset:]
+	if len(matches) > params.Limit {
+		matches = matches[:params.Limit]
+	}
+	return matches, nil
+}
+
+//SetSuspended sets whether the user with the given ID
+//is suspended and returns the newly-updated user
+func (ms *MemStore) SetSuspended(ctx context.Context, id int64, suspended bool) (*User, error) {
+	return ms.updateAndGet(ctx, id, func(user *User) {
+		user.Suspended = suspended
+	})
+}
+
+//SetPasswordResetRequired sets whether the user with the given ID
+//must change their password and returns the newly-updated user
+func (ms *MemStore) SetPasswordResetRequired(ctx context.Context, id int64, required bool) (*User, error) {
+	return ms.updateAndGet(ctx, id, func(user *User) {
+		user.PasswordResetRequired = required
+	})
+}
+
+//UpdatePhotoURL sets the photo URL of the user with
+//the given ID and returns the newly-updated user
+func (ms *MemStore) UpdatePhotoURL(ctx context.Context, id int64, photoURL string) (*User, error) {
+	return ms.updateAndGet(ctx, id, func(user *User) {
+		user.PhotoURL = photoURL
+	})
+}
+
+//UpdateUserName changes the user name of the user with the given ID,
+//records the old user name as a PastUserName, and returns the newly-updated user
+func (ms *MemStore) UpdateUserName(ctx context.Context, id int64, userName string) (*User, error) {
+	var result *User
+	err := ms.update(ctx, id, func(user *User) error {
+		for _, existing := range ms.users {
+			if existing.ID != id && strings.EqualFold(existing.UserName, userName) {
+				return ErrDuplicateUserName
+			}
+		}
+		ms.pastNames = append(ms.pastNames, &PastUserName{
+			UserID:    id,
+			UserName:  user.UserName,
+			ChangedAt: time.Unix(time.Now().Unix(), 0).UTC(),
+		})
+		user.UserName = userName
+		result = copyUser(user, time.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//GetPastUserNames returns the user names given up by the
+//user with the given ID, most recently changed first
+func (ms *MemStore) GetPastUserNames(ctx context.Context, id int64) ([]*PastUserName, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	names := []*PastUserName{}
+	//past names are appended in order, so walk backwards for the most recent
+	for i := len(ms.pastNames) - 1; i >= 0; i-- {
+		if ms.pastNames[i].UserID == id {
+			name := *ms.pastNames[i]
+			names = append(names, &name)
+		}
+	}
+	return names, nil
+}
+
+//GetUserNameHolder returns the ID of the user who most recently gave up
+//the given user name at or after `since`, or 0 if nobody did
+func (ms *MemStore) GetUserNameHolder(ctx context.Context, userName string, since time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	for i := len(ms.pastNames) - 1; i >= 0; i-- {
+		past := ms.pastNames[i]
+		if strings.EqualFold(past.UserName, userName) && past.ChangedAt.Unix() >= since.Unix() {
+			return past.UserID, nil
+		}
+	}
+	return 0, nil
+}
+
+//UpdatePassword replaces the password hash of the user with the given ID,
+//clears any required password reset, and returns the newly-updated user
+func (ms *MemStore) UpdatePassword(ctx context.Context, id int64, passHash []byte) (*User, error) {
+	return ms.updateAndGet(ctx, id, func(user *User) {
+		user.PassHash = append([]byte(nil), passHash...)
+		user.PasswordResetRequired = false
+	})
+}
+
+//find returns a copy of the first user matching the predicate
+func (ms *MemStore) find(ctx context.Context, match func(u *User) bool) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ms.mx.RLock()
+	defer ms.mx.RUnlock()
+	for _, user := range ms.users {
+		if match(user) {
+			return copyUser(user, time.Now()), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+//update calls fn with the stored user with the given ID while holding the
+//write lock. Changes fn makes to the user are discarded if it returns an error.
+func (ms *MemStore) update(ctx context.Context, id int64, fn func(user *User) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	stored, ok := ms.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user := copyUser(stored, time.Time{})
+	if err := fn(user); err != nil {
+		return err
+	}
+	ms.users[id] = user
+	return nil
+}
+
+//updateAndGet applies fn to the user with the given
+//ID and returns a copy of the updated user
+func (ms *MemStore) updateAndGet(ctx context.Context, id int64, fn func(user *User)) (*User, error) {
+	var result *User
+	err := ms.update(ctx, id, func(user *User) error {
+		fn(user)
+		result = copyUser(user, time.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//copyUser returns a deep copy of the user so that callers can't change
+//the stored user. If now is non-zero, a status that has expired by then
+//is cleared, as SQLStore does when reading users.
+func copyUser(user *User, now time.Time) *User {
+	u := *user
+	u.PassHash = append([]byte(nil), user.PassHash...)
+	u.Status = copyStatus(user.Status)
+	if !now.IsZero() {
+		u.Status = u.ActiveStatus(now)
+	}
+	return &u
+}
+
+func copyStatus(status *Status) *Status {
+	if status == nil {
+		return nil
+	}
+	s := *status
+	if status.ExpiresAt != nil {
+		expiresAt := *status.ExpiresAt
+		s.ExpiresAt = &expiresAt
+	}
+	return &s
+}
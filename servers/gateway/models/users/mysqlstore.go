@@ -1,23 +1,35 @@
 package users
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/indexes"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 )
 
 // SQLStore keeps tracks of the current active database connection so that we don't need to open a new connection
 // every time we execute a query
 type SQLStore struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect sqldb.Dialect
 }
 
 //NewSQLStore constructs a new MySQLStore
 func NewSQLStore(db *sql.DB) *SQLStore {
+	return NewDialectStore(db, sqldb.MySQL)
+}
+
+//NewDialectStore constructs a new SQLStore for a database of the given dialect
+func NewDialectStore(db *sql.DB, dialect sqldb.Dialect) *SQLStore {
 	return &SQLStore{
-		db: db,
+		db:      db,
+		dialect: dialect,
 	}
 }
 
@@ -26,145 +38,493 @@ var v struct {
 }
 
 const sqlGetAllUsers = "select id, user_name, first_name, last_name from users"
-const sqlColumnListWithID = "id, email, pass_hash, user_name, first_name, last_name, photo_url"
+const sqlColumnListWithID = "id, email, pass_hash, user_name, first_name, last_name, photo_url, is_admin, suspended, reset_required, " +
+	"bio, pronouns, time_zone, status_text, status_emoji, status_expires"
 const sqlColumnListNoID = "email, pass_hash, user_name, first_name, last_name, photo_url"
 const sqlGetUserByID = "select " + sqlColumnListWithID + " from users where id = ?"
+const sqlGetUsersByIDs = "select " + sqlColumnListWithID + " from users where id in "
 const sqlGetUserByEmail = "select " + sqlColumnListWithID + " from users where email = ?"
 const sqlGetUserByUserName = "select " + sqlColumnListWithID + " from users where user_name = ?"
-const sqlInsertUser = "insert into users(" + sqlColumnListNoID + ") values (?,?,?,?,?,?)"
-const sqlUpdateUser = "update users set first_name = ?, last_name = ? where id = ?"
+const sqlInsertUser = "insert into users(" + sqlColumnListNoID + ", updated_at) values (?,?,?,?,?,?,?)"
+const sqlUpdateUser = "update users set first_name = ?, last_name = ?, bio = ?, pronouns = ?, time_zone = ?, " +
+	"status_text = ?, status_emoji = ?, status_expires = ?, updated_at = ? where id = ?"
 const sqlDeleteUser = "delete from users where id = ?"
+const sqlInsertDeletedUser = "insert into deleted_users(user_id, deleted_at) values (?,?)"
+const sqlGetUsersChangedSince = "select id, user_name, first_name, last_name from users where updated_at >= ?"
+const sqlGetUsersDeletedSince = "select user_id from deleted_users where deleted_at >= ?"
+const sqlListUsers = "select " + sqlColumnListWithID + " from users"
+const sqlSetSuspended = "update users set suspended = ? where id = ?"
+const sqlSetResetRequired = "update users set reset_required = ? where id = ?"
+const sqlUpdatePhotoURL = "update users set photo_url = ? where id = ?"
+const sqlGetUserName = "select user_name from users where id = ?"
+const sqlInsertPastUserName = "insert into past_user_names(user_id, user_name, changed_at) values (?,?,?)"
+const sqlUpdateUserName = "update users set user_name = ?, updated_at = ? where id = ?"
+const sqlGetPastUserNames = "select user_id, user_name, changed_at from past_user_names where user_id = ? order by changed_at desc"
+const sqlGetUserNameHolder = "select user_id from past_user_names where user_name = ? and changed_at >= ? order by changed_at desc limit 1"
+const sqlUpdatePassword = "update users set pass_hash = ?, reset_required = false where id = ?"
 
-//GetByID returns the User with the given ID
-func (ms *SQLStore) GetByID(id int64) (*User, error) {
-	rows, err := ms.db.Query(sqlGetUserByID, id)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	user := User{}
+//scanUsers scans every row into a User, using the
+//columns in sqlColumnListWithID
+func scanUsers(rows *sql.Rows) ([]*User, error) {
+	users := []*User{}
+	now := time.Now()
 	for rows.Next() {
-		if err := rows.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL); err != nil {
+		user := &User{}
+		status := &Status{}
+		var statusExpires int64
+		if err := rows.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL,
+			&user.Admin, &user.Suspended, &user.PasswordResetRequired,
+			&user.Bio, &user.Pronouns, &user.TimeZone, &status.Text, &status.Emoji, &statusExpires); err != nil {
 			return nil, fmt.Errorf("error scanning row: %v", err)
 		}
+		if len(status.Text) > 0 || len(status.Emoji) > 0 {
+			if statusExpires > 0 {
+				expiresAt := time.Unix(statusExpires, 0).UTC()
+				status.ExpiresAt = &expiresAt
+			}
+			user.Status = status
+			user.Status = user.ActiveStatus(now)
+		}
+		users = append(users, user)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error getting next row: %v", err)
 	}
-	return &user, nil
+	return users, nil
 }
 
-//GetByEmail returns the User with the given email
-func (ms *SQLStore) GetByEmail(email string) (*User, error) {
-	rows, err := ms.db.Query(sqlGetUserByEmail, email)
+//statusColumns returns the values of the status_text, status_emoji
+//and status_expires columns for the given status
+func statusColumns(status *Status) (string, string, int64) {
+	if status == nil {
+		return "", "", 0
+	}
+	var expires int64
+	if status.ExpiresAt != nil {
+		expires = status.ExpiresAt.Unix()
+	}
+	return status.Text, status.Emoji, expires
+}
+
+//getUser returns the last User matched by the query,
+//or ErrUserNotFound if there isn't one
+func (ms *SQLStore) getUser(ctx context.Context, query string, args ...interface{}) (*User, error) {
+	rows, err := ms.db.QueryContext(ctx, ms.dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	user := User{}
-	for rows.Next() {
-		if err := rows.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL); err != nil {
-			return nil, fmt.Errorf("error scanning row: %v", err)
-		}
+	users, err := scanUsers(rows)
+	if err != nil {
+		return nil, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error getting next row: %v", err)
+	if len(users) == 0 {
+		return nil, ErrUserNotFound
 	}
-	return &user, nil
+	return users[len(users)-1], nil
 }
 
-//GetByUserName returns the User with the given Username
-func (ms *SQLStore) GetByUserName(username string) (*User, error) {
-	rows, err := ms.db.Query(sqlGetUserByUserName, username)
+//GetByID returns the User with the given ID
+func (ms *SQLStore) GetByID(ctx context.Context, id int64) (*User, error) {
+	return ms.getUser(ctx, sqlGetUserByID, id)
+}
+
+//GetByIDFresh returns the User with the given ID. A SQLStore
+//has no cache, so it's the same as GetByID.
+func (ms *SQLStore) GetByIDFresh(ctx context.Context, id int64) (*User, error) {
+	return ms.GetByID(ctx, id)
+}
+
+//GetByIDs returns the Users with the given IDs in the same order
+//as the IDs, skipping any IDs for which there is no user.
+//The users are selected with a single query.
+func (ms *SQLStore) GetByIDs(ctx context.Context, ids []int64) ([]*User, error) {
+	if len(ids) == 0 {
+		return []*User{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := sqlGetUsersByIDs + "(" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")"
+	rows, err := ms.db.QueryContext(ctx, ms.dialect.Rebind(query), args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error getting users: %v", err)
 	}
 	defer rows.Close()
-	user := User{}
-	for rows.Next() {
-		if err := rows.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL); err != nil {
-			return nil, fmt.Errorf("error scanning row: %v", err)
-		}
+	scanned, err := scanUsers(rows)
+	if err != nil {
+		return nil, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error getting next row: %v", err)
+
+	//the database returns the users in any order
+	byID := make(map[int64]*User, len(scanned))
+	for _, user := range scanned {
+		byID[user.ID] = user
 	}
-	return &user, nil
+	found := []*User{}
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			found = append(found, user)
+		}
+	}
+	return found, nil
+}
+
+//GetByEmail returns the User with the given email
+func (ms *SQLStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return ms.getUser(ctx, sqlGetUserByEmail, email)
+}
+
+//GetByUserName returns the User with the given Username
+func (ms *SQLStore) GetByUserName(ctx context.Context, username string) (*User, error) {
+	return ms.getUser(ctx, sqlGetUserByUserName, username)
 }
 
 //Insert inserts the user into the database, and returns
 //the newly-inserted User, complete with the DBMS-assigned ID
-func (ms *SQLStore) Insert(user *User) (*User, error) {
-	result, err := ms.db.Exec(sqlInsertUser, &user.Email, &user.PassHash, &user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL)
+func (ms *SQLStore) Insert(ctx context.Context, user *User) (*User, error) {
+	newID, err := ms.insertUser(ctx, user)
 	if err != nil {
-		return nil, fmt.Errorf("error inserting new row: %v", err)
+		return nil, err
 	}
 
+	user.ID = newID
+	return user, nil
+}
+
+//insertUser inserts the user's row and returns its new ID.
+//PostgreSQL doesn't report the last insert ID, so
+//there the ID is returned by the insert itself.
+func (ms *SQLStore) insertUser(ctx context.Context, user *User) (int64, error) {
+	args := []interface{}{&user.Email, &user.PassHash, &user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL, time.Now().Unix()}
+	if ms.dialect == sqldb.Postgres {
+		var newID int64
+		if err := ms.db.QueryRowContext(ctx, ms.dialect.Rebind(sqlInsertUser+" returning id"), args...).Scan(&newID); err != nil {
+			return 0, ms.insertError(err)
+		}
+		return newID, nil
+	}
+	result, err := ms.db.ExecContext(ctx, sqlInsertUser, args...)
+	if err != nil {
+		return 0, ms.insertError(err)
+	}
 	newID, err := result.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("error getting new ID: %v", err)
+		return 0, fmt.Errorf("error getting new ID: %v", err)
 	}
-
-	user.ID = newID
-	return user, nil
+	return newID, nil
 }
 
 //Update applies UserUpdates to the given user ID
 //and returns the newly-updated user
-func (ms *SQLStore) Update(id int64, updates *Updates) (*User, error) {
-	_, err := ms.db.Exec(sqlUpdateUser, updates.FirstName, updates.LastName, id)
+func (ms *SQLStore) Update(ctx context.Context, id int64, updates *Updates) (*User, error) {
+	user, err := ms.GetByID(ctx, id)
+	if err == ErrUserNotFound {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user to update: %v", err)
+	}
+	if err := user.ApplyUpdates(updates); err != nil {
+		return nil, err
+	}
+	statusText, statusEmoji, statusExpires := statusColumns(user.Status)
+	_, err = ms.db.ExecContext(ctx, ms.dialect.Rebind(sqlUpdateUser), user.FirstName, user.LastName, user.Bio, user.Pronouns, user.TimeZone,
+		statusText, statusEmoji, statusExpires, time.Now().Unix(), id)
 	if err != nil {
 		return nil, fmt.Errorf("error updating row: %v", err)
 	}
-	user, err := ms.GetByID(id)
+	return user, nil
+}
+
+//Delete deletes the user with the given ID, recording when
+//it was deleted so ForEachChangeSince can report the deletion
+func (ms *SQLStore) Delete(ctx context.Context, id int64) error {
+	tx, err := ms.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	result, err := tx.ExecContext(ctx, ms.dialect.Rebind(sqlDeleteUser), id)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error deleting row: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		tx.Rollback()
+		return ErrUserNotFound
+	}
+	if _, err := tx.ExecContext(ctx, ms.dialect.Rebind(sqlInsertDeletedUser), id, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error recording deletion: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+//List returns the users matching the given params, ordered by ID
+func (ms *SQLStore) List(ctx context.Context, params *ListParams) ([]*User, error) {
+	query := sqlListUsers
+	conditions := []string{}
+	args := []interface{}{}
+	if len(params.Query) > 0 {
+		conditions = append(conditions, "(user_name like ? escape '!' or email like ? escape '!')")
+		pattern := escapeLike(params.Query) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if params.Suspended != nil {
+		conditions = append(conditions, "suspended = ?")
+		args = append(args, *params.Suspended)
+	}
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += " order by id limit ? offset ?"
+	args = append(args, params.Limit, params.Offset)
+
+	rows, err := ms.db.QueryContext(ctx, ms.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing users: %v", err)
+	}
+	defer rows.Close()
+	return scanUsers(rows)
+}
+
+//SetSuspended sets whether the user with the given ID
+//is suspended and returns the newly-updated user
+func (ms *SQLStore) SetSuspended(ctx context.Context, id int64, suspended bool) (*User, error) {
+	return ms.execAndGet(ctx, id, sqlSetSuspended, suspended, id)
+}
+
+//SetPasswordResetRequired sets whether the user with the given ID
+//must change their password and returns the newly-updated user
+func (ms *SQLStore) SetPasswordResetRequired(ctx context.Context, id int64, required bool) (*User, error) {
+	return ms.execAndGet(ctx, id, sqlSetResetRequired, required, id)
+}
+
+//UpdatePhotoURL sets the photo URL of the user with
+//the given ID and returns the newly-updated user
+func (ms *SQLStore) UpdatePhotoURL(ctx context.Context, id int64, photoURL string) (*User, error) {
+	return ms.execAndGet(ctx, id, sqlUpdatePhotoURL, photoURL, id)
+}
+
+//UpdateUserName changes the user name of the user with the given ID,
+//records the old user name as a PastUserName, and returns the newly-updated user
+func (ms *SQLStore) UpdateUserName(ctx context.Context, id int64, userName string) (*User, error) {
+	tx, err := ms.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	var oldUserName string
+	if err := tx.QueryRowContext(ctx, ms.dialect.Rebind(sqlGetUserName), id).Scan(&oldUserName); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("error getting current user name: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, ms.dialect.Rebind(sqlInsertPastUserName), id, oldUserName, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("error recording past user name: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, ms.dialect.Rebind(sqlUpdateUserName), userName, time.Now().Unix(), id); err != nil {
+		tx.Rollback()
+		if dupErr := ms.duplicateError(err); dupErr != nil {
+			return nil, dupErr
+		}
+		return nil, fmt.Errorf("error updating row: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	user, err := ms.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("error getting updated user: %v", err)
 	}
 	return user, nil
 }
 
-//Delete deletes the user with the given ID
-func (ms *SQLStore) Delete(id int64) error {
-	_, err := ms.db.Exec(sqlDeleteUser, id)
+//GetPastUserNames returns the user names given up by the
+//user with the given ID, most recently changed first
+func (ms *SQLStore) GetPastUserNames(ctx context.Context, id int64) ([]*PastUserName, error) {
+	rows, err := ms.db.QueryContext(ctx, ms.dialect.Rebind(sqlGetPastUserNames), id)
 	if err != nil {
-		return fmt.Errorf("error deleting row: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	names := []*PastUserName{}
+	for rows.Next() {
+		name := &PastUserName{}
+		var changedAt int64
+		if err := rows.Scan(&name.UserID, &name.UserName, &changedAt); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		name.ChangedAt = time.Unix(changedAt, 0).UTC()
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting next row: %v", err)
+	}
+	return names, nil
+}
+
+//GetUserNameHolder returns the ID of the user who most recently gave up
+//the given user name at or after `since`, or 0 if nobody did
+func (ms *SQLStore) GetUserNameHolder(ctx context.Context, userName string, since time.Time) (int64, error) {
+	var id int64
+	err := ms.db.QueryRowContext(ctx, ms.dialect.Rebind(sqlGetUserNameHolder), userName, since.Unix()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting user name holder: %v", err)
+	}
+	return id, nil
+}
+
+//UpdatePassword replaces the password hash of the user with the given ID,
+//clears any required password reset, and returns the newly-updated user
+func (ms *SQLStore) UpdatePassword(ctx context.Context, id int64, passHash []byte) (*User, error) {
+	return ms.execAndGet(ctx, id, sqlUpdatePassword, passHash, id)
+}
+
+//execAndGet executes the update statement and returns the updated
+//user with the given ID, or ErrUserNotFound if no row was changed
+//because the user doesn't exist
+func (ms *SQLStore) execAndGet(ctx context.Context, id int64, stmt string, args ...interface{}) (*User, error) {
+	if _, err := ms.db.ExecContext(ctx, ms.dialect.Rebind(stmt), args...); err != nil {
+		return nil, fmt.Errorf("error updating row: %v", err)
+	}
+	user, err := ms.GetByID(ctx, id)
+	if err == ErrUserNotFound {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting updated user: %v", err)
+	}
+	return user, nil
+}
+
+//insertError returns the error to report for a failed insert
+func (ms *SQLStore) insertError(err error) error {
+	if dupErr := ms.duplicateError(err); dupErr != nil {
+		return dupErr
+	}
+	return fmt.Errorf("error inserting new row: %v", err)
+}
+
+//mysqlDuplicateEntry is the MySQL error number for a unique key violation
+const mysqlDuplicateEntry = 1062
+
+//postgresUniqueViolation is the PostgreSQL error code for a unique key violation
+const postgresUniqueViolation = "23505"
+
+//duplicateError returns ErrDuplicateEmail or ErrDuplicateUserName if the
+//error is a unique key violation on the email or user name, or nil otherwise
+func (ms *SQLStore) duplicateError(err error) error {
+	//key is the name of the violated key or column
+	var key string
+	switch ms.dialect {
+	case sqldb.Postgres:
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) || pqErr.Code != postgresUniqueViolation {
+			return nil
+		}
+		key = pqErr.Constraint
+	case sqldb.SQLite:
+		//go-sqlite3 only reports the violated column in the message,
+		//for example "UNIQUE constraint failed: users.user_name"
+		const prefix = "UNIQUE constraint failed: "
+		msg := err.Error()
+		i := strings.Index(msg, prefix)
+		if i < 0 {
+			return nil
+		}
+		key = msg[i+len(prefix):]
+	default:
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlDuplicateEntry {
+			return nil
+		}
+		//the message ends with the violated key, which older versions of
+		//MySQL name "user_name" and newer ones name "users.user_name"
+		key = mysqlErr.Message[strings.LastIndex(mysqlErr.Message, " for key ")+1:]
+	}
+	switch {
+	case strings.Contains(key, "user_name"):
+		return ErrDuplicateUserName
+	case strings.Contains(key, "email"):
+		return ErrDuplicateEmail
 	}
 	return nil
 }
 
-//GetAllUsers gets all the rows in the user table and inserts them
-//into the trie
-func (ms *SQLStore) GetAllUsers() (*indexes.Trie, error) {
-	trie := indexes.NewTrie()
+//escapeLike escapes the wildcard characters in a string used as a
+//pattern in a like clause. SQLite has no default escape character,
+//so the clause must name '!' as its escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+//ForEachUser calls fn with every user in the store, such as to build
+//the search index. Only the ID and the user, first and last names
+//are read, since those are all the index needs.
+func (ms *SQLStore) ForEachUser(fn func(user *User)) error {
 	rows, err := ms.db.Query(sqlGetAllUsers)
 	if err != nil {
-		fmt.Print("no users stored in db")
-		return trie, nil
+		return fmt.Errorf("error getting users: %v", err)
 	}
 	defer rows.Close()
 	for rows.Next() {
-		user := User{}
+		user := &User{}
 		if err := rows.Scan(&user.ID, &user.UserName, &user.FirstName, &user.LastName); err != nil {
-			return nil, fmt.Errorf("error scanning row: %v", err)
-		}
-		firstname := strings.ToLower(user.FirstName)
-		fsplit := strings.Split(firstname, " ")
-		for i := range fsplit {
-			fsplit[i] = strings.TrimSpace(fsplit[i])
-			trie.Add(fsplit[i], user.ID)
+			return fmt.Errorf("error scanning row: %v", err)
 		}
+		fn(user)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error getting next row: %v", err)
+	}
+	return nil
+}
 
-		lastname := strings.ToLower(user.LastName)
-		lsplit := strings.Split(lastname, " ")
-		for i := range lsplit {
-			lsplit[i] = strings.TrimSpace(lsplit[i])
-			trie.Add(lsplit[i], user.ID)
+//ForEachChangeSince calls updated with every user whose names changed or
+//who signed up at or after `since`, and deleted with the ID of every user
+//deleted at or after it, such as to catch the search index up from a
+//snapshot. Like ForEachUser, only the fields the index needs are read.
+func (ms *SQLStore) ForEachChangeSince(since time.Time, updated func(user *User), deleted func(id int64)) error {
+	rows, err := ms.db.Query(ms.dialect.Rebind(sqlGetUsersDeletedSince), since.Unix())
+	if err != nil {
+		return fmt.Errorf("error getting deleted users: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("error scanning row: %v", err)
 		}
+		deleted(id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error getting next row: %v", err)
+	}
 
-		trie.Add(strings.ToLower(user.UserName), user.ID)
+	rows, err = ms.db.Query(ms.dialect.Rebind(sqlGetUsersChangedSince), since.Unix())
+	if err != nil {
+		return fmt.Errorf("error getting changed users: %v", err)
 	}
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("error getting next row: %v", err)
+	defer rows.Close()
+	for rows.Next() {
+		user := &User{}
+		if err := rows.Scan(&user.ID, &user.UserName, &user.FirstName, &user.LastName); err != nil {
+			return fmt.Errorf("error scanning row: %v", err)
+		}
+		updated(user)
 	}
-	return trie, nil
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error getting next row: %v", err)
+	}
+	return nil
 }
@@ -1,14 +1,50 @@
 package users
 
 import (
+	"context"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 )
 
+//userColumns are the columns selected by sqlColumnListWithID
+var userColumns = strings.Split(sqlColumnListWithID, ", ")
+
+//userRow returns a mock row for userColumns with the given
+//values, and zero values for all of the remaining columns
+func userRow(id driver.Value, email, passHash, userName, firstName, lastName, photoURL string) []driver.Value {
+	row := []driver.Value{id, email, passHash, userName, firstName, lastName, photoURL}
+	for _, col := range userColumns[len(row):] {
+		switch col {
+		case "is_admin", "suspended", "reset_required":
+			row = append(row, false)
+		case "status_expires":
+			row = append(row, 0)
+		default:
+			row = append(row, "")
+		}
+	}
+	return row
+}
+
+//setColumn sets the value of the named column in a row from userRow
+func setColumn(row []driver.Value, column string, value driver.Value) {
+	for i, col := range userColumns {
+		if col == column {
+			row[i] = value
+		}
+	}
+}
+
 func TestUserInsert(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -50,10 +86,11 @@ func TestUserInsert(t *testing.T) {
 			user.FirstName,
 			user.LastName,
 			user.PhotoURL,
+			sqlmock.AnyArg(),
 		).
 		WillReturnResult(sqlmock.NewResult(newID, 1))
 
-	insertedUser, err := sqlStore.Insert(user)
+	insertedUser, err := sqlStore.Insert(context.Background(), user)
 	if err != nil {
 		t.Fatalf("unexpected error during successful insert: %v", err)
 	}
@@ -105,12 +142,100 @@ func TestUserInsertFail(t *testing.T) {
 		).
 		WillReturnError(fmt.Errorf("some error"))
 
-	_, err = sqlStore.Insert(user)
+	_, err = sqlStore.Insert(context.Background(), user)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
 }
 
+func TestUserInsertDuplicate(t *testing.T) {
+	cases := []struct {
+		name        string
+		message     string
+		expectedErr error
+	}{
+		{"Duplicate Email", "Duplicate entry 'test@gmail.com' for key 'users.email'", ErrDuplicateEmail},
+		{"Duplicate User Name", "Duplicate entry 'test' for key 'user_name'", ErrDuplicateUserName},
+		{"Email Naming A Key", "Duplicate entry 'user_name@gmail.com' for key 'email'", ErrDuplicateEmail},
+	}
+
+	for _, c := range cases {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating sqlmock: %v", err)
+		}
+		sqlStore := NewSQLStore(db)
+
+		mock.ExpectExec(regexp.QuoteMeta(sqlInsertUser)).
+			WillReturnError(&mysql.MySQLError{Number: mysqlDuplicateEntry, Message: c.message})
+
+		user := &User{Email: "test@gmail.com", UserName: "test"}
+		if _, err := sqlStore.Insert(context.Background(), user); err != c.expectedErr {
+			t.Errorf("case %s: incorrect error: expected %v but got %v", c.name, c.expectedErr, err)
+		}
+		db.Close()
+	}
+}
+
+func TestDuplicateErrorDialects(t *testing.T) {
+	cases := []struct {
+		name        string
+		dialect     sqldb.Dialect
+		err         error
+		expectedErr error
+	}{
+		{"Postgres Email", sqldb.Postgres, &pq.Error{Code: postgresUniqueViolation, Constraint: "users_email_key"}, ErrDuplicateEmail},
+		{"Postgres User Name", sqldb.Postgres, &pq.Error{Code: postgresUniqueViolation, Constraint: "users_user_name_key"}, ErrDuplicateUserName},
+		{"Postgres Other Error", sqldb.Postgres, &pq.Error{Code: "23502", Column: "email"}, nil},
+		{"SQLite Email", sqldb.SQLite, fmt.Errorf("UNIQUE constraint failed: users.email"), ErrDuplicateEmail},
+		{"SQLite User Name", sqldb.SQLite, fmt.Errorf("UNIQUE constraint failed: users.user_name"), ErrDuplicateUserName},
+		{"SQLite Other Error", sqldb.SQLite, fmt.Errorf("NOT NULL constraint failed: users.email"), nil},
+	}
+
+	for _, c := range cases {
+		store := NewDialectStore(nil, c.dialect)
+		if err := store.duplicateError(c.err); err != c.expectedErr {
+			t.Errorf("case %s: incorrect error: expected %v but got %v", c.name, c.expectedErr, err)
+		}
+	}
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+
+	//the database returns the users out of order and without user 5
+	userMockRows := sqlmock.NewRows(userColumns).
+		AddRow(userRow(1, "one@gmail.com", "test", "one", "first", "last", "testtest")...).
+		AddRow(userRow(3, "three@gmail.com", "test", "three", "first", "last", "testtest")...)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUsersByIDs+"(?,?,?)")).
+		WithArgs(3, 5, 1).
+		WillReturnRows(userMockRows)
+
+	users, err := sqlStore.GetByIDs(context.Background(), []int64{3, 5, 1})
+	if err != nil {
+		t.Fatalf("unexpected error during successful select: %v", err)
+	}
+	if len(users) != 2 || users[0].ID != 3 || users[1].ID != 1 {
+		t.Fatalf("incorrect users: expected users 3 and 1 but got %v", users)
+	}
+
+	//no IDs shouldn't query the database
+	if users, err := sqlStore.GetByIDs(context.Background(), nil); err != nil || len(users) != 0 {
+		t.Errorf("expected no users but got %v (error %v)", users, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
 func TestGetUserByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -121,8 +246,8 @@ func TestGetUserByID(t *testing.T) {
 
 	sqlStore := NewSQLStore(db)
 
-	userMockRows := sqlmock.NewRows([]string{"id", "email", "pass_hash", "user_name", "first_name", "last_name", "photo_url"}).
-		AddRow(1, "test@gmail.com", "test", "username", "first", "last", "testtest")
+	userMockRows := sqlmock.NewRows(userColumns).
+		AddRow(userRow(1, "test@gmail.com", "test", "username", "first", "last", "testtest")...)
 
 	expectedSQL := regexp.QuoteMeta(sqlGetUserByID)
 
@@ -130,7 +255,7 @@ func TestGetUserByID(t *testing.T) {
 		WithArgs(1).
 		WillReturnRows(userMockRows)
 
-	user, err := sqlStore.GetByID(1)
+	user, err := sqlStore.GetByID(context.Background(), 1)
 	expectedUser := User{ID: 1, Email: "test@gmail.com", PassHash: user.PassHash, UserName: "username", FirstName: "first", LastName: "last", PhotoURL: "testtest"}
 	if err != nil {
 		t.Fatalf("unexpected error during successful select: %v", err)
@@ -142,6 +267,25 @@ func TestGetUserByID(t *testing.T) {
 	}
 }
 
+func TestGetUserByIDNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserByID)).
+		WithArgs(1000).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	if _, err := sqlStore.GetByID(context.Background(), 1000); err != ErrUserNotFound {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrUserNotFound, err)
+	}
+}
+
 func TestGetUserByIDScanFail(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -161,7 +305,7 @@ func TestGetUserByIDScanFail(t *testing.T) {
 		WithArgs(1).
 		WillReturnRows(userMockRows)
 
-	_, err = sqlStore.GetByID(1)
+	_, err = sqlStore.GetByID(context.Background(), 1)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -178,15 +322,15 @@ func TestGetUserByEmail(t *testing.T) {
 	sqlStore := NewSQLStore(db)
 	id := int64(1)
 
-	userMockRows := sqlmock.NewRows([]string{"id", "email", "pass_hash", "user_name", "first_name", "last_name", "photo_url"}).
-		AddRow(id, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")
+	userMockRows := sqlmock.NewRows(userColumns).
+		AddRow(userRow(id, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")...)
 
 	expectedSQL := regexp.QuoteMeta(sqlGetUserByEmail)
 	mock.ExpectQuery(expectedSQL).
 		WithArgs("rioaishii@gmail.com").
 		WillReturnRows(userMockRows)
 
-	user, err := sqlStore.GetByEmail("rioaishii@gmail.com")
+	user, err := sqlStore.GetByEmail(context.Background(), "rioaishii@gmail.com")
 	expectedUser := User{ID: 1, Email: "rioaishii@gmail.com", PassHash: user.PassHash, UserName: "rioishii", FirstName: "rio", LastName: "ishii", PhotoURL: "testtest"}
 	if err != nil {
 		t.Fatalf("unexpected error during successful select: %v", err)
@@ -213,7 +357,7 @@ func TestGetUserByEmailFail(t *testing.T) {
 		WithArgs("test@gmail.com").
 		WillReturnError(fmt.Errorf("email not found"))
 
-	_, err = sqlStore.GetByEmail("test@gmail.com")
+	_, err = sqlStore.GetByEmail(context.Background(), "test@gmail.com")
 	if err == nil {
 		t.Fatalf("unexpected error, found invalid user %v", err)
 	}
@@ -238,7 +382,7 @@ func TestGetUserByEmailScanFail(t *testing.T) {
 		WithArgs("test@gmail.com").
 		WillReturnRows(userMockRows)
 
-	_, err = sqlStore.GetByEmail("test@gmail.com")
+	_, err = sqlStore.GetByEmail(context.Background(), "test@gmail.com")
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -255,15 +399,15 @@ func TestGetUserByUserName(t *testing.T) {
 	sqlStore := NewSQLStore(db)
 	id := int64(1)
 
-	userMockRows := sqlmock.NewRows([]string{"id", "email", "pass_hash", "user_name", "first_name", "last_name", "photo_url"}).
-		AddRow(id, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")
+	userMockRows := sqlmock.NewRows(userColumns).
+		AddRow(userRow(id, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")...)
 
 	expectedSQL := regexp.QuoteMeta(sqlGetUserByUserName)
 	mock.ExpectQuery(expectedSQL).
 		WithArgs("rioishii").
 		WillReturnRows(userMockRows)
 
-	user, err := sqlStore.GetByUserName("rioishii")
+	user, err := sqlStore.GetByUserName(context.Background(), "rioishii")
 	if err != nil {
 		t.Fatalf("unexpected error during successful select: %v", err)
 	}
@@ -290,7 +434,7 @@ func TestGetUserByUserNameFail(t *testing.T) {
 		WithArgs("test").
 		WillReturnError(fmt.Errorf("username not found"))
 
-	_, err = sqlStore.GetByUserName("test")
+	_, err = sqlStore.GetByUserName(context.Background(), "test")
 	if err == nil {
 		t.Fatalf("unexpected error, found invalid user %v", err)
 	}
@@ -315,7 +459,7 @@ func TestGetUserByUserNameScanFail(t *testing.T) {
 		WithArgs("test").
 		WillReturnRows(userMockRows)
 
-	_, err = sqlStore.GetByUserName("test")
+	_, err = sqlStore.GetByUserName(context.Background(), "test")
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -332,28 +476,37 @@ func TestUpdateUser(t *testing.T) {
 	sqlStore := NewSQLStore(db)
 
 	updateID := int64(1)
-	updateUser := Updates{FirstName: "John", LastName: "Doe"}
+	bio := "hello there"
+	updateUser := Updates{FirstName: "John", LastName: "Doe", Bio: &bio, Status: &Status{Text: "on vacation", Emoji: ":palm_tree:"}}
 
-	expectedRows := sqlmock.NewRows([]string{"id", "email", "pass_hash", "user_name", "first_name", "last_name", "photo_url"}).
-		AddRow(1, "rioaishii@gmail.com", "password", "rioishii", "John", "Doe", "testtest")
+	expectedRows := sqlmock.NewRows(userColumns).
+		AddRow(userRow(1, "rioaishii@gmail.com", "password", "rioishii", "Rio", "Ishii", "testtest")...)
 
 	expectedSQLUpdate := regexp.QuoteMeta(sqlUpdateUser)
 	expectedSQLGet := regexp.QuoteMeta(sqlGetUserByID)
 
+	mock.ExpectQuery(expectedSQLGet).
+		WithArgs(updateID).
+		WillReturnRows(expectedRows)
 	mock.ExpectExec(expectedSQLUpdate).
 		WithArgs(
 			updateUser.FirstName,
 			updateUser.LastName,
+			bio,
+			"",
+			"",
+			"on vacation",
+			":palm_tree:",
+			0,
+			sqlmock.AnyArg(),
 			updateID,
 		).
 		WillReturnResult(sqlmock.NewResult(0, updateID))
-	mock.ExpectQuery(expectedSQLGet).
-		WithArgs(updateID).
-		WillReturnRows(expectedRows)
 
-	user, err := sqlStore.Update(updateID, &updateUser)
+	user, err := sqlStore.Update(context.Background(), updateID, &updateUser)
 
-	expectedUser := User{ID: 1, Email: "rioaishii@gmail.com", PassHash: user.PassHash, UserName: "rioishii", FirstName: "John", LastName: "Doe", PhotoURL: "testtest"}
+	expectedUser := User{ID: 1, Email: "rioaishii@gmail.com", PassHash: user.PassHash, UserName: "rioishii", FirstName: "John", LastName: "Doe", PhotoURL: "testtest",
+		Bio: bio, Status: &Status{Text: "on vacation", Emoji: ":palm_tree:"}}
 	if err != nil {
 		t.Fatalf("unexpected error during successful update: %v", err)
 	} else if !reflect.DeepEqual(user, &expectedUser) {
@@ -370,20 +523,16 @@ func TestUpdateUserFailRow(t *testing.T) {
 	sqlStore := NewSQLStore(db)
 	updateID := int64(1)
 	updateUser := Updates{FirstName: "John", LastName: "Doe"}
-	userMockRows := sqlmock.NewRows([]string{"id", "email", "pass_hash", "user_name", "first_name", "last_name", "photo_url"}).
-		AddRow(updateID, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")
+	userMockRows := sqlmock.NewRows(userColumns).
+		AddRow(userRow(updateID, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")...)
 	expectedSQLUpdate := regexp.QuoteMeta(sqlUpdateUser)
 	expectedSQLGet := regexp.QuoteMeta(sqlGetUserByID)
-	mock.ExpectExec(expectedSQLUpdate).
-		WithArgs(
-			updateUser.FirstName,
-			updateID,
-		).
-		WillReturnError(fmt.Errorf("some error"))
 	mock.ExpectQuery(expectedSQLGet).
 		WithArgs(updateID).
 		WillReturnRows(userMockRows)
-	_, err = sqlStore.Update(updateID, &updateUser)
+	mock.ExpectExec(expectedSQLUpdate).
+		WillReturnError(fmt.Errorf("some error"))
+	_, err = sqlStore.Update(context.Background(), updateID, &updateUser)
 	if err == nil {
 		t.Fatalf("Expected error")
 	}
@@ -403,18 +552,23 @@ func TestDeleteUser(t *testing.T) {
 	expectedSQLDelete := regexp.QuoteMeta(sqlDeleteUser)
 	expectSQLGet := regexp.QuoteMeta(sqlGetUserByID)
 
+	mock.ExpectBegin()
 	mock.ExpectExec(expectedSQLDelete).
 		WithArgs(deleteID).
 		WillReturnResult(sqlmock.NewResult(0, deleteID))
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertDeletedUser)).
+		WithArgs(deleteID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 	mock.ExpectQuery(expectSQLGet).
 		WithArgs(deleteID).
 		WillReturnError(fmt.Errorf("user unfound"))
 
-	err = sqlStore.Delete(deleteID)
+	err = sqlStore.Delete(context.Background(), deleteID)
 	if err != nil {
 		t.Fatalf("unexpected error during successful update: %v", err)
 	}
-	_, err = sqlStore.GetByID(deleteID)
+	_, err = sqlStore.GetByID(context.Background(), deleteID)
 	if err == nil {
 		t.Fatalf("unexpected error returned deleted user %v", err)
 	}
@@ -434,15 +588,321 @@ func TestDeleteUserFail(t *testing.T) {
 	expectedSQLDelete := regexp.QuoteMeta(sqlDeleteUser)
 	expectSQLGet := regexp.QuoteMeta(sqlGetUserByID)
 
+	mock.ExpectBegin()
 	mock.ExpectExec(expectedSQLDelete).
 		WithArgs(deleteID).
 		WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
 	mock.ExpectQuery(expectSQLGet).
 		WithArgs(deleteID).
 		WillReturnError(fmt.Errorf("user unfound"))
 
-	err = sqlStore.Delete(deleteID)
+	err = sqlStore.Delete(context.Background(), deleteID)
 	if err == nil {
 		t.Fatalf("Expected error")
 	}
 }
+
+func TestDeleteUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(sqlDeleteUser)).
+		WithArgs(5000).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := sqlStore.Delete(context.Background(), 5000); err != ErrUserNotFound {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrUserNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	suspended := true
+
+	row := userRow(3, "rioaishii@gmail.com", "password", "rio_ishii", "rio", "ishii", "testtest")
+	setColumn(row, "suspended", true)
+	userMockRows := sqlmock.NewRows(userColumns).AddRow(row...)
+
+	expectedSQL := regexp.QuoteMeta(sqlListUsers + " where (user_name like ? escape '!' or email like ? escape '!') and suspended = ? order by id limit ? offset ?")
+	mock.ExpectQuery(expectedSQL).
+		WithArgs("rio!_%", "rio!_%", true, 10, 20).
+		WillReturnRows(userMockRows)
+
+	users, err := sqlStore.List(context.Background(), &ListParams{Query: "rio_", Suspended: &suspended, Offset: 20, Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error during successful list: %v", err)
+	}
+	expectedUser := &User{ID: 3, Email: "rioaishii@gmail.com", PassHash: []byte("password"), UserName: "rio_ishii", FirstName: "rio", LastName: "ishii", PhotoURL: "testtest", Suspended: true}
+	if len(users) != 1 || !reflect.DeepEqual(users[0], expectedUser) {
+		t.Fatalf("incorrect users: expected [%v] but got %v", expectedUser, users)
+	}
+}
+
+func TestSetSuspended(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	id := int64(1)
+
+	row := userRow(id, "rioaishii@gmail.com", "password", "rioishii", "rio", "ishii", "testtest")
+	setColumn(row, "suspended", true)
+	userMockRows := sqlmock.NewRows(userColumns).AddRow(row...)
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlSetSuspended)).
+		WithArgs(true, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserByID)).
+		WithArgs(id).
+		WillReturnRows(userMockRows)
+
+	user, err := sqlStore.SetSuspended(context.Background(), id, true)
+	if err != nil {
+		t.Fatalf("unexpected error during successful suspend: %v", err)
+	}
+	if !user.Suspended {
+		t.Fatal("expected user to be suspended")
+	}
+}
+
+func TestSetSuspendedNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	id := int64(1000)
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlSetSuspended)).
+		WithArgs(true, id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserByID)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	if _, err := sqlStore.SetSuspended(context.Background(), id, true); err != ErrUserNotFound {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrUserNotFound, err)
+	}
+}
+
+func TestGetUserExpiredStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+
+	active := userRow(1, "test@gmail.com", "test", "active", "first", "last", "testtest")
+	setColumn(active, "status_text", "in a meeting")
+	setColumn(active, "status_expires", time.Now().Add(time.Hour).Unix())
+	expired := userRow(2, "test2@gmail.com", "test", "expired", "first", "last", "testtest")
+	setColumn(expired, "status_text", "in a meeting")
+	setColumn(expired, "status_expires", time.Now().Add(-time.Hour).Unix())
+
+	suspended := false
+	mock.ExpectQuery(regexp.QuoteMeta(sqlListUsers)).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(active...).AddRow(expired...))
+
+	users, err := sqlStore.List(context.Background(), &ListParams{Suspended: &suspended, Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error during successful list: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("incorrect number of users: expected 2 but got %d", len(users))
+	}
+	if users[0].Status == nil || users[0].Status.Text != "in a meeting" || users[0].Status.ExpiresAt == nil {
+		t.Errorf("expected active status but got %v", users[0].Status)
+	}
+	if users[1].Status != nil {
+		t.Errorf("expected expired status to be cleared but got %v", users[1].Status)
+	}
+}
+
+func TestUpdateUserName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	id := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserName)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"user_name"}).AddRow("oldname"))
+	mock.ExpectExec(regexp.QuoteMeta(sqlInsertPastUserName)).
+		WithArgs(id, "oldname", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(sqlUpdateUserName)).
+		WithArgs("newname", sqlmock.AnyArg(), id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserByID)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow(id, "rioaishii@gmail.com", "password", "newname", "rio", "ishii", "testtest")...))
+
+	user, err := sqlStore.UpdateUserName(context.Background(), id, "newname")
+	if err != nil {
+		t.Fatalf("unexpected error during successful user name update: %v", err)
+	}
+	if user.UserName != "newname" {
+		t.Errorf("incorrect user name: expected newname but got %s", user.UserName)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUserNameNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	id := int64(1000)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserName)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"user_name"}))
+	mock.ExpectRollback()
+
+	if _, err := sqlStore.UpdateUserName(context.Background(), id, "newname"); err != ErrUserNotFound {
+		t.Fatalf("incorrect error: expected %v but got %v", ErrUserNotFound, err)
+	}
+}
+
+func TestGetUserNameHolder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	since := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserNameHolder)).
+		WithArgs("oldname", since.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(4))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUserNameHolder)).
+		WithArgs("freename", since.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	if id, err := sqlStore.GetUserNameHolder(context.Background(), "oldname", since); err != nil || id != 4 {
+		t.Errorf("incorrect holder: expected 4 but got %d (error %v)", id, err)
+	}
+	if id, err := sqlStore.GetUserNameHolder(context.Background(), "freename", since); err != nil || id != 0 {
+		t.Errorf("incorrect holder: expected 0 but got %d (error %v)", id, err)
+	}
+}
+
+func TestForEachUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetAllUsers)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_name", "first_name", "last_name"}).
+			AddRow(1, "one", "First", "Last").
+			AddRow(2, "two", "Second", "Last"))
+
+	found := []*User{}
+	if err := sqlStore.ForEachUser(func(user *User) { found = append(found, user) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != 1 || found[1].UserName != "two" || found[1].FirstName != "Second" {
+		t.Errorf("incorrect users: %+v", found)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetAllUsers)).WillReturnError(fmt.Errorf("some error"))
+	if err := sqlStore.ForEachUser(func(user *User) {}); err == nil {
+		t.Errorf("expected error when the query fails")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestForEachChangeSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sqlmock: %v", err)
+	}
+
+	defer db.Close()
+
+	sqlStore := NewSQLStore(db)
+	since := time.Unix(1500000000, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUsersDeletedSince)).
+		WithArgs(since.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUsersChangedSince)).
+		WithArgs(since.Unix()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_name", "first_name", "last_name"}).
+			AddRow(1, "one", "First", "Last"))
+
+	updated := []*User{}
+	deleted := []int64{}
+	err = sqlStore.ForEachChangeSince(since,
+		func(user *User) { updated = append(updated, user) },
+		func(id int64) { deleted = append(deleted, id) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated) != 1 || updated[0].ID != 1 || updated[0].UserName != "one" {
+		t.Errorf("incorrect updated users: %+v", updated)
+	}
+	if !reflect.DeepEqual(deleted, []int64{3}) {
+		t.Errorf("incorrect deleted users: %v", deleted)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetUsersDeletedSince)).WillReturnError(fmt.Errorf("some error"))
+	if err := sqlStore.ForEachChangeSince(since, func(user *User) {}, func(id int64) {}); err == nil {
+		t.Errorf("expected error when the query fails")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
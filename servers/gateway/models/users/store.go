@@ -1,31 +1,105 @@
 package users
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
 //ErrUserNotFound is returned when the user can't be found
 var ErrUserNotFound = errors.New("user not found")
 
-//Store represents a store for Users
+//ErrDuplicateEmail is returned when another user already has the email
+var ErrDuplicateEmail = errors.New("email is already in use")
+
+//ErrDuplicateUserName is returned when another user already has the user name
+var ErrDuplicateUserName = errors.New("user name is already taken")
+
+//ListParams filters and paginates the users returned from Store.List
+type ListParams struct {
+	//Query, if non-empty, matches users whose user name
+	//or email begins with the given string
+	Query string
+	//Suspended, if non-nil, only matches users whose
+	//suspended status equals the given value
+	Suspended *bool
+	//Offset is the number of matching users to skip
+	Offset int
+	//Limit is the maximum number of users to return
+	Limit int
+}
+
+//PastUserName records a user name that a user gave up
+type PastUserName struct {
+	UserID    int64
+	UserName  string
+	ChangedAt time.Time
+}
+
+//Store represents a store for Users. Every method takes a context that
+//cancels the underlying query, and methods that look up a single user
+//return ErrUserNotFound if there is no such user.
 type Store interface {
 	//GetByID returns the User with the given ID
-	GetByID(id int64) (*User, error)
+	GetByID(ctx context.Context, id int64) (*User, error)
+
+	//GetByIDFresh returns the User with the given ID as it is stored
+	//now, never from a cache, for checks of what the user may do
+	GetByIDFresh(ctx context.Context, id int64) (*User, error)
+
+	//GetByIDs returns the Users with the given IDs in the same order
+	//as the IDs, skipping any IDs for which there is no user
+	GetByIDs(ctx context.Context, ids []int64) ([]*User, error)
 
 	//GetByEmail returns the User with the given email
-	GetByEmail(email string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
 
 	//GetByUserName returns the User with the given Username
-	GetByUserName(username string) (*User, error)
+	GetByUserName(ctx context.Context, username string) (*User, error)
 
 	//Insert inserts the user into the database, and returns
-	//the newly-inserted User, complete with the DBMS-assigned ID
-	Insert(user *User) (*User, error)
+	//the newly-inserted User, complete with the DBMS-assigned ID.
+	//It returns ErrDuplicateEmail or ErrDuplicateUserName if
+	//another user already has the email or user name.
+	Insert(ctx context.Context, user *User) (*User, error)
 
 	//Update applies UserUpdates to the given user ID
 	//and returns the newly-updated user
-	Update(id int64, updates *Updates) (*User, error)
+	Update(ctx context.Context, id int64, updates *Updates) (*User, error)
+
+	//Delete deletes the user with the given ID, or
+	//returns ErrUserNotFound if there is no such user
+	Delete(ctx context.Context, id int64) error
+
+	//List returns the users matching the given params, ordered by ID
+	List(ctx context.Context, params *ListParams) ([]*User, error)
+
+	//SetSuspended sets whether the user with the given ID
+	//is suspended and returns the newly-updated user
+	SetSuspended(ctx context.Context, id int64, suspended bool) (*User, error)
+
+	//SetPasswordResetRequired sets whether the user with the given ID
+	//must change their password and returns the newly-updated user
+	SetPasswordResetRequired(ctx context.Context, id int64, required bool) (*User, error)
+
+	//UpdatePhotoURL sets the photo URL of the user with
+	//the given ID and returns the newly-updated user
+	UpdatePhotoURL(ctx context.Context, id int64, photoURL string) (*User, error)
+
+	//UpdateUserName changes the user name of the user with the given ID,
+	//records the old user name as a PastUserName, and returns the newly-updated user.
+	//It returns ErrDuplicateUserName if another user already has the user name.
+	UpdateUserName(ctx context.Context, id int64, userName string) (*User, error)
+
+	//GetPastUserNames returns the user names given up by the
+	//user with the given ID, most recently changed first
+	GetPastUserNames(ctx context.Context, id int64) ([]*PastUserName, error)
+
+	//GetUserNameHolder returns the ID of the user who most recently gave up
+	//the given user name at or after `since`, or 0 if nobody did
+	GetUserNameHolder(ctx context.Context, userName string, since time.Time) (int64, error)
 
-	//Delete deletes the user with the given ID
-	Delete(id int64) error
+	//UpdatePassword replaces the password hash of the user with the given ID,
+	//clears any required password reset, and returns the newly-updated user
+	UpdatePassword(ctx context.Context, id int64, passHash []byte) (*User, error)
 }
@@ -0,0 +1,96 @@
+package users_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/migrations"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users/userstest"
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/sqldb"
+)
+
+func TestMemStoreConformance(t *testing.T) {
+	userstest.TestStore(t, func(t *testing.T) users.Store {
+		return users.NewMemStore()
+	})
+}
+
+func TestCachedStoreConformance(t *testing.T) {
+	userstest.TestStore(t, func(t *testing.T) users.Store {
+		return users.NewCachedStore(users.NewMemStore(), time.Minute)
+	})
+}
+
+//TestSQLStoreConformance runs the suite against the MySQL database
+//in the TESTDSN environment variable, and is skipped if it's not set.
+//The database's users are deleted before each test.
+func TestSQLStoreConformance(t *testing.T) {
+	dsn := os.Getenv("TESTDSN")
+	if len(dsn) == 0 {
+		t.Skip("TESTDSN is not set")
+	}
+	testSQLStoreConformance(t, dsn)
+}
+
+//TestPostgresStoreConformance runs the suite against the PostgreSQL
+//database in the TESTPOSTGRESDSN environment variable, and is skipped
+//if it's not set. The database's users are deleted before each test.
+func TestPostgresStoreConformance(t *testing.T) {
+	dsn := os.Getenv("TESTPOSTGRESDSN")
+	if len(dsn) == 0 {
+		t.Skip("TESTPOSTGRESDSN is not set")
+	}
+	testSQLStoreConformance(t, dsn)
+}
+
+//TestSQLiteStoreConformance runs the suite against a new SQLite
+//database, and is skipped if the SQLite driver isn't available
+//because the tests were built without cgo.
+func TestSQLiteStoreConformance(t *testing.T) {
+	dsn := "sqlite:" + filepath.Join(t.TempDir(), "users.db")
+	db, _, err := sqldb.Open(dsn)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	err = db.Ping()
+	db.Close()
+	if err != nil {
+		t.Skipf("SQLite is unavailable: %v", err)
+	}
+	testSQLStoreConformance(t, dsn)
+}
+
+//testSQLStoreConformance migrates the database named by the
+//DSN and runs the suite against it
+func testSQLStoreConformance(t *testing.T, dsn string) {
+	db, dialect, err := sqldb.Open(dsn)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	all, err := migrations.All(dialect)
+	if err != nil {
+		t.Fatalf("error loading migrations: %v", err)
+	}
+	if _, err := migrations.NewMigrator(db, dialect, all).Up(); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	userstest.TestStore(t, func(t *testing.T) users.Store {
+		clearTables(t, db)
+		return users.NewDialectStore(db, dialect)
+	})
+}
+
+//clearTables deletes every user and past user name
+func clearTables(t *testing.T, db *sql.DB) {
+	for _, table := range []string{"users", "past_user_names"} {
+		if _, err := db.Exec("delete from " + table); err != nil {
+			t.Fatalf("error clearing %s: %v", table, err)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/mail"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,18 +16,63 @@ import (
 //See https://id.gravatar.com/site/implement/images/ for details
 const gravatarBasePhotoURL = "https://www.gravatar.com/avatar/"
 
+//maximum lengths, in characters, of the profile fields
+const (
+	maxBioLength         = 300
+	maxPronounsLength    = 32
+	maxStatusTextLength  = 100
+	maxStatusEmojiLength = 32
+)
+
+//maxUserNameLength is the maximum length of a user name
+const maxUserNameLength = 32
+
+//userNameChars are the characters allowed in a user name
+const userNameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-"
+
+//reservedUserNames are names that no user may take, because they
+//collide with paths in the API or could be used to impersonate staff
+var reservedUserNames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"me":            true,
+	"mine":          true,
+	"moderator":     true,
+	"root":          true,
+	"support":       true,
+	"system":        true,
+}
+
 //bcryptCost is the default bcrypt cost to use when hashing passwords
 var bcryptCost = 13
 
 //User represents a user account in the database
 type User struct {
-	ID        int64  `json:"id"`
-	Email     string `json:"-"` //never JSON encoded/decoded
-	PassHash  []byte `json:"-"` //never JSON encoded/decoded
-	UserName  string `json:"userName"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	PhotoURL  string `json:"photoURL"`
+	ID        int64   `json:"id"`
+	Email     string  `json:"-"` //never JSON encoded/decoded
+	PassHash  []byte  `json:"-"` //never JSON encoded/decoded
+	UserName  string  `json:"userName"`
+	FirstName string  `json:"firstName"`
+	LastName  string  `json:"lastName"`
+	PhotoURL  string  `json:"photoURL"`
+	Bio       string  `json:"bio"`
+	Pronouns  string  `json:"pronouns"`
+	TimeZone  string  `json:"timeZone"`
+	Status    *Status `json:"status,omitempty"`
+	//Admin, Suspended and PasswordResetRequired are only changed
+	//and seen by administrators via the admin API
+	Admin                 bool `json:"-"`
+	Suspended             bool `json:"-"`
+	PasswordResetRequired bool `json:"-"`
+}
+
+//Status represents a user's custom status message
+type Status struct {
+	Text  string `json:"text"`
+	Emoji string `json:"emoji"`
+	//ExpiresAt is when the status should be cleared,
+	//or nil if it never expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 //Credentials represents user sign-in credentials
@@ -44,10 +91,30 @@ type NewUser struct {
 	LastName     string `json:"lastName"`
 }
 
-//Updates represents allowed updates to a user profile
+//Updates represents allowed updates to a user profile.
+//Nil fields are left unchanged, and a Status with
+//empty text and emoji clears the user's status.
 type Updates struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	FirstName string  `json:"firstName"`
+	LastName  string  `json:"lastName"`
+	Bio       *string `json:"bio,omitempty"`
+	Pronouns  *string `json:"pronouns,omitempty"`
+	TimeZone  *string `json:"timeZone,omitempty"`
+	Status    *Status `json:"status,omitempty"`
+}
+
+//UserNameChange represents a request to change
+//the authenticated user's user name
+type UserNameChange struct {
+	UserName string `json:"userName"`
+}
+
+//PasswordChange represents a request to change the
+//authenticated user's password
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	NewPasswordConf string `json:"newPasswordConf"`
 }
 
 //Validate validates the new user and returns an error if
@@ -63,12 +130,44 @@ func (nu *NewUser) Validate() error {
 	if nu.Password != nu.PasswordConf {
 		return fmt.Errorf("password does not match")
 	}
-	if len(nu.UserName) == 0 {
+	return ValidateUserName(nu.UserName)
+}
+
+//ValidateUserName returns an error if the user name breaks any of
+//the format rules or is reserved, or nil if its valid
+func ValidateUserName(userName string) error {
+	if len(userName) == 0 {
 		return fmt.Errorf("user name must be non-zero length")
 	}
-	if strings.Contains(nu.UserName, " ") {
+	if strings.Contains(userName, " ") {
 		return fmt.Errorf("user name may not contain spaces")
 	}
+	if len(userName) > maxUserNameLength {
+		return fmt.Errorf("user name must be at most %d characters", maxUserNameLength)
+	}
+	for _, r := range userName {
+		if !strings.ContainsRune(userNameChars, r) {
+			return fmt.Errorf("user name may only contain letters, digits, '.', '_' and '-'")
+		}
+	}
+	if reservedUserNames[strings.ToLower(userName)] {
+		return fmt.Errorf("user name %q is reserved", userName)
+	}
+	return nil
+}
+
+//Validate validates the password change and returns an error if
+//any of the validation rules fail, or nil if its valid
+func (pc *PasswordChange) Validate() error {
+	if len(pc.NewPassword) < 6 {
+		return fmt.Errorf("password must be at least 6 characters")
+	}
+	if pc.NewPassword != pc.NewPasswordConf {
+		return fmt.Errorf("password does not match")
+	}
+	if pc.NewPassword == pc.CurrentPassword {
+		return fmt.Errorf("new password must be different from the current password")
+	}
 	return nil
 }
 
@@ -138,19 +237,77 @@ func (u *User) Authenticate(password string) error {
 //ApplyUpdates applies the updates to the user. An error
 //is returned if the updates are invalid
 func (u *User) ApplyUpdates(updates *Updates) error {
-	if updates.FirstName == "" && updates.LastName == "" {
+	if updates.FirstName == "" && updates.LastName == "" && updates.Bio == nil &&
+		updates.Pronouns == nil && updates.TimeZone == nil && updates.Status == nil {
 		return fmt.Errorf("invalid update fields")
 	}
+	if err := updates.Validate(); err != nil {
+		return err
+	}
 	if len(updates.FirstName) > 0 {
 		u.FirstName = updates.FirstName
 	}
 	if len(updates.LastName) > 0 {
 		u.LastName = updates.LastName
 	}
+	if updates.Bio != nil {
+		u.Bio = strings.TrimSpace(*updates.Bio)
+	}
+	if updates.Pronouns != nil {
+		u.Pronouns = strings.TrimSpace(*updates.Pronouns)
+	}
+	if updates.TimeZone != nil {
+		u.TimeZone = *updates.TimeZone
+	}
+	if updates.Status != nil {
+		if len(updates.Status.Text) == 0 && len(updates.Status.Emoji) == 0 {
+			u.Status = nil
+		} else {
+			status := *updates.Status
+			u.Status = &status
+		}
+	}
 
 	return nil
 }
 
+//Validate validates the profile fields of the updates and returns
+//an error if any of the validation rules fail, or nil if its valid
+func (updates *Updates) Validate() error {
+	if updates.Bio != nil && utf8.RuneCountInString(*updates.Bio) > maxBioLength {
+		return fmt.Errorf("bio must be at most %d characters", maxBioLength)
+	}
+	if updates.Pronouns != nil && utf8.RuneCountInString(*updates.Pronouns) > maxPronounsLength {
+		return fmt.Errorf("pronouns must be at most %d characters", maxPronounsLength)
+	}
+	if updates.TimeZone != nil && len(*updates.TimeZone) > 0 {
+		if _, err := time.LoadLocation(*updates.TimeZone); err != nil || *updates.TimeZone == "Local" {
+			return fmt.Errorf("invalid time zone: %s", *updates.TimeZone)
+		}
+	}
+	if status := updates.Status; status != nil {
+		if utf8.RuneCountInString(status.Text) > maxStatusTextLength {
+			return fmt.Errorf("status text must be at most %d characters", maxStatusTextLength)
+		}
+		if utf8.RuneCountInString(status.Emoji) > maxStatusEmojiLength {
+			return fmt.Errorf("status emoji must be at most %d characters", maxStatusEmojiLength)
+		}
+		if status.ExpiresAt != nil && !status.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("status expiry must be in the future")
+		}
+	}
+	return nil
+}
+
+//ActiveStatus returns the user's status, or nil if
+//they have none or it expired before the given time
+func (u *User) ActiveStatus(now time.Time) *Status {
+	if u.Status == nil || (u.Status.ExpiresAt != nil && !u.Status.ExpiresAt.After(now)) {
+		return nil
+	}
+	return u.Status
+}
+
 func getMD5Hash(text string) string {
 	hasher := md5.New()
 	hasher.Write([]byte(text))
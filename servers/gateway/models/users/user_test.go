@@ -1,7 +1,10 @@
 package users
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 )
 
 //TODO: add tests for the various functions in user.go, as described in the assignment.
@@ -323,7 +326,7 @@ func TestApplyUpdates(t *testing.T) {
 
 	for _, c := range cases {
 		nu := NewUser{c.email, c.password, c.passwordConf, c.username, c.firstname, c.lastname}
-		update := Updates{c.updateFirst, c.updateLast}
+		update := Updates{FirstName: c.updateFirst, LastName: c.updateLast}
 		user, err := nu.ToUser()
 		if err != nil {
 			t.Errorf("unexpected error converting NewUser to User")
@@ -340,3 +343,127 @@ func TestApplyUpdates(t *testing.T) {
 		}
 	}
 }
+
+func TestPasswordChangeValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		change      PasswordChange
+		expectError bool
+	}{
+		{
+			"Valid password change",
+			PasswordChange{"password1234", "newpassword", "newpassword"},
+			false,
+		},
+		{
+			"New password too short",
+			PasswordChange{"password1234", "pswd", "pswd"},
+			true,
+		},
+		{
+			"New password and confirmation do not match",
+			PasswordChange{"password1234", "newpassword", "newpasswrod"},
+			true,
+		},
+		{
+			"New password same as current",
+			PasswordChange{"password1234", "password1234", "password1234"},
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		err := c.change.Validate()
+		if err != nil && !c.expectError {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+		}
+		if err == nil && c.expectError {
+			t.Errorf("case %s: expected error but didn't get one", c.name)
+		}
+	}
+}
+
+func TestApplyProfileUpdates(t *testing.T) {
+	str := func(s string) *string { return &s }
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	cases := []struct {
+		name        string
+		update      Updates
+		expectError bool
+	}{
+		{"Valid bio", Updates{Bio: str("I like turtles")}, false},
+		{"Bio too long", Updates{Bio: str(strings.Repeat("a", maxBioLength+1))}, true},
+		{"Multibyte bio at limit", Updates{Bio: str(strings.Repeat("é", maxBioLength))}, false},
+		{"Valid pronouns", Updates{Pronouns: str("they/them")}, false},
+		{"Pronouns too long", Updates{Pronouns: str(strings.Repeat("a", maxPronounsLength+1))}, true},
+		{"Valid time zone", Updates{TimeZone: str("America/Los_Angeles")}, false},
+		{"Clear time zone", Updates{TimeZone: str("")}, false},
+		{"Invalid time zone", Updates{TimeZone: str("Mars/Olympus_Mons")}, true},
+		{"Valid status", Updates{Status: &Status{Text: "lunch", Emoji: "🍔", ExpiresAt: &future}}, false},
+		{"Status text too long", Updates{Status: &Status{Text: strings.Repeat("a", maxStatusTextLength+1)}}, true},
+		{"Status emoji too long", Updates{Status: &Status{Emoji: strings.Repeat(":", maxStatusEmojiLength+1)}}, true},
+		{"Status already expired", Updates{Status: &Status{Text: "lunch", ExpiresAt: &past}}, true},
+	}
+
+	for _, c := range cases {
+		user := &User{FirstName: "Rio", LastName: "Ishii"}
+		err := user.ApplyUpdates(&c.update)
+		if err != nil && !c.expectError {
+			t.Errorf("case %s: unexpected error applying updates: %v", c.name, err)
+		}
+		if c.expectError && err == nil {
+			t.Errorf("case %s: expected error but didn't get one", c.name)
+		}
+	}
+
+	user := &User{Status: &Status{Text: "lunch"}}
+	if err := user.ApplyUpdates(&Updates{Status: &Status{}}); err != nil {
+		t.Fatalf("unexpected error clearing status: %v", err)
+	}
+	if user.Status != nil {
+		t.Errorf("expected status to be cleared but got %v", user.Status)
+	}
+}
+
+func TestValidateUserName(t *testing.T) {
+	cases := []struct {
+		name        string
+		userName    string
+		expectError bool
+	}{
+		{"Valid user name", "rio.ishii-2_0", false},
+		{"Empty user name", "", true},
+		{"Contains spaces", "rio ishii", true},
+		{"Too long", strings.Repeat("a", maxUserNameLength+1), true},
+		{"Maximum length", strings.Repeat("a", maxUserNameLength), false},
+		{"Invalid characters", "rio@ishii", true},
+		{"Non-ASCII letters", "josé", true},
+		{"Reserved", "admin", true},
+		{"Reserved in different case", "System", true},
+		{"Contains reserved", "adminrio", false},
+	}
+
+	for _, c := range cases {
+		err := ValidateUserName(c.userName)
+		if err != nil && !c.expectError {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+		}
+		if err == nil && c.expectError {
+			t.Errorf("case %s: expected error but didn't get one", c.name)
+		}
+	}
+}
+
+func TestUserJSONHidesAdminFields(t *testing.T) {
+	user := &User{ID: 1, Email: "test@example.com", UserName: "tester", Admin: true, Suspended: true, PasswordResetRequired: true}
+	buf, err := json.Marshal(user)
+	if err != nil {
+		t.Fatalf("error encoding user: %v", err)
+	}
+	for _, hidden := range []string{"test@example.com", "admin", "suspended", "passwordResetRequired"} {
+		if strings.Contains(string(buf), hidden) {
+			t.Errorf("user JSON contains %q: %s", hidden, buf)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+//Package userstest provides a conformance test suite that every
+//users.Store implementation must pass, so that stores can be swapped
+//without changing the behavior handlers rely on.
+package userstest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/UW-Info-441-Winter-Quarter-2020/homework-rioishii/servers/gateway/models/users"
+)
+
+//NewStoreFunc returns a new, empty store for a single test
+type NewStoreFunc func(t *testing.T) users.Store
+
+//TestStore runs the conformance suite against stores created by newStore
+func TestStore(t *testing.T, newStore NewStoreFunc) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store users.Store)
+	}{
+		{"InsertAndGet", testInsertAndGet},
+		{"GetByIDs", testGetByIDs},
+		{"Duplicates", testDuplicates},
+		{"NotFound", testNotFound},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"List", testList},
+		{"AdminFlags", testAdminFlags},
+		{"UserNames", testUserNames},
+		{"Password", testPassword},
+		{"CanceledContext", testCanceledContext},
+	}
+	for _, test := range tests {
+		fn := test.fn
+		t.Run(test.name, func(t *testing.T) {
+			fn(t, newStore(t))
+		})
+	}
+}
+
+//newUser returns a user to insert, with fields derived from name
+func newUser(name string) *users.User {
+	return &users.User{
+		Email:     name + "@example.com",
+		PassHash:  []byte("hash-" + name),
+		UserName:  name,
+		FirstName: "First" + name,
+		LastName:  "Last" + name,
+		PhotoURL:  "https://example.com/" + name,
+	}
+}
+
+//mustInsert inserts a user derived from name and fails the test on error
+func mustInsert(t *testing.T, store users.Store, name string) *users.User {
+	t.Helper()
+	user, err := store.Insert(context.Background(), newUser(name))
+	if err != nil {
+		t.Fatalf("error inserting %s: %v", name, err)
+	}
+	return user
+}
+
+func testInsertAndGet(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	first := mustInsert(t, store, "first")
+	second := mustInsert(t, store, "second")
+	if first.ID <= 0 || second.ID <= first.ID {
+		t.Fatalf("IDs should be positive and increasing: got %d then %d", first.ID, second.ID)
+	}
+
+	gets := map[string]func() (*users.User, error){
+		"GetByID":       func() (*users.User, error) { return store.GetByID(ctx, second.ID) },
+		"GetByIDFresh":  func() (*users.User, error) { return store.GetByIDFresh(ctx, second.ID) },
+		"GetByEmail":    func() (*users.User, error) { return store.GetByEmail(ctx, "second@example.com") },
+		"GetByUserName": func() (*users.User, error) { return store.GetByUserName(ctx, "second") },
+	}
+	for name, get := range gets {
+		user, err := get()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if user.ID != second.ID || user.Email != "second@example.com" || user.UserName != "second" ||
+			user.FirstName != "Firstsecond" || user.LastName != "Lastsecond" ||
+			user.PhotoURL != "https://example.com/second" || string(user.PassHash) != "hash-second" {
+			t.Errorf("%s: incorrect user: %+v", name, user)
+		}
+		if user.Admin || user.Suspended || user.PasswordResetRequired || user.Status != nil {
+			t.Errorf("%s: new users should have default flags and no status: %+v", name, user)
+		}
+	}
+}
+
+func testGetByIDs(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	first := mustInsert(t, store, "first")
+	second := mustInsert(t, store, "second")
+	third := mustInsert(t, store, "third")
+
+	found, err := store.GetByIDs(ctx, []int64{third.ID, first.ID + 1000, first.ID, second.ID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"third", "first", "second"}
+	if len(found) != len(expected) {
+		t.Fatalf("incorrect number of users: expected %d but got %d", len(expected), len(found))
+	}
+	for i, user := range found {
+		if user.UserName != expected[i] {
+			t.Errorf("incorrect user at %d: expected %s but got %s", i, expected[i], user.UserName)
+		}
+	}
+
+	found, err = store.GetByIDs(ctx, nil)
+	if err != nil || len(found) != 0 {
+		t.Errorf("expected no users for no IDs but got %v (error %v)", found, err)
+	}
+}
+
+func testDuplicates(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	mustInsert(t, store, "taken")
+
+	dupEmail := newUser("other")
+	dupEmail.Email = "taken@example.com"
+	if _, err := store.Insert(ctx, dupEmail); err != users.ErrDuplicateEmail {
+		t.Errorf("incorrect error for duplicate email: expected %v but got %v", users.ErrDuplicateEmail, err)
+	}
+	dupUserName := newUser("another")
+	dupUserName.UserName = "taken"
+	if _, err := store.Insert(ctx, dupUserName); err != users.ErrDuplicateUserName {
+		t.Errorf("incorrect error for duplicate user name: expected %v but got %v", users.ErrDuplicateUserName, err)
+	}
+	mustInsert(t, store, "other")
+}
+
+func testNotFound(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	user := mustInsert(t, store, "exists")
+	missing := user.ID + 1000
+
+	checks := map[string]error{}
+	_, checks["GetByID"] = store.GetByID(ctx, missing)
+	_, checks["GetByIDFresh"] = store.GetByIDFresh(ctx, missing)
+	_, checks["GetByEmail"] = store.GetByEmail(ctx, "missing@example.com")
+	_, checks["GetByUserName"] = store.GetByUserName(ctx, "missing")
+	_, checks["Update"] = store.Update(ctx, missing, &users.Updates{FirstName: "New"})
+	checks["Delete"] = store.Delete(ctx, missing)
+	_, checks["SetSuspended"] = store.SetSuspended(ctx, missing, true)
+	_, checks["SetPasswordResetRequired"] = store.SetPasswordResetRequired(ctx, missing, true)
+	_, checks["UpdatePhotoURL"] = store.UpdatePhotoURL(ctx, missing, "https://example.com/new")
+	_, checks["UpdateUserName"] = store.UpdateUserName(ctx, missing, "newname")
+	_, checks["UpdatePassword"] = store.UpdatePassword(ctx, missing, []byte("new-hash"))
+	for name, err := range checks {
+		if err != users.ErrUserNotFound {
+			t.Errorf("%s: incorrect error: expected %v but got %v", name, users.ErrUserNotFound, err)
+		}
+	}
+}
+
+func testUpdate(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	user := mustInsert(t, store, "updater")
+	bio := "hello"
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	updated, err := store.Update(ctx, user.ID, &users.Updates{
+		FirstName: "New",
+		Bio:       &bio,
+		Status:    &users.Status{Text: "busy", ExpiresAt: &expiresAt},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error updating: %v", err)
+	}
+	if updated.FirstName != "New" || updated.LastName != "Lastupdater" || updated.Bio != "hello" {
+		t.Errorf("incorrect updated user: %+v", updated)
+	}
+
+	got, err := store.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error getting updated user: %v", err)
+	}
+	if got.FirstName != "New" || got.Bio != "hello" {
+		t.Errorf("update was not stored: %+v", got)
+	}
+	if got.Status == nil || got.Status.Text != "busy" || got.Status.ExpiresAt == nil || !got.Status.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("incorrect stored status: %+v", got.Status)
+	}
+
+	if _, err := store.Update(ctx, user.ID, &users.Updates{}); err == nil {
+		t.Errorf("expected error for empty updates")
+	}
+}
+
+func testDelete(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	user := mustInsert(t, store, "deleted")
+	if err := store.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error deleting: %v", err)
+	}
+	if _, err := store.GetByID(ctx, user.ID); err != users.ErrUserNotFound {
+		t.Errorf("incorrect error getting deleted user: expected %v but got %v", users.ErrUserNotFound, err)
+	}
+	//the user name and email are free to be taken again
+	mustInsert(t, store, "deleted")
+}
+
+func testList(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	ids := []int64{}
+	for i := 0; i < 5; i++ {
+		ids = append(ids, mustInsert(t, store, fmt.Sprintf("lister%d", i)).ID)
+	}
+	mustInsert(t, store, "other")
+	if _, err := store.SetSuspended(ctx, ids[1], true); err != nil {
+		t.Fatalf("unexpected error suspending: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		params   *users.ListParams
+		expected []int64
+	}{
+		{"Query", &users.ListParams{Query: "lister", Limit: 10}, ids},
+		{"Paged", &users.ListParams{Query: "lister", Offset: 1, Limit: 2}, ids[1:3]},
+		{"Past End", &users.ListParams{Query: "lister", Offset: 10, Limit: 2}, []int64{}},
+		{"Suspended", &users.ListParams{Suspended: boolPtr(true), Limit: 10}, ids[1:2]},
+		{"Wildcards Escaped", &users.ListParams{Query: "%", Limit: 10}, []int64{}},
+	}
+	for _, c := range cases {
+		found, err := store.List(ctx, c.params)
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		foundIDs := []int64{}
+		for _, user := range found {
+			foundIDs = append(foundIDs, user.ID)
+		}
+		if fmt.Sprint(foundIDs) != fmt.Sprint(c.expected) {
+			t.Errorf("case %s: incorrect users: expected %v but got %v", c.name, c.expected, foundIDs)
+		}
+	}
+}
+
+func testAdminFlags(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	user := mustInsert(t, store, "flagged")
+	if updated, err := store.SetSuspended(ctx, user.ID, true); err != nil || !updated.Suspended {
+		t.Errorf("user should be suspended: %+v (error %v)", updated, err)
+	}
+	if updated, err := store.SetPasswordResetRequired(ctx, user.ID, true); err != nil || !updated.PasswordResetRequired {
+		t.Errorf("user should require a password reset: %+v (error %v)", updated, err)
+	}
+	if updated, err := store.UpdatePhotoURL(ctx, user.ID, "/v1/avatars/1/128.png"); err != nil || updated.PhotoURL != "/v1/avatars/1/128.png" {
+		t.Errorf("incorrect photo URL: %+v (error %v)", updated, err)
+	}
+	got, err := store.GetByID(ctx, user.ID)
+	if err != nil || !got.Suspended || !got.PasswordResetRequired {
+		t.Errorf("flags were not stored: %+v (error %v)", got, err)
+	}
+}
+
+func testUserNames(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	user := mustInsert(t, store, "oldname")
+	other := mustInsert(t, store, "othername")
+	since := time.Now().Add(-time.Minute)
+
+	if _, err := store.UpdateUserName(ctx, user.ID, "othername"); err != users.ErrDuplicateUserName {
+		t.Errorf("incorrect error for taken user name: expected %v but got %v", users.ErrDuplicateUserName, err)
+	}
+	updated, err := store.UpdateUserName(ctx, user.ID, "newname")
+	if err != nil {
+		t.Fatalf("unexpected error changing user name: %v", err)
+	}
+	if updated.UserName != "newname" {
+		t.Errorf("incorrect user name: expected newname but got %s", updated.UserName)
+	}
+	if _, err := store.GetByUserName(ctx, "oldname"); err != users.ErrUserNotFound {
+		t.Errorf("old user name should no longer match: %v", err)
+	}
+
+	past, err := store.GetPastUserNames(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error getting past user names: %v", err)
+	}
+	if len(past) != 1 || past[0].UserName != "oldname" || past[0].UserID != user.ID {
+		t.Errorf("incorrect past user names: %+v", past)
+	}
+	if past, err := store.GetPastUserNames(ctx, other.ID); err != nil || len(past) != 0 {
+		t.Errorf("expected no past user names for other user: %+v (error %v)", past, err)
+	}
+
+	if holder, err := store.GetUserNameHolder(ctx, "oldname", since); err != nil || holder != user.ID {
+		t.Errorf("incorrect holder: expected %d but got %d (error %v)", user.ID, holder, err)
+	}
+	if holder, err := store.GetUserNameHolder(ctx, "oldname", time.Now().Add(time.Minute)); err != nil || holder != 0 {
+		t.Errorf("hold should have lapsed: got holder %d (error %v)", holder, err)
+	}
+}
+
+func testPassword(t *testing.T, store users.Store) {
+	ctx := context.Background()
+	user := mustInsert(t, store, "password")
+	if _, err := store.SetPasswordResetRequired(ctx, user.ID, true); err != nil {
+		t.Fatalf("unexpected error requiring reset: %v", err)
+	}
+	updated, err := store.UpdatePassword(ctx, user.ID, []byte("new-hash"))
+	if err != nil {
+		t.Fatalf("unexpected error updating password: %v", err)
+	}
+	if string(updated.PassHash) != "new-hash" || updated.PasswordResetRequired {
+		t.Errorf("password update should store the hash and clear the reset: %+v", updated)
+	}
+}
+
+func testCanceledContext(t *testing.T, store users.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.Insert(ctx, newUser("canceled")); err == nil {
+		t.Errorf("expected error inserting with a canceled context")
+	}
+	if _, err := store.GetByUserName(context.Background(), "canceled"); err != users.ErrUserNotFound {
+		t.Errorf("user should not be inserted with a canceled context: %v", err)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -12,12 +13,15 @@ import (
 //Production systems should use a shared server store like redis
 type MemStore struct {
 	entries *cache.Cache
+	mx      sync.Mutex
+	users   map[int64]map[SessionID]struct{}
 }
 
 //NewMemStore constructs and returns a new MemStore
 func NewMemStore(sessionDuration time.Duration, purgeInterval time.Duration) *MemStore {
 	return &MemStore{
 		entries: cache.New(sessionDuration, purgeInterval),
+		users:   make(map[int64]map[SessionID]struct{}),
 	}
 }
 
@@ -50,3 +54,43 @@ func (ms *MemStore) Delete(sid SessionID) error {
 	ms.entries.Delete(sid.String())
 	return nil
 }
+
+//AddUserSession associates the SessionID with the given user ID so that
+//all of a user's sessions can later be found and ended together.
+func (ms *MemStore) AddUserSession(userID int64, sid SessionID) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if ms.users[userID] == nil {
+		ms.users[userID] = make(map[SessionID]struct{})
+	}
+	ms.users[userID][sid] = struct{}{}
+	return nil
+}
+
+//GetUserSessions returns the SessionIDs associated with the given user ID.
+//Sessions that have expired or been deleted are not returned.
+func (ms *MemStore) GetUserSessions(userID int64) ([]SessionID, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	sids := []SessionID{}
+	for sid := range ms.users[userID] {
+		if _, found := ms.entries.Get(sid.String()); !found {
+			delete(ms.users[userID], sid)
+			continue
+		}
+		sids = append(sids, sid)
+	}
+	return sids, nil
+}
+
+//DeleteUserSessions deletes all state data for every SessionID
+//associated with the given user ID.
+func (ms *MemStore) DeleteUserSessions(userID int64) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for sid := range ms.users[userID] {
+		ms.entries.Delete(sid.String())
+	}
+	delete(ms.users, userID)
+	return nil
+}
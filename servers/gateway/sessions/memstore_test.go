@@ -75,3 +75,56 @@ func TestMemStoreSaveUnmarshalble(t *testing.T) {
 		t.Error("expected error when attempting to save a session state with an unmarshalable field")
 	}
 }
+
+func TestMemStoreUserSessions(t *testing.T) {
+	store := NewMemStore(time.Hour, time.Minute)
+	userID := int64(7)
+
+	sid1, err := NewSessionID("test key")
+	if err != nil {
+		t.Fatalf("error generating new SessionID: %v", err)
+	}
+	sid2, err := NewSessionID("test key")
+	if err != nil {
+		t.Fatalf("error generating new SessionID: %v", err)
+	}
+	other, err := NewSessionID("test key")
+	if err != nil {
+		t.Fatalf("error generating new SessionID: %v", err)
+	}
+
+	for _, sid := range []SessionID{sid1, sid2, other} {
+		if err := store.Save(sid, "state"); err != nil {
+			t.Fatalf("error saving state: %v", err)
+		}
+	}
+	store.AddUserSession(userID, sid1)
+	store.AddUserSession(userID, sid2)
+	store.AddUserSession(userID+1, other)
+
+	sids, err := store.GetUserSessions(userID)
+	if err != nil {
+		t.Fatalf("error getting user sessions: %v", err)
+	}
+	if len(sids) != 2 {
+		t.Errorf("incorrect number of user sessions: expected 2 but got %d", len(sids))
+	}
+
+	//sessions ended individually should no longer be reported
+	store.Delete(sid1)
+	sids, _ = store.GetUserSessions(userID)
+	if len(sids) != 1 || sids[0] != sid2 {
+		t.Errorf("incorrect user sessions after delete: expected [%s] but got %v", sid2, sids)
+	}
+
+	if err := store.DeleteUserSessions(userID); err != nil {
+		t.Fatalf("error deleting user sessions: %v", err)
+	}
+	var state string
+	if err := store.Get(sid2, &state); err != ErrStateNotFound {
+		t.Errorf("incorrect error when getting state of deleted user session: expected %v but got %v", ErrStateNotFound, err)
+	}
+	if err := store.Get(other, &state); err != nil {
+		t.Errorf("sessions of other users should not be deleted: %v", err)
+	}
+}
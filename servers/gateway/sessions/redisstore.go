@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -55,6 +56,61 @@ func (rs *RedisStore) Delete(sid SessionID) error {
 	return nil
 }
 
+//AddUserSession associates the SessionID with the given user ID so that
+//all of a user's sessions can later be found and ended together.
+func (rs *RedisStore) AddUserSession(userID int64, sid SessionID) error {
+	key := getUserRedisKey(userID)
+	if err := rs.Client.SAdd(key, sid.String()).Err(); err != nil {
+		return err
+	}
+	return rs.Client.Expire(key, rs.SessionDuration).Err()
+}
+
+//GetUserSessions returns the SessionIDs associated with the given user ID.
+//Sessions that have expired or been deleted are not returned.
+func (rs *RedisStore) GetUserSessions(userID int64) ([]SessionID, error) {
+	key := getUserRedisKey(userID)
+	members, err := rs.Client.SMembers(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	sids := []SessionID{}
+	for _, member := range members {
+		sid := SessionID(member)
+		n, err := rs.Client.Exists(sid.getRedisKey()).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			rs.Client.SRem(key, member)
+			continue
+		}
+		sids = append(sids, sid)
+	}
+	return sids, nil
+}
+
+//DeleteUserSessions deletes all state data for every SessionID
+//associated with the given user ID.
+func (rs *RedisStore) DeleteUserSessions(userID int64) error {
+	key := getUserRedisKey(userID)
+	members, err := rs.Client.SMembers(key).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{key}
+	for _, member := range members {
+		keys = append(keys, SessionID(member).getRedisKey())
+	}
+	return rs.Client.Del(keys...).Err()
+}
+
+//getUserRedisKey returns the redis key for the set of SessionIDs
+//belonging to the given user ID
+func getUserRedisKey(userID int64) string {
+	return "uid:" + strconv.FormatInt(userID, 10)
+}
+
 //getRedisKey() returns the redis key to use for the SessionID
 func (sid SessionID) getRedisKey() string {
 	//convert the SessionID to a string and add the prefix "sid:" to keep
@@ -23,4 +23,15 @@ type Store interface {
 
 	//Delete deletes all state data associated with the SessionID from the store.
 	Delete(sid SessionID) error
+
+	//AddUserSession associates the SessionID with the given user ID so that
+	//all of a user's sessions can later be found and ended together.
+	AddUserSession(userID int64, sid SessionID) error
+
+	//GetUserSessions returns the SessionIDs associated with the given user ID.
+	GetUserSessions(userID int64) ([]SessionID, error)
+
+	//DeleteUserSessions deletes all state data for every SessionID
+	//associated with the given user ID.
+	DeleteUserSessions(userID int64) error
 }
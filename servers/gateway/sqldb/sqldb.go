@@ -0,0 +1,100 @@
+//Package sqldb opens the gateway's SQL database and smooths over the
+//differences between the databases it supports, so that the SQL stores
+//can share one set of queries.
+package sqldb
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	//drivers for each of the supported dialects
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//Dialect identifies the kind of SQL database a store talks to
+type Dialect int
+
+//The supported dialects. MySQL is the zero value, so stores
+//that aren't told otherwise keep their original behavior.
+const (
+	MySQL Dialect = iota
+	Postgres
+	SQLite
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case SQLite:
+		return "sqlite"
+	default:
+		return "mysql"
+	}
+}
+
+//DriverName returns the name of the database/sql driver for the dialect
+func (d Dialect) DriverName() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case SQLite:
+		return "sqlite3"
+	default:
+		return "mysql"
+	}
+}
+
+//Rebind converts a query written with "?" placeholders into the
+//placeholder style of the dialect. Queries must not contain a literal
+//question mark.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+//ParseDSN returns the dialect of the data source name and the DSN to pass
+//to its driver. DSNs starting with "postgres://" or "postgresql://" are
+//PostgreSQL, DSNs starting with "sqlite:" name a SQLite database file,
+//and anything else is a MySQL DSN, optionally starting with "mysql://".
+func ParseDSN(dsn string) (Dialect, string) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return Postgres, dsn
+	case strings.HasPrefix(dsn, "sqlite:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(dsn, "sqlite:"), "//")
+		//wait for other connections' writes rather than failing,
+		//and let readers proceed while a write is in progress
+		if !strings.Contains(path, "?") {
+			path += "?_busy_timeout=5000&_journal_mode=WAL"
+		}
+		return SQLite, path
+	default:
+		return MySQL, strings.TrimPrefix(dsn, "mysql://")
+	}
+}
+
+//Open opens the database named by the DSN and returns it with its dialect
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	dialect, driverDSN := ParseDSN(dsn)
+	db, err := sql.Open(dialect.DriverName(), driverDSN)
+	if err != nil {
+		return nil, dialect, err
+	}
+	return db, dialect, nil
+}
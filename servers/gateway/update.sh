@@ -83,6 +83,7 @@ docker run \
     -d \
     -p 443:443 \
     -v /etc/letsencrypt:/etc/letsencrypt:ro \
+    -v gatewayAvatars:/avatars \
     -e MYSQL_ROOT_PASSWORD=$MYSQL_ROOT_PASSWORD \
     -e SUMMARY=summary:4000 \
     -e CHAT="chat1:5001,chat2:5002,chat3:5003" \